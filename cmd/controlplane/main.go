@@ -4,30 +4,145 @@ import (
 	"flag"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/sirupsen/logrus"
 
+	"github.com/supergiant/supergiant/pkg/backup"
 	"github.com/supergiant/supergiant/pkg/controlplane"
+	"github.com/supergiant/supergiant/pkg/provisioner"
+	"github.com/supergiant/supergiant/pkg/workflows"
 )
 
 var (
-	addr         = flag.String("address", "0.0.0.0", "network interface to attach server to")
-	port         = flag.Int("port", 8080, "tcp port to listen for incoming requests")
-	etcdURL      = flag.String("etcd-url", "localhost:2379", "etcd url with port")
-	templatesDir = flag.String("templates", "/etc/supergiant/templates/", "supergiant will load script templates from the specified directory on start")
-	logLevel     = flag.String("log-level", "INFO", "logging level, e.g. info, warning, debug, error, fatal")
+	addr               = flag.String("address", "0.0.0.0", "network interface to attach server to")
+	port               = flag.Int("port", 8080, "tcp port to listen for incoming requests")
+	storageMode        = flag.String("storage", "etcd", "storage backend to keep supergiant data in, e.g. etcd, file")
+	etcdURL            = flag.String("etcd-url", "localhost:2379", "comma separated list of etcd urls with port")
+	etcdDialTimeout    = flag.Duration("etcd-dial-timeout", 10*time.Second, "timeout of establishing connection to etcd")
+	etcdRequestTimeout = flag.Duration("etcd-request-timeout", 10*time.Second, "timeout of a single etcd request, zero means no timeout")
+	storagePath        = flag.String("storage-path", "/var/lib/supergiant/supergiant.db", "path to the data file when file storage is used")
+	templatesDir       = flag.String("templates", "/etc/supergiant/templates/", "supergiant will load script templates from the specified directory on start")
+	workflowsDir       = flag.String("workflows", "/etc/supergiant/workflows/", "supergiant will load workflow definitions from the specified directory on start")
+	logLevel           = flag.String("log-level", "INFO", "logging level, e.g. info, warning, debug, error, fatal")
+	encryptionKeyFile  = flag.String("encryption-key-file", "", "path to the keyring file used to encrypt secrets at rest, "+
+		"master key can be also provided with "+encryptionKeyEnv+" environment variable")
+	encryptedPrefixes = flag.String("encrypted-prefixes", "", "comma separated list of storage prefixes to encrypt, secrets are encrypted by default")
+	rotateKeys        = flag.Bool("rotate-encryption-keys", false, "re-encrypt all secrets with the active key and exit")
+	taskRetention     = flag.Duration("task-retention", 0, "finished tasks and their logs older than that are removed, zero keeps tasks forever")
+	tasksPerCluster   = flag.Int("task-retention-per-cluster", 0, "number of the latest finished tasks kept for every cluster, zero means no limit")
+	keepFailedTasks   = flag.Bool("task-retention-keep-failed", false, "never remove failed tasks")
+	janitorInterval   = flag.Duration("task-janitor-interval", time.Hour, "how often finished tasks are pruned, zero disables periodic pruning")
+	stepParallelism   = flag.Int("step-parallelism", workflows.DefaultParallelism, "number of independent steps of a task that run at the same time")
+	maxRunningTasks   = flag.Int("max-running-tasks", workflows.DefaultMaxRunningTasks, "number of tasks that run at the same time, the rest wait in the queue. Masters of a cluster take one place together, the rest of them run over the limit")
+	cloudAPIRate      = flag.Float64("cloud-api-rate", workflows.DefaultCloudRate, "number of cloud API steps of a cloud account started per second, zero means no limit")
+	cloudAPIBurst     = flag.Int("cloud-api-burst", workflows.DefaultCloudBurst, "number of cloud API steps of a cloud account that can be started at once")
+	replicaID         = flag.String("replica-id", "", "unique id of the control plane replica that shares the storage with others, host name is used when empty")
+	taskLeaseTTL      = flag.Duration("task-lease-ttl", workflows.DefaultLeaseTTL, "tasks of the replica that has stopped renewing their leases for that long are taken over by the leader")
+	resumePolicy      = flag.String("task-resume-policy", string(provisioner.FailTasks), "tasks interrupted by restart are either resumed or marked failed, e.g. resume, fail")
+	exportFile        = flag.String("export", "", "write backup archive of all supergiant data to the file and exit")
+	importFile        = flag.String("import", "", "restore backup archive from the file to the empty storage and exit")
+	excludeSecrets    = flag.Bool("exclude-secrets", false, "leave cloud accounts, PKI and cluster credentials out of export or import")
+	excludeTasks      = flag.Bool("exclude-tasks", false, "leave task history out of export or import")
+	migrateDryRun     = flag.Bool("migrate-dry-run", false, "report storage migrations that would be applied on start and exit")
 )
 
+const encryptionKeyEnv = "SUPERGIANT_ENCRYPTION_KEY"
+
 func main() {
 	flag.Parse()
 
 	cfg := &controlplane.Config{
-		Addr:         *addr,
-		Port:         *port,
-		EtcdUrl:      *etcdURL,
-		TemplatesDir: *templatesDir,
-		LogLevel:     *logLevel,
+		Addr:               *addr,
+		Port:               *port,
+		Storage:            *storageMode,
+		EtcdUrl:            *etcdURL,
+		EtcdDialTimeout:    *etcdDialTimeout,
+		EtcdRequestTimeout: *etcdRequestTimeout,
+		StoragePath:        *storagePath,
+		TemplatesDir:       *templatesDir,
+		WorkflowsDir:       *workflowsDir,
+		LogLevel:           *logLevel,
+		EncryptionKeyFile:  *encryptionKeyFile,
+		EncryptionKey:      os.Getenv(encryptionKeyEnv),
+		TaskRetention: workflows.RetentionPolicy{
+			MaxAge:        *taskRetention,
+			MaxPerCluster: *tasksPerCluster,
+			KeepFailed:    *keepFailedTasks,
+		},
+		TaskJanitorInterval: *janitorInterval,
+		StepParallelism:     *stepParallelism,
+		TaskResumePolicy:    provisioner.ResumePolicy(*resumePolicy),
+		MaxRunningTasks:     *maxRunningTasks,
+		CloudAPIRate:        *cloudAPIRate,
+		CloudAPIBurst:       *cloudAPIBurst,
+		ReplicaID:           *replicaID,
+		TaskLeaseTTL:        *taskLeaseTTL,
+	}
+
+	if *encryptedPrefixes != "" {
+		cfg.EncryptedPrefixes = strings.Split(*encryptedPrefixes, ",")
+	}
+
+	backupOpts := backup.Options{
+		ExcludeSecrets: *excludeSecrets,
+		ExcludeTasks:   *excludeTasks,
+	}
+
+	if *exportFile != "" {
+		f, err := os.OpenFile(*exportFile, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+		if err != nil {
+			logrus.Fatalf("create export file: %v", err)
+		}
+
+		if err := controlplane.Export(cfg, backupOpts, f); err != nil {
+			f.Close()
+			logrus.Fatalf("export: %v", err)
+		}
+
+		if err := f.Close(); err != nil {
+			logrus.Fatalf("close export file: %v", err)
+		}
+		logrus.Infof("supergiant data has been exported to %s", *exportFile)
+		return
+	}
+
+	if *importFile != "" {
+		f, err := os.Open(*importFile)
+		if err != nil {
+			logrus.Fatalf("open import file: %v", err)
+		}
+		defer f.Close()
+
+		count, err := controlplane.Import(cfg, backupOpts, f)
+		if err != nil {
+			logrus.Fatalf("import: %v", err)
+		}
+		logrus.Infof("%d records have been imported from %s", count, *importFile)
+		return
+	}
+
+	if *migrateDryRun {
+		results, err := controlplane.Migrate(cfg, true)
+		if err != nil {
+			logrus.Fatalf("migrate dry run: %v", err)
+		}
+		for _, r := range results {
+			logrus.Infof("migration %d %s: %d records to migrate", r.Version, r.Name, r.Records)
+		}
+		logrus.Infof("%d migrations pending", len(results))
+		return
+	}
+
+	if *rotateKeys {
+		count, err := controlplane.RotateEncryptionKeys(cfg)
+		if err != nil {
+			logrus.Fatalf("rotate encryption keys: %v", err)
+		}
+		logrus.Infof("%d values have been re-encrypted", count)
+		return
 	}
 
 	server, err := controlplane.New(cfg)
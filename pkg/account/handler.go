@@ -10,6 +10,7 @@ import (
 	"gopkg.in/asaskevich/govalidator.v8"
 
 	"github.com/pkg/errors"
+	"github.com/supergiant/supergiant/pkg/api"
 	"github.com/supergiant/supergiant/pkg/message"
 	"github.com/supergiant/supergiant/pkg/model"
 	"github.com/supergiant/supergiant/pkg/sgerrors"
@@ -64,12 +65,35 @@ func (h *Handler) Create(rw http.ResponseWriter, r *http.Request) {
 
 // ListAll retrieves all cloud accounts
 func (h *Handler) ListAll(rw http.ResponseWriter, r *http.Request) {
-	accounts, err := h.service.GetAll(r.Context())
+	page, err := api.ParsePage(r)
 	if err != nil {
+		message.SendValidationFailed(rw, err)
+		return
+	}
+
+	var (
+		accounts []model.CloudAccount
+		next     string
+	)
+
+	if page.Paginated() {
+		accounts, next, err = h.service.GetPage(r.Context(), page.Limit, page.Continue)
+	} else {
+		accounts, err = h.service.GetAll(r.Context())
+	}
+
+	if err != nil {
+		if sgerrors.IsInvalidContinue(err) {
+			message.SendValidationFailed(rw, err)
+			return
+		}
+
 		logrus.Errorf("account handler: list all %v", err)
 		message.SendUnknownError(rw, err)
 		return
 	}
+
+	api.SetContinue(rw, next)
 	if err := json.NewEncoder(rw).Encode(accounts); err != nil {
 		logrus.Errorf("account handler: list all %v", err)
 		message.SendUnknownError(rw, err)
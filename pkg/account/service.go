@@ -51,6 +51,27 @@ func (s *Service) GetAll(ctx context.Context) ([]model.CloudAccount, error) {
 	return accounts, nil
 }
 
+// GetPage retrieves a page of cloud accounts sorted by name and the continue token of the next page
+func (s *Service) GetPage(ctx context.Context, limit int, continueToken string) ([]model.CloudAccount, string, error) {
+	accounts := make([]model.CloudAccount, 0)
+	kvs, next, err := s.repository.Page(ctx, s.storagePrefix, limit, continueToken)
+	if err != nil {
+		return accounts, "", err
+	}
+	for _, kv := range kvs {
+		ca := new(model.CloudAccount)
+		err = json.NewDecoder(bytes.NewReader(kv.Value)).Decode(ca)
+		if err != nil {
+			logrus.Warningf("failed to convert stored data to cloud account struct")
+			logrus.Debugf("corrupted data: %s", string(kv.Value))
+			continue
+		}
+		accounts = append(accounts, *ca)
+	}
+
+	return accounts, next, nil
+}
+
 // Get retrieves a user by it's accountName, returns nil if not found
 func (s *Service) Get(ctx context.Context, accountName string) (*model.CloudAccount, error) {
 
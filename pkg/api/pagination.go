@@ -0,0 +1,47 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// ContinueHeader carries the continue token of the next page of a list,
+// the header is absent on the last page.
+const ContinueHeader = "X-Continue"
+
+// Page holds pagination parameters of a list request, zero Limit means no limit
+type Page struct {
+	Limit    int
+	Continue string
+}
+
+// Paginated tells whether the client has asked for a page instead of the whole list
+func (p Page) Paginated() bool {
+	return p.Limit > 0 || p.Continue != ""
+}
+
+// ParsePage reads limit and continue query parameters of the request
+func ParsePage(r *http.Request) (Page, error) {
+	p := Page{
+		Continue: r.URL.Query().Get("continue"),
+	}
+
+	if limit := r.URL.Query().Get("limit"); limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil || l < 0 {
+			return p, errors.Errorf("limit must be a positive number, got %s", limit)
+		}
+		p.Limit = l
+	}
+
+	return p, nil
+}
+
+// SetContinue passes continue token of the next page to the client
+func SetContinue(w http.ResponseWriter, token string) {
+	if token != "" {
+		w.Header().Set(ContinueHeader, token)
+	}
+}
@@ -0,0 +1,42 @@
+package api
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePage(t *testing.T) {
+	testCases := []struct {
+		query    string
+		expected Page
+		hasErr   bool
+	}{
+		{
+			query: "",
+		},
+		{
+			query:    "?limit=10&continue=token",
+			expected: Page{Limit: 10, Continue: "token"},
+		},
+		{
+			query:  "?limit=-1",
+			hasErr: true,
+		},
+		{
+			query:  "?limit=ten",
+			hasErr: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		p, err := ParsePage(httptest.NewRequest("GET", "/kubes"+testCase.query, nil))
+		require.Equal(t, testCase.hasErr, err != nil, testCase.query)
+
+		if !testCase.hasErr {
+			require.Equal(t, testCase.expected, p)
+			require.Equal(t, testCase.query != "", p.Paginated())
+		}
+	}
+}
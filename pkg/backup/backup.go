@@ -0,0 +1,241 @@
+package backup
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/supergiant/supergiant/pkg/account"
+	"github.com/supergiant/supergiant/pkg/helm"
+	"github.com/supergiant/supergiant/pkg/kube"
+	"github.com/supergiant/supergiant/pkg/migrations"
+	"github.com/supergiant/supergiant/pkg/profile"
+	"github.com/supergiant/supergiant/pkg/storage"
+	"github.com/supergiant/supergiant/pkg/user"
+	"github.com/supergiant/supergiant/pkg/workflows"
+)
+
+// FormatVersion is a version of the archive layout
+const FormatVersion = 1
+
+// importBatchSize keeps transactions below the etcd limit of operations per transaction
+const importBatchSize = 100
+
+// Prefixes are all storage prefixes that hold supergiant data. There is no PKI prefix,
+// pki.Service is never given a storage, certificate authorities of clusters are kept
+// in the auth of kubes and in the configs of tasks.
+var Prefixes = []string{
+	account.DefaultStoragePrefix,
+	kube.DefaultStoragePrefix,
+	profile.DefaultKubeProfilePreifx,
+	user.DefaultStoragePrefix,
+	helm.DefaultStoragePrefix,
+	workflows.Prefix,
+	workflows.ClusterIndexPrefix,
+	workflows.ScriptPrefix,
+	migrations.Prefix,
+}
+
+// SecretPrefixes hold cloud credentials
+var SecretPrefixes = []string{
+	account.DefaultStoragePrefix,
+}
+
+// secretFields are paths of credentials in records of the prefixes, they are stripped
+// from the records that are exported without secrets.
+var secretFields = map[string][][]string{
+	kube.DefaultStoragePrefix: {
+		{"auth"},
+	},
+	// Tasks keep the config they have been run with
+	workflows.Prefix: {
+		{"config", "digitalOceanConfig", "accessToken"},
+		{"config", "awsConfig", "keyID"},
+		{"config", "awsConfig", "secret"},
+		{"config", "sshConfig", "bootstrapPrivateKey"},
+		{"config", "certificatesConfig", "password"},
+	},
+}
+
+// Archive is a snapshot of all supergiant records, values are kept
+// with their schema version envelope so they can be migrated after restore.
+type Archive struct {
+	FormatVersion int       `json:"formatVersion"`
+	SchemaVersion int       `json:"schemaVersion"`
+	CreatedAt     time.Time `json:"createdAt"`
+	Records       []Record  `json:"records"`
+}
+
+type Record struct {
+	Key   string `json:"key"`
+	Value []byte `json:"value"`
+}
+
+// Options tell which records are left out of the archive
+type Options struct {
+	// ExcludeSecrets skips cloud accounts and strips credentials of kubes and tasks
+	ExcludeSecrets bool
+	// ExcludeTasks skips task history
+	ExcludeTasks bool
+}
+
+// Export writes gzipped archive of all supergiant records to w, repository
+// must return values with their schema version envelope.
+func Export(ctx context.Context, repository storage.Interface, schemaVersion int, opts Options, w io.Writer) error {
+	archive := &Archive{
+		FormatVersion: FormatVersion,
+		SchemaVersion: schemaVersion,
+		CreatedAt:     time.Now(),
+		Records:       make([]Record, 0),
+	}
+
+	for _, prefix := range Prefixes {
+		kvs, err := repository.List(ctx, prefix)
+		if err != nil {
+			return errors.Wrapf(err, "list %s", prefix)
+		}
+
+		for _, kv := range kvs {
+			if opts.skip(kv.Key) {
+				continue
+			}
+
+			value := kv.Value
+			if opts.ExcludeSecrets && secretFields[prefix] != nil {
+				if value, err = stripFields(value, secretFields[prefix]); err != nil {
+					return errors.Wrapf(err, "strip secrets of %s", kv.Key)
+				}
+			}
+
+			archive.Records = append(archive.Records, Record{
+				Key:   kv.Key,
+				Value: value,
+			})
+		}
+	}
+
+	gw := gzip.NewWriter(w)
+	if err := json.NewEncoder(gw).Encode(archive); err != nil {
+		return errors.Wrap(err, "encode archive")
+	}
+
+	return gw.Close()
+}
+
+// Import restores archive read from r to the empty repository and returns number of restored records.
+// Archive made by a newer version of supergiant is rejected, older archives are migrated on the next start.
+func Import(ctx context.Context, repository storage.Interface, schemaVersion int, opts Options, r io.Reader) (int, error) {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return 0, errors.Wrap(err, "read archive")
+	}
+	defer gr.Close()
+
+	archive := &Archive{}
+	if err := json.NewDecoder(gr).Decode(archive); err != nil {
+		return 0, errors.Wrap(err, "decode archive")
+	}
+
+	if archive.FormatVersion != FormatVersion {
+		return 0, errors.Errorf("unsupported archive format version %d", archive.FormatVersion)
+	}
+
+	if archive.SchemaVersion > schemaVersion {
+		return 0, errors.Errorf("archive schema version %d is newer than supported %d",
+			archive.SchemaVersion, schemaVersion)
+	}
+
+	for _, prefix := range Prefixes {
+		kvs, err := repository.List(ctx, prefix)
+		if err != nil {
+			return 0, errors.Wrapf(err, "list %s", prefix)
+		}
+
+		if len(kvs) > 0 {
+			return 0, errors.Errorf("storage is not empty, %s has %d records", prefix, len(kvs))
+		}
+	}
+
+	count := 0
+	ops := make([]storage.Op, 0, importBatchSize)
+
+	for _, record := range archive.Records {
+		if opts.skip(record.Key) {
+			continue
+		}
+
+		ops = append(ops, storage.PutOp("", record.Key, record.Value))
+		if len(ops) == importBatchSize {
+			if err := repository.Txn(ctx, ops...); err != nil {
+				return count, errors.Wrap(err, "write records")
+			}
+			count += len(ops)
+			ops = ops[:0]
+		}
+	}
+
+	if len(ops) > 0 {
+		if err := repository.Txn(ctx, ops...); err != nil {
+			return count, errors.Wrap(err, "write records")
+		}
+		count += len(ops)
+	}
+
+	return count, nil
+}
+
+func (o Options) skip(key string) bool {
+	if o.ExcludeTasks && (strings.HasPrefix(key, workflows.Prefix) ||
+		strings.HasPrefix(key, workflows.ClusterIndexPrefix)) {
+		return true
+	}
+
+	if o.ExcludeSecrets {
+		for _, prefix := range SecretPrefixes {
+			if strings.HasPrefix(key, prefix) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// stripFields removes fields with the paths from the record keeping the rest of it intact
+func stripFields(value []byte, paths [][]string) ([]byte, error) {
+	version, data := storage.UnwrapVersion(value)
+
+	record := make(map[string]interface{})
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, err
+	}
+
+	for _, path := range paths {
+		obj := record
+		for _, key := range path[:len(path)-1] {
+			if obj, _ = obj[key].(map[string]interface{}); obj == nil {
+				break
+			}
+		}
+
+		if obj != nil {
+			delete(obj, path[len(path)-1])
+		}
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+
+	if version == 0 {
+		return data, nil
+	}
+
+	return storage.WrapVersion(version, data)
+}
@@ -0,0 +1,144 @@
+package backup
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/supergiant/supergiant/pkg/account"
+	"github.com/supergiant/supergiant/pkg/kube"
+	"github.com/supergiant/supergiant/pkg/storage"
+	"github.com/supergiant/supergiant/pkg/user"
+	"github.com/supergiant/supergiant/pkg/workflows"
+)
+
+func newTestRepository(t *testing.T, dir, name string) storage.Interface {
+	r, err := storage.NewFileRepository(path.Join(dir, name))
+	require.NoError(t, err)
+
+	return r
+}
+
+func TestExportImport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sg-backup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	ctx := context.Background()
+	source := newTestRepository(t, dir, "source.db")
+
+	kubeValue, err := storage.WrapVersion(1, []byte(`{"name":"test","auth":{"key":"secret"}}`))
+	require.NoError(t, err)
+
+	require.NoError(t, source.Put(ctx, kube.DefaultStoragePrefix, "test", kubeValue))
+	require.NoError(t, source.Put(ctx, account.DefaultStoragePrefix, "do", []byte(`{"name":"do"}`)))
+	require.NoError(t, source.Put(ctx, user.DefaultStoragePrefix, "root", []byte(`{"login":"root"}`)))
+	require.NoError(t, source.Put(ctx, workflows.Prefix, "task", []byte(`{"id":"task","config":{
+		"clusterName":"test",
+		"digitalOceanConfig":{"region":"fra1","accessToken":"secret-token"},
+		"awsConfig":{"keyID":"secret-key-id","secret":"secret-aws"},
+		"sshConfig":{"user":"root","bootstrapPrivateKey":"secret-private-key"},
+		"certificatesConfig":{"username":"root","password":"secret-password"}}}`)))
+	require.NoError(t, source.Put(ctx, "/other/", "key", []byte("value")))
+
+	testCases := []struct {
+		opts     Options
+		expected []string
+	}{
+		{
+			expected: []string{
+				account.DefaultStoragePrefix + "do",
+				kube.DefaultStoragePrefix + "test",
+				user.DefaultStoragePrefix + "root",
+				workflows.Prefix + "task",
+			},
+		},
+		{
+			opts: Options{
+				ExcludeSecrets: true,
+				ExcludeTasks:   true,
+			},
+			expected: []string{
+				kube.DefaultStoragePrefix + "test",
+				user.DefaultStoragePrefix + "root",
+			},
+		},
+		{
+			opts: Options{
+				ExcludeSecrets: true,
+			},
+			expected: []string{
+				kube.DefaultStoragePrefix + "test",
+				user.DefaultStoragePrefix + "root",
+				workflows.Prefix + "task",
+			},
+		},
+	}
+
+	for i, testCase := range testCases {
+		buf := &bytes.Buffer{}
+		require.NoError(t, Export(ctx, source, 1, testCase.opts, buf))
+
+		gr, err := gzip.NewReader(bytes.NewReader(buf.Bytes()))
+		require.NoError(t, err)
+		archive := &Archive{}
+		require.NoError(t, json.NewDecoder(gr).Decode(archive))
+
+		// No credential is left in the archive without secrets
+		for _, record := range archive.Records {
+			if testCase.opts.ExcludeSecrets {
+				require.NotContains(t, string(record.Value), "secret", record.Key)
+			}
+
+			if record.Key == workflows.Prefix+"task" {
+				require.Contains(t, string(record.Value), `"region":"fra1"`)
+			}
+		}
+
+		target := newTestRepository(t, dir, fmt.Sprintf("target-%d.db", i))
+		count, err := Import(ctx, target, 1, Options{}, bytes.NewReader(buf.Bytes()))
+		require.NoError(t, err)
+		require.Equal(t, len(testCase.expected), count)
+
+		keys := make([]string, 0)
+		for _, prefix := range Prefixes {
+			kvs, err := target.List(ctx, prefix)
+			require.NoError(t, err)
+			for _, kv := range kvs {
+				keys = append(keys, kv.Key)
+			}
+		}
+		require.ElementsMatch(t, testCase.expected, keys)
+
+		raw, err := target.Get(ctx, kube.DefaultStoragePrefix, "test")
+		require.NoError(t, err)
+		version, data := storage.UnwrapVersion(raw)
+		require.Equal(t, 1, version)
+		require.Equal(t, !testCase.opts.ExcludeSecrets, bytes.Contains(data, []byte("secret")))
+
+		// Storage must be empty to import
+		_, err = Import(ctx, target, 1, Options{}, bytes.NewReader(buf.Bytes()))
+		require.Error(t, err)
+	}
+}
+
+func TestImportNewerSchema(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sg-backup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	ctx := context.Background()
+	buf := &bytes.Buffer{}
+	require.NoError(t, Export(ctx, newTestRepository(t, dir, "source.db"), 2, Options{}, buf))
+
+	_, err = Import(ctx, newTestRepository(t, dir, "target.db"), 1, Options{}, buf)
+	require.Error(t, err)
+}
@@ -0,0 +1,54 @@
+package backup
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+
+	"github.com/supergiant/supergiant/pkg/message"
+	"github.com/supergiant/supergiant/pkg/storage"
+)
+
+// Handler is a http controller for exporting the control plane data
+type Handler struct {
+	repository    storage.Interface
+	schemaVersion int
+}
+
+// NewHandler constructs a Handler for backups, repository must return
+// values with their schema version envelope.
+func NewHandler(repository storage.Interface, schemaVersion int) *Handler {
+	return &Handler{
+		repository:    repository,
+		schemaVersion: schemaVersion,
+	}
+}
+
+func (h *Handler) Register(r *mux.Router) {
+	r.HandleFunc("/backup", h.Export).Methods(http.MethodGet)
+}
+
+// Export sends archive of all supergiant records, secrets and tasks can be left out with
+// excludeSecrets and excludeTasks query parameters.
+func (h *Handler) Export(w http.ResponseWriter, r *http.Request) {
+	opts := Options{}
+	opts.ExcludeSecrets, _ = strconv.ParseBool(r.URL.Query().Get("excludeSecrets"))
+	opts.ExcludeTasks, _ = strconv.ParseBool(r.URL.Query().Get("excludeTasks"))
+
+	buf := &bytes.Buffer{}
+	if err := Export(r.Context(), h.repository, h.schemaVersion, opts, buf); err != nil {
+		logrus.Errorf("export backup: %v", err)
+		message.SendUnknownError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=supergiant-%s.json.gz",
+		time.Now().UTC().Format("20060102-150405")))
+	w.Write(buf.Bytes())
+}
@@ -0,0 +1,39 @@
+package backup
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+
+	"github.com/supergiant/supergiant/pkg/user"
+)
+
+func TestHandlerExport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sg-backup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	repository := newTestRepository(t, dir, "source.db")
+	require.NoError(t, repository.Put(context.Background(), user.DefaultStoragePrefix, "root", []byte(`{}`)))
+
+	router := mux.NewRouter()
+	NewHandler(repository, 1).Register(router)
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/backup?excludeTasks=true", nil)
+	router.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "application/gzip", rec.Header().Get("Content-Type"))
+
+	target := newTestRepository(t, dir, "target.db")
+	count, err := Import(context.Background(), target, 1, Options{}, rec.Body)
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+}
@@ -3,20 +3,24 @@ package controlplane
 import (
 	"context"
 	"fmt"
+	"io"
 	"github.com/supergiant/supergiant/pkg/workflows/steps/amazon"
 	"net/http"
+	"os"
+	"strings"
 	"time"
 
-	"github.com/coreos/etcd/clientv3"
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"github.com/supergiant/supergiant/pkg/account"
 	"github.com/supergiant/supergiant/pkg/api"
+	"github.com/supergiant/supergiant/pkg/backup"
 	"github.com/supergiant/supergiant/pkg/helm"
 	"github.com/supergiant/supergiant/pkg/jwt"
 	"github.com/supergiant/supergiant/pkg/kube"
+	"github.com/supergiant/supergiant/pkg/migrations"
 	"github.com/supergiant/supergiant/pkg/profile"
 	"github.com/supergiant/supergiant/pkg/provisioner"
 	sshRunner "github.com/supergiant/supergiant/pkg/runner/ssh"
@@ -38,16 +42,31 @@ import (
 	"github.com/supergiant/supergiant/pkg/workflows/steps/manifest"
 	"github.com/supergiant/supergiant/pkg/workflows/steps/network"
 	"github.com/supergiant/supergiant/pkg/workflows/steps/poststart"
+	"github.com/supergiant/supergiant/pkg/workflows/steps/preflight"
 	"github.com/supergiant/supergiant/pkg/workflows/steps/ssh"
 	"github.com/supergiant/supergiant/pkg/workflows/steps/tiller"
 )
 
 type Server struct {
-	server http.Server
-	cfg    *Config
+	server     http.Server
+	cfg        *Config
+	repository storage.Interface
+	janitor    *workflows.Janitor
+	// Replica that holds the leader lease runs background loops and takes over tasks of stopped replicas
+	elector     *storage.Elector
+	provisioner *provisioner.TaskProvisioner
+	cancel      context.CancelFunc
 }
 
 func (srv *Server) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	srv.cancel = cancel
+	go workflows.WatchScripts(ctx, srv.repository)
+	go srv.elector.Run(ctx, func(ctx context.Context) {
+		go srv.janitor.Run(ctx)
+		srv.provisioner.TakeOver(ctx, srv.cfg.TaskResumePolicy, srv.cfg.TaskLeaseTTL)
+	})
+
 	err := srv.server.ListenAndServe()
 	if err != nil {
 		logrus.Fatal(err)
@@ -62,15 +81,72 @@ func (srv *Server) Shutdown() {
 	if err != nil {
 		logrus.Fatal(err)
 	}
+
+	if srv.cancel != nil {
+		srv.cancel()
+	}
+
+	if err := srv.repository.Close(); err != nil {
+		logrus.Errorf("close storage: %v", err)
+	}
 }
 
+const (
+	StorageETCD = "etcd"
+	StorageFile = "file"
+)
+
+// leaderLease is the key of the lease held by the leader of control plane replicas
+const leaderLease = "leader"
+
 // Config is the server configuration
 type Config struct {
-	Port         int
-	Addr         string
-	EtcdUrl      string
-	LogLevel     string
-	TemplatesDir string
+	Port    int
+	Addr    string
+	Storage string
+	// EtcdUrl is a comma separated list of etcd endpoints
+	EtcdUrl            string
+	EtcdDialTimeout    time.Duration
+	EtcdRequestTimeout time.Duration
+	StoragePath        string
+	LogLevel           string
+	TemplatesDir       string
+	// WorkflowsDir holds YAML or JSON workflow definitions loaded on start
+	WorkflowsDir string
+
+	// EncryptionKeyFile is a path to the keyring file, EncryptionKey is a single
+	// master key in form of <key id>:<base64 key>, encryption is disabled when both are empty.
+	EncryptionKeyFile string
+	EncryptionKey     string
+	// EncryptedPrefixes are storage prefixes whose values are encrypted,
+	// DefaultEncryptedPrefixes are used when empty.
+	EncryptedPrefixes []string
+
+	TaskRetention       workflows.RetentionPolicy
+	TaskJanitorInterval time.Duration
+	// StepParallelism is the number of independent steps of a task that run at the same time
+	StepParallelism int
+	// TaskResumePolicy tells whether tasks interrupted by restart are resumed or failed
+	TaskResumePolicy provisioner.ResumePolicy
+	// MaxRunningTasks is the number of tasks that run at the same time, the rest are queued.
+	// Masters of a cluster take one place together, the rest of them run over the limit.
+	MaxRunningTasks int
+	// CloudAPIRate and CloudAPIBurst limit the rate of steps that call API of a cloud account
+	CloudAPIRate  float64
+	CloudAPIBurst int
+	// ReplicaID identifies the replica of the control plane that owns tasks it runs, host name is used when empty.
+	// Replicas that share the storage must have distinct ids.
+	ReplicaID string
+	// TaskLeaseTTL is the time tasks of the stopped replica stay owned by it before they are taken over
+	TaskLeaseTTL time.Duration
+}
+
+// DefaultEncryptedPrefixes are storage prefixes that hold cloud credentials, ssh keys and certificates
+var DefaultEncryptedPrefixes = []string{
+	account.DefaultStoragePrefix,
+	kube.DefaultStoragePrefix,
+	// Tasks keep credentials and keys in the config they have been run with
+	workflows.Prefix,
 }
 
 func New(cfg *Config) (*Server, error) {
@@ -79,38 +155,183 @@ func New(cfg *Config) (*Server, error) {
 	}
 
 	configureLogging(cfg)
-	r, err := configureApplication(cfg)
+	raw, err := newRawRepository(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := migrations.NewRunner(raw, migrations.All).Run(context.Background(), false); err != nil {
+		return nil, errors.Wrap(err, "migrate storage")
+	}
+	repository := storage.NewVersionedRepository(raw, migrations.Latest())
+
+	if cfg.ReplicaID == "" {
+		if cfg.ReplicaID, err = os.Hostname(); err != nil {
+			return nil, errors.Wrap(err, "get replica id")
+		}
+	}
+
+	janitor := workflows.NewJanitor(repository, cfg.TaskRetention, cfg.TaskJanitorInterval)
+	r, taskProvisioner, err := configureApplication(cfg, repository, raw, janitor)
 	if err != nil {
 		return nil, err
 	}
 	headersOk := handlers.AllowedHeaders([]string{"Access-Control-Request-Headers", "Authorization"})
 	methodsOk := handlers.AllowedMethods([]string{"GET", "HEAD", "POST", "PUT", "OPTIONS"})
+	exposedOk := handlers.ExposedHeaders([]string{api.ContinueHeader})
 
 	// TODO add TLS support
 	s := &Server{
-		cfg: cfg,
+		cfg:         cfg,
+		repository:  repository,
+		janitor:     janitor,
+		elector:     storage.NewElector(repository, leaderLease, cfg.ReplicaID, cfg.TaskLeaseTTL),
+		provisioner: taskProvisioner,
 		server: http.Server{
-			Handler:      handlers.CORS(headersOk, methodsOk)(handlers.RecoveryHandler(handlers.PrintRecoveryStack(true))(r)),
+			Handler:      handlers.CORS(headersOk, methodsOk, exposedOk)(handlers.RecoveryHandler(handlers.PrintRecoveryStack(true))(r)),
 			Addr:         fmt.Sprintf("%s:%d", cfg.Addr, cfg.Port),
 			ReadTimeout:  time.Second * 10,
 			WriteTimeout: time.Second * 15,
 			IdleTimeout:  time.Second * 120,
 		},
 	}
-	if err := generateUserIfColdStart(cfg); err != nil {
+	if err := generateUserIfColdStart(repository); err != nil {
 		return nil, err
 	}
 
 	return s, nil
 }
 
-//generateUserIfColdStart checks if there are any users in the db and if not (i.e. on first launch) generates a root user
-func generateUserIfColdStart(cfg *Config) error {
-	etcdCfg := clientv3.Config{
-		DialTimeout: time.Second * 10,
-		Endpoints:   []string{cfg.EtcdUrl},
+// RotateEncryptionKeys re-encrypts all secrets with the active key of the configured keyring
+func RotateEncryptionKeys(cfg *Config) (int, error) {
+	if err := validate(cfg); err != nil {
+		return 0, err
+	}
+
+	repository, err := newRawRepository(cfg)
+	if err != nil {
+		return 0, err
 	}
-	repository := storage.NewETCDRepository(etcdCfg)
+	defer repository.Close()
+
+	encrypted, ok := repository.(*storage.EncryptedRepository)
+	if !ok {
+		return 0, errors.New("encryption key is not configured")
+	}
+
+	return encrypted.Rotate(context.Background())
+}
+
+// Migrate applies pending migrations of the persisted records, in dry run mode
+// it only reports migrations that would be applied.
+func Migrate(cfg *Config, dryRun bool) ([]migrations.Result, error) {
+	if err := validate(cfg); err != nil {
+		return nil, err
+	}
+
+	repository, err := newRawRepository(cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer repository.Close()
+
+	return migrations.NewRunner(repository, migrations.All).Run(context.Background(), dryRun)
+}
+
+// Export writes archive of all supergiant records to w
+func Export(cfg *Config, opts backup.Options, w io.Writer) error {
+	if err := validate(cfg); err != nil {
+		return err
+	}
+
+	repository, err := newRawRepository(cfg)
+	if err != nil {
+		return err
+	}
+	defer repository.Close()
+
+	return backup.Export(context.Background(), repository, migrations.Latest(), opts, w)
+}
+
+// Import restores archive read from r to the empty storage, records of older
+// archives are migrated on the next start.
+func Import(cfg *Config, opts backup.Options, r io.Reader) (int, error) {
+	if err := validate(cfg); err != nil {
+		return 0, err
+	}
+
+	repository, err := newRawRepository(cfg)
+	if err != nil {
+		return 0, err
+	}
+	defer repository.Close()
+
+	return backup.Import(context.Background(), repository, migrations.Latest(), opts, r)
+}
+
+// newRawRepository creates storage backend selected in the configuration
+// and wraps it with encryption if encryption key is provided, values are
+// returned with their schema version envelope.
+func newRawRepository(cfg *Config) (storage.Interface, error) {
+	repository, err := newBackend(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var keyring *storage.Keyring
+	switch {
+	case cfg.EncryptionKeyFile != "":
+		keyring, err = storage.LoadKeyring(cfg.EncryptionKeyFile)
+	case cfg.EncryptionKey != "":
+		keyring, err = storage.ParseKeyring(cfg.EncryptionKey)
+	default:
+		return repository, nil
+	}
+
+	if err != nil {
+		return nil, errors.Wrap(err, "encryption key")
+	}
+
+	prefixes := cfg.EncryptedPrefixes
+	if len(prefixes) == 0 {
+		prefixes = DefaultEncryptedPrefixes
+	}
+
+	return storage.NewEncryptedRepository(repository, keyring, prefixes), nil
+}
+
+func newBackend(cfg *Config) (storage.Interface, error) {
+	switch cfg.Storage {
+	case StorageFile:
+		return storage.NewFileRepository(cfg.StoragePath)
+	default:
+		dialTimeout := cfg.EtcdDialTimeout
+		if dialTimeout <= 0 {
+			dialTimeout = time.Second * 10
+		}
+
+		etcdCfg := storage.ETCDConfig{
+			Endpoints:      etcdEndpoints(cfg.EtcdUrl),
+			DialTimeout:    dialTimeout,
+			RequestTimeout: cfg.EtcdRequestTimeout,
+		}
+		return storage.NewETCDRepository(etcdCfg), nil
+	}
+}
+
+func etcdEndpoints(urls string) []string {
+	endpoints := make([]string, 0)
+	for _, url := range strings.Split(urls, ",") {
+		if url = strings.TrimSpace(url); url != "" {
+			endpoints = append(endpoints, url)
+		}
+	}
+
+	return endpoints
+}
+
+//generateUserIfColdStart checks if there are any users in the db and if not (i.e. on first launch) generates a root user
+func generateUserIfColdStart(repository storage.Interface) error {
 	userService := user.NewService(user.DefaultStoragePrefix, repository)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -137,30 +358,41 @@ func generateUserIfColdStart(cfg *Config) error {
 }
 
 func validate(cfg *Config) error {
-	if cfg.EtcdUrl == "" {
-		return errors.New("etcd url can't be empty")
-	}
+	switch cfg.Storage {
+	case StorageETCD, "":
+		endpoints := etcdEndpoints(cfg.EtcdUrl)
+		if len(endpoints) == 0 {
+			return errors.New("etcd url can't be empty")
+		}
 
-	if err := assert.CheckETCD(cfg.EtcdUrl); err != nil {
-		return errors.Wrapf(err, "etcd url %s", cfg.EtcdUrl)
+		for _, endpoint := range endpoints {
+			if err := assert.CheckETCD(endpoint); err != nil {
+				return errors.Wrapf(err, "etcd url %s", endpoint)
+			}
+		}
+	case StorageFile:
+		if cfg.StoragePath == "" {
+			return errors.New("storage path can't be empty")
+		}
+	default:
+		return errors.Errorf("unknown storage %s", cfg.Storage)
 	}
 
 	if cfg.Port <= 0 {
 		return errors.New("port can't be negative")
 	}
 
+	if cfg.TaskLeaseTTL <= 0 {
+		return errors.New("task lease ttl must be positive")
+	}
+
 	return nil
 }
 
-func configureApplication(cfg *Config) (*mux.Router, error) {
-	//TODO will work for now, but we should revisit ETCD configuration later
-	etcdCfg := clientv3.Config{
-		Endpoints: []string{cfg.EtcdUrl},
-	}
+func configureApplication(cfg *Config, repository, raw storage.Interface, janitor *workflows.Janitor) (*mux.Router, *provisioner.TaskProvisioner, error) {
 	router := mux.NewRouter()
 
 	protectedAPI := router.PathPrefix("/v1/api").Subrouter()
-	repository := storage.NewETCDRepository(etcdCfg)
 
 	accountService := account.NewService(account.DefaultStoragePrefix, repository)
 	accountHandler := account.NewHandler(accountService)
@@ -181,7 +413,7 @@ func configureApplication(cfg *Config) (*mux.Router, error) {
 
 	// Read templates first and then initialize workflows with steps that uses these templates
 	if err := templatemanager.Init(cfg.TemplatesDir); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	digitalocean.Init()
 	certificates.Init()
@@ -195,18 +427,38 @@ func configureApplication(cfg *Config) (*mux.Router, error) {
 	tiller.Init()
 	etcd.Init()
 	ssh.Init()
+	preflight.Init()
 	network.Init()
 	clustercheck.Init()
 	amazon.InitCreateKeyPair()
 	amazon.InitStepCreateInstance()
-	workflows.Init()
+	workflows.SetParallelism(cfg.StepParallelism)
+	workflows.SetMaxRunningTasks(cfg.MaxRunningTasks)
+	workflows.SetCloudRateLimit(cfg.CloudAPIRate, cfg.CloudAPIBurst)
+	workflows.SetOwner(cfg.ReplicaID)
+	workflows.SetLeaseTTL(cfg.TaskLeaseTTL)
+	if err := workflows.Init(); err != nil {
+		return nil, nil, errors.Wrap(err, "init workflows")
+	}
+	// Workflow definitions may use script steps
+	if err := workflows.LoadScripts(context.Background(), repository); err != nil {
+		return nil, nil, errors.Wrap(err, "load scripts")
+	}
+	if err := workflows.LoadWorkflows(cfg.WorkflowsDir); err != nil {
+		return nil, nil, errors.Wrap(err, "load workflows")
+	}
 
 	taskHandler := workflows.NewTaskHandler(repository, sshRunner.NewRunner, accountService)
 	taskHandler.Register(router)
+	taskHandler.RegisterScripts(protectedAPI)
 
 	kubeService := kube.NewService(kube.DefaultStoragePrefix, repository)
 
 	taskProvisioner := provisioner.NewProvisioner(repository, kubeService)
+	if err := taskProvisioner.ResumeInterrupted(context.Background(), cfg.TaskResumePolicy); err != nil {
+		return nil, nil, errors.Wrap(err, "resume interrupted tasks")
+	}
+
 	tokenGetter := provisioner.NewEtcdTokenGetter()
 	provisionHandler := provisioner.NewHandler(accountService, tokenGetter, taskProvisioner)
 	provisionHandler.Register(protectedAPI)
@@ -218,13 +470,18 @@ func configureApplication(cfg *Config) (*mux.Router, error) {
 	helmHandler := helm.NewHandler(helmService)
 	helmHandler.Register(protectedAPI)
 
+	backupHandler := backup.NewHandler(raw, migrations.Latest())
+	backupHandler.Register(protectedAPI)
+
+	janitor.Register(protectedAPI)
+
 	authMiddleware := api.Middleware{
 		TokenService: jwtService,
 		UserService:  userService,
 	}
 	protectedAPI.Use(authMiddleware.AuthMiddleware, api.ContentTypeJSON)
 
-	return router, nil
+	return router, taskProvisioner, nil
 }
 
 func configureLogging(cfg *Config) {
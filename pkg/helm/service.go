@@ -12,7 +12,7 @@ import (
 )
 
 const (
-	prefix = "/helm/repositories/"
+	DefaultStoragePrefix = "/helm/repositories/"
 )
 
 // Service manages helm repositories.
@@ -38,7 +38,7 @@ func (s *Service) Create(ctx context.Context, r *helm.Repository) error {
 		return errors.Wrap(err, "marshal")
 	}
 
-	err = s.storage.Put(ctx, prefix, r.Name, rawJSON)
+	err = s.storage.Put(ctx, DefaultStoragePrefix, r.Name, rawJSON)
 	if err != nil {
 		return errors.Wrap(err, "storage")
 	}
@@ -48,7 +48,7 @@ func (s *Service) Create(ctx context.Context, r *helm.Repository) error {
 
 // Get retrieves a helm repository from the storage by its name.
 func (s *Service) Get(ctx context.Context, repoName string) (*helm.Repository, error) {
-	res, err := s.storage.Get(ctx, prefix, repoName)
+	res, err := s.storage.Get(ctx, DefaultStoragePrefix, repoName)
 	if err != nil {
 		return nil, errors.Wrap(err, "storage")
 	}
@@ -67,7 +67,7 @@ func (s *Service) Get(ctx context.Context, repoName string) (*helm.Repository, e
 
 // GetAll retrieves all helm repositories from the storage.
 func (s *Service) GetAll(ctx context.Context) ([]helm.Repository, error) {
-	rawRepos, err := s.storage.GetAll(ctx, prefix)
+	rawRepos, err := s.storage.GetAll(ctx, DefaultStoragePrefix)
 	if err != nil {
 		return nil, errors.Wrap(err, "storage")
 	}
@@ -87,5 +87,5 @@ func (s *Service) GetAll(ctx context.Context) ([]helm.Repository, error) {
 
 // Delete removes a helm repository from the storage by its name.
 func (s *Service) Delete(ctx context.Context, repoName string) error {
-	return s.storage.Delete(ctx, prefix, repoName)
+	return s.storage.Delete(ctx, DefaultStoragePrefix, repoName)
 }
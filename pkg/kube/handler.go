@@ -13,6 +13,7 @@ import (
 
 	"fmt"
 	"github.com/sirupsen/logrus"
+	"github.com/supergiant/supergiant/pkg/api"
 	"github.com/supergiant/supergiant/pkg/clouds"
 	"github.com/supergiant/supergiant/pkg/message"
 	"github.com/supergiant/supergiant/pkg/model"
@@ -87,15 +88,27 @@ func (h *Handler) getTasks(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tasks, err := h.getKubeTasks(r.Context(), id)
+	page, err := api.ParsePage(r)
+	if err != nil {
+		message.SendValidationFailed(w, err)
+		return
+	}
+
+	tasks, next, err := workflows.ClusterTasks(r.Context(), h.repo, id, page.Limit, page.Continue)
 
 	if err != nil {
+		if sgerrors.IsInvalidContinue(err) {
+			message.SendValidationFailed(w, err)
+			return
+		}
+
 		if sgerrors.IsNotFound(err) {
 			message.SendNotFound(w, id, err)
 			return
 		}
 
 		message.SendUnknownError(w, err)
+		return
 	}
 
 	if len(tasks) == 0 {
@@ -103,23 +116,12 @@ func (h *Handler) getTasks(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	type taskDTO struct {
-		ID           string                 `json:"id"`
-		Type         string                 `json:"type"`
-		Status       steps.Status           `json:"status"`
-		StepStatuses []workflows.StepStatus `json:"stepsStatuses"`
-	}
-
-	resp := make([]taskDTO, 0, len(tasks))
-
+	resp := make([]workflows.TaskDTO, 0, len(tasks))
 	for _, task := range tasks {
-		resp = append(resp, taskDTO{
-			ID:           task.ID,
-			Type:         task.Type,
-			Status:       task.Status,
-			StepStatuses: task.StepStatuses,
-		})
+		resp = append(resp, workflows.NewTaskDTO(task))
 	}
+
+	api.SetContinue(w, next)
 	if err := json.NewEncoder(w).Encode(resp); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
@@ -168,12 +170,35 @@ func (h *Handler) getKube(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) listKubes(w http.ResponseWriter, r *http.Request) {
-	kubes, err := h.svc.ListAll(r.Context())
+	page, err := api.ParsePage(r)
 	if err != nil {
+		message.SendValidationFailed(w, err)
+		return
+	}
+
+	var (
+		kubes []model.Kube
+		next  string
+	)
+
+	if page.Paginated() {
+		kubes, next, err = h.svc.ListPage(r.Context(), page.Limit, page.Continue)
+	} else {
+		kubes, err = h.svc.ListAll(r.Context())
+	}
+
+	if err != nil {
+		if sgerrors.IsInvalidContinue(err) {
+			message.SendValidationFailed(w, err)
+			return
+		}
+
 		message.SendUnknownError(w, err)
 		return
 	}
 
+	api.SetContinue(w, next)
+
 	if err = json.NewEncoder(w).Encode(kubes); err != nil {
 		message.SendUnknownError(w, err)
 	}
@@ -248,13 +273,10 @@ func (h *Handler) deleteKube(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		// Finally delete cluster record from etcd
-		if err := h.svc.Delete(context.Background(), kname); err != nil {
+		// Finally delete cluster record and its tasks from etcd
+		if err := h.deleteCluster(context.Background(), kname); err != nil {
 			logrus.Errorf("delete kube %s caused %v", kname, err)
-			return
 		}
-
-		h.deleteClusterTasks(context.Background(), kname)
 	}(t)
 
 	w.WriteHeader(http.StatusAccepted)
@@ -512,11 +534,12 @@ func (h *Handler) deleteNode(w http.ResponseWriter, r *http.Request) {
 			logrus.Errorf("delete node %s from cluster %s caused %v", nodeName, kname, err)
 		}
 
-		// Delete node from cluster object
-		delete(k.Nodes, nodeName)
-		// Save cluster object to etcd
+		// Delete node from the latest version of cluster object
 		logrus.Infof("delete node %s from cluster %s", nodeName, kname)
-		err = h.svc.Create(context.Background(), k)
+		err = h.svc.Update(context.Background(), kname, func(k *model.Kube) error {
+			delete(k.Nodes, nodeName)
+			return nil
+		})
 
 		if err != nil {
 			logrus.Errorf("update cluster %s caused %v", kname, err)
@@ -527,40 +550,29 @@ func (h *Handler) deleteNode(w http.ResponseWriter, r *http.Request) {
 
 // TODO(stgleb): Create separte task service to manage task object lifecycle
 func (h *Handler) getKubeTasks(ctx context.Context, kubeName string) ([]*workflows.Task, error) {
-	data, err := h.repo.GetAll(ctx, workflows.Prefix)
+	tasks, _, err := workflows.ClusterTasks(ctx, h.repo, kubeName, 0, "")
 
 	if err != nil {
 		return nil, errors.Wrap(err, "get cluster tasks")
 	}
 
-	tasks := make([]*workflows.Task, 0)
-	for _, v := range data {
-		task := &workflows.Task{}
-		err := json.Unmarshal(v, task)
-		if err != nil {
-			return nil, errors.Wrap(err, "unmarshal task data")
-		}
-
-		if task != nil && task.Config != nil && task.Config.ClusterName == kubeName {
-			tasks = append(tasks, task)
-		}
-	}
-
 	return tasks, nil
 }
 
-func (h *Handler) deleteClusterTasks(ctx context.Context, clusterName string) error {
+// deleteCluster removes cluster record with its tasks index in one transaction, tasks
+// that don't fit into it are removed in the following ones.
+func (h *Handler) deleteCluster(ctx context.Context, clusterName string) error {
 	tasks, err := h.getKubeTasks(ctx, clusterName)
 
 	if err != nil {
 		return errors.Wrap(err, fmt.Sprintf("delete cluster %s tasks", clusterName))
 	}
 
+	ops := make([]storage.Op, 0, len(tasks)+1)
+	ops = append(ops, workflows.DeleteClusterIndexOp(clusterName))
 	for _, task := range tasks {
-		if err := h.repo.Delete(ctx, workflows.Prefix, task.ID); err != nil {
-			logrus.Warnf("delete task %s: %v", task.ID, err)
-		}
+		ops = append(ops, storage.DeleteOp(workflows.Prefix, task.ID))
 	}
 
-	return nil
+	return h.svc.DeleteTx(ctx, clusterName, ops...)
 }
@@ -14,12 +14,14 @@ import (
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 
+	"github.com/supergiant/supergiant/pkg/api"
 	"github.com/supergiant/supergiant/pkg/clouds"
 	"github.com/supergiant/supergiant/pkg/message"
 	"github.com/supergiant/supergiant/pkg/model"
 	"github.com/supergiant/supergiant/pkg/node"
 	"github.com/supergiant/supergiant/pkg/profile"
 	"github.com/supergiant/supergiant/pkg/sgerrors"
+	"github.com/supergiant/supergiant/pkg/storage"
 	"github.com/supergiant/supergiant/pkg/testutils"
 	"github.com/supergiant/supergiant/pkg/workflows"
 	"github.com/supergiant/supergiant/pkg/workflows/steps"
@@ -52,6 +54,8 @@ const (
 	serviceGet               = "Get"
 	serviceListAll           = "ListAll"
 	serviceDelete            = "Delete"
+	serviceDeleteTx          = "DeleteTx"
+	serviceUpdate            = "Update"
 	serviceListKubeResources = "ListKubeResources"
 	serviceGetKubeResources  = "GetKubeResources"
 )
@@ -73,6 +77,10 @@ func (m *kubeServiceMock) Create(ctx context.Context, k *model.Kube) error {
 	}
 	return val
 }
+func (m *kubeServiceMock) CreateTx(ctx context.Context, k *model.Kube, ops ...storage.Op) error {
+	args := m.Called(ctx, k, ops)
+	return args.Error(0)
+}
 func (m *kubeServiceMock) Get(ctx context.Context, name string) (*model.Kube, error) {
 	args := m.Called(ctx, name)
 	val, ok := args.Get(0).(*model.Kube)
@@ -81,6 +89,10 @@ func (m *kubeServiceMock) Get(ctx context.Context, name string) (*model.Kube, er
 	}
 	return val, args.Error(1)
 }
+func (m *kubeServiceMock) Update(ctx context.Context, name string, fn func(*model.Kube) error) error {
+	args := m.Called(ctx, name, fn)
+	return args.Error(0)
+}
 func (m *kubeServiceMock) ListAll(ctx context.Context) ([]model.Kube, error) {
 	args := m.Called(ctx)
 	val, ok := args.Get(0).([]model.Kube)
@@ -89,10 +101,22 @@ func (m *kubeServiceMock) ListAll(ctx context.Context) ([]model.Kube, error) {
 	}
 	return val, args.Error(1)
 }
+func (m *kubeServiceMock) ListPage(ctx context.Context, limit int, continueToken string) ([]model.Kube, string, error) {
+	args := m.Called(ctx, limit, continueToken)
+	val, ok := args.Get(0).([]model.Kube)
+	if !ok {
+		return nil, args.String(1), args.Error(2)
+	}
+	return val, args.String(1), args.Error(2)
+}
 func (m *kubeServiceMock) Delete(ctx context.Context, name string) error {
 	args := m.Called(ctx, name)
 	return args.Error(0)
 }
+func (m *kubeServiceMock) DeleteTx(ctx context.Context, name string, ops ...storage.Op) error {
+	args := m.Called(ctx, name, ops)
+	return args.Error(0)
+}
 func (m *kubeServiceMock) ListKubeResources(ctx context.Context, kname string) ([]byte, error) {
 	args := m.Called(ctx, kname)
 	val, ok := args.Get(0).([]byte)
@@ -320,6 +344,31 @@ func TestHandler_listKubes(t *testing.T) {
 	}
 }
 
+func TestHandler_listKubesPage(t *testing.T) {
+	svc := new(kubeServiceMock)
+	svc.On("ListPage", mock.Anything, 1, "").
+		Return([]model.Kube{{Name: "first"}}, "next", nil)
+	svc.On("ListPage", mock.Anything, 1, "invalid").
+		Return(nil, "", sgerrors.ErrInvalidContinue)
+
+	router := mux.NewRouter().SkipClean(true)
+	NewHandler(svc, nil, nil, nil).Register(router)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/kubes?limit=1", nil))
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, "next", rr.Header().Get(api.ContinueHeader))
+
+	kubes := make([]model.Kube, 0)
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&kubes))
+	require.Equal(t, []model.Kube{{Name: "first"}}, kubes)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/kubes?limit=1&continue=invalid", nil))
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
 func TestHandler_deleteKube(t *testing.T) {
 	tcs := []struct {
 		description string
@@ -401,7 +450,7 @@ func TestHandler_deleteKube(t *testing.T) {
 		require.Equalf(t, nil, err, "TC#%d: create request: %v", i+1, err)
 
 		svc.On(serviceGet, mock.Anything, tc.kubeName).Return(tc.kube, tc.getKubeError)
-		svc.On(serviceDelete, mock.Anything, tc.kubeName).Return(tc.deleteKubeError)
+		svc.On(serviceDeleteTx, mock.Anything, tc.kubeName, mock.Anything).Return(tc.deleteKubeError)
 
 		accSvc.On(serviceGet, mock.Anything, tc.accountName).Return(tc.account, tc.getAccountError)
 		mockRepo := new(testutils.MockStorage)
@@ -409,8 +458,9 @@ func TestHandler_deleteKube(t *testing.T) {
 			mock.Anything, mock.Anything).Return(nil)
 		mockRepo.On("Delete", mock.Anything,
 			mock.Anything, mock.Anything).Return(nil)
-		mockRepo.On("GetAll", mock.Anything,
-			mock.Anything).Return([][]byte{}, nil)
+		mockRepo.On(testutils.StoragePage, mock.Anything, mock.Anything,
+			mock.Anything, mock.Anything).Return([]storage.KeyValue{}, "", nil)
+		mockRepo.On(testutils.StorageTxn, mock.Anything, mock.Anything).Return(nil)
 
 		workflows.Init()
 		workflows.RegisterWorkFlow(workflows.DigitalOceanDeleteCluster, []steps.Step{})
@@ -802,7 +852,7 @@ func TestDeleteNodeFromKube(t *testing.T) {
 		svc := new(kubeServiceMock)
 		svc.On(serviceGet, mock.Anything, testCase.kubeName).
 			Return(testCase.kube, testCase.kubeServiceErr)
-		svc.On(serviceCreate, mock.Anything, testCase.kube).Return(mock.Anything)
+		svc.On(serviceUpdate, mock.Anything, testCase.kubeName, mock.Anything).Return(nil)
 
 		accService := new(accServiceMock)
 		accService.On("Get", mock.Anything, testCase.accountName).
@@ -811,6 +861,7 @@ func TestDeleteNodeFromKube(t *testing.T) {
 		mockRepo := new(testutils.MockStorage)
 		mockRepo.On("Put", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
 			Return(nil)
+		mockRepo.On(testutils.StorageTxn, mock.Anything, mock.Anything).Return(nil)
 
 		mockRepo.On("Delete", mock.Anything, mock.Anything, mock.Anything).
 			Return(nil)
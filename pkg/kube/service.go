@@ -17,12 +17,19 @@ import (
 
 const DefaultStoragePrefix = "/supergiant/kube/"
 
+// txnBatchSize keeps transactions below the etcd limit of operations per transaction
+const txnBatchSize = 100
+
 // Interface represents an interface for a kube service.
 type Interface interface {
 	Create(ctx context.Context, k *model.Kube) error
+	CreateTx(ctx context.Context, k *model.Kube, ops ...storage.Op) error
 	Get(ctx context.Context, name string) (*model.Kube, error)
+	Update(ctx context.Context, name string, fn func(*model.Kube) error) error
 	ListAll(ctx context.Context) ([]model.Kube, error)
+	ListPage(ctx context.Context, limit int, continueToken string) ([]model.Kube, string, error)
 	Delete(ctx context.Context, name string) error
+	DeleteTx(ctx context.Context, name string, ops ...storage.Op) error
 	ListKubeResources(ctx context.Context, kname string) ([]byte, error)
 	GetKubeResources(ctx context.Context, kname, resource, ns, name string) ([]byte, error)
 	GetCerts(ctx context.Context, kname, cname string) (*Bundle, error)
@@ -62,6 +69,16 @@ func (s *Service) Create(ctx context.Context, k *model.Kube) error {
 	return nil
 }
 
+// CreateTx stores a kube together with additional operations, see txnBatches.
+func (s *Service) CreateTx(ctx context.Context, k *model.Kube, ops ...storage.Op) error {
+	raw, err := json.Marshal(k)
+	if err != nil {
+		return errors.Wrap(err, "marshal")
+	}
+
+	return s.txnBatches(ctx, storage.PutOp(s.prefix, k.Name, raw), ops)
+}
+
 // Get returns a kube with a specified name.
 func (s *Service) Get(ctx context.Context, name string) (*model.Kube, error) {
 	raw, err := s.storage.Get(ctx, s.prefix, name)
@@ -80,6 +97,37 @@ func (s *Service) Get(ctx context.Context, name string) (*model.Kube, error) {
 	return k, nil
 }
 
+// Update applies fn to the latest stored version of a kube, fn may be called
+// several times if the kube is modified concurrently.
+func (s *Service) Update(ctx context.Context, name string, fn func(*model.Kube) error) error {
+	err := s.storage.Update(ctx, s.prefix, name, func(current []byte) ([]byte, error) {
+		if current == nil {
+			return nil, sgerrors.ErrNotFound
+		}
+
+		k := &model.Kube{}
+		if err := json.Unmarshal(current, k); err != nil {
+			return nil, errors.Wrap(err, "unmarshal")
+		}
+
+		if err := fn(k); err != nil {
+			return nil, err
+		}
+
+		raw, err := json.Marshal(k)
+		if err != nil {
+			return nil, errors.Wrap(err, "marshal")
+		}
+
+		return raw, nil
+	})
+	if err != nil {
+		return errors.Wrap(err, "storage: update")
+	}
+
+	return nil
+}
+
 // ListAll returns all kubes.
 func (s *Service) ListAll(ctx context.Context) ([]model.Kube, error) {
 	rawKubes, err := s.storage.GetAll(ctx, s.prefix)
@@ -99,11 +147,62 @@ func (s *Service) ListAll(ctx context.Context) ([]model.Kube, error) {
 	return kubes, nil
 }
 
+// ListPage returns a page of kubes sorted by name and the continue token of the next page.
+func (s *Service) ListPage(ctx context.Context, limit int, continueToken string) ([]model.Kube, string, error) {
+	kvs, next, err := s.storage.Page(ctx, s.prefix, limit, continueToken)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "storage: page")
+	}
+
+	kubes := make([]model.Kube, len(kvs))
+	for i, kv := range kvs {
+		k := model.Kube{}
+		if err = json.Unmarshal(kv.Value, &k); err != nil {
+			return nil, "", errors.Wrap(err, "unmarshal")
+		}
+		kubes[i] = k
+	}
+
+	return kubes, next, nil
+}
+
 // Delete deletes a kube with a specified name.
 func (s *Service) Delete(ctx context.Context, name string) error {
 	return s.storage.Delete(ctx, s.prefix, name)
 }
 
+// DeleteTx deletes a kube together with additional operations, see txnBatches.
+func (s *Service) DeleteTx(ctx context.Context, name string, ops ...storage.Op) error {
+	return s.txnBatches(ctx, storage.DeleteOp(s.prefix, name), ops)
+}
+
+// txnBatches applies the operation of the kube in one transaction with the first
+// operations of ops, the rest of them are applied in the following transactions.
+func (s *Service) txnBatches(ctx context.Context, kubeOp storage.Op, ops []storage.Op) error {
+	n := txnBatchSize - 1
+	if len(ops) < n {
+		n = len(ops)
+	}
+	batch := append([]storage.Op{kubeOp}, ops[:n]...)
+	ops = ops[n:]
+
+	for {
+		if err := s.storage.Txn(ctx, batch...); err != nil {
+			return errors.Wrap(err, "storage: txn")
+		}
+
+		if len(ops) == 0 {
+			return nil
+		}
+
+		n = txnBatchSize
+		if len(ops) < n {
+			n = len(ops)
+		}
+		batch, ops = ops[:n], ops[n:]
+	}
+}
+
 // ListKubeResources returns raw representation of the supported kubernetes resources.
 func (s *Service) ListKubeResources(ctx context.Context, kname string) ([]byte, error) {
 	kube, err := s.Get(ctx, kname)
@@ -3,11 +3,16 @@ package kube
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/pkg/errors"
+	"github.com/stretchr/testify/mock"
 
 	"github.com/supergiant/supergiant/pkg/model"
+	"github.com/supergiant/supergiant/pkg/sgerrors"
+	"github.com/supergiant/supergiant/pkg/storage"
 	"github.com/supergiant/supergiant/pkg/testutils"
 )
 
@@ -87,6 +92,61 @@ func TestKubeServiceCreate(t *testing.T) {
 	}
 }
 
+func TestKubeServiceUpdate(t *testing.T) {
+	testCases := []struct {
+		current  []byte
+		expected string
+		err      error
+	}{
+		{
+			current:  []byte(`{"name":"kube-name-1234","state":"provisioning"}`),
+			expected: `{"state":"operational","name":"kube-name-1234"`,
+		},
+		{
+			current: nil,
+			err:     sgerrors.ErrNotFound,
+		},
+	}
+
+	prefix := DefaultStoragePrefix
+
+	for _, testCase := range testCases {
+		var written []byte
+
+		m := new(testutils.MockStorage)
+		m.On("Update", context.Background(), prefix, "kube-name-1234", mock.Anything).
+			Return(func(fn storage.UpdateFunc) error {
+				var err error
+				written, err = fn(testCase.current)
+				return err
+			})
+
+		service := NewService(prefix, m)
+
+		err := service.Update(context.Background(), "kube-name-1234", func(k *model.Kube) error {
+			k.State = model.StateOperational
+			return nil
+		})
+
+		if testCase.err == nil && err != nil {
+			t.Errorf("Unexpected error %v", err)
+			return
+		}
+
+		if testCase.err != nil && !sgerrors.IsNotFound(err) {
+			t.Errorf("Wrong error expected %v actual %v", testCase.err, err)
+		}
+
+		if testCase.err == nil && !strings.HasPrefix(string(written), testCase.expected) {
+			t.Errorf("Wrong kube written expected %s actual %s", testCase.expected, string(written))
+		}
+
+		if testCase.err != nil && written != nil {
+			t.Errorf("Kube must not be written when it does not exist")
+		}
+	}
+}
+
 func TestKubeServiceGetAll(t *testing.T) {
 	testCases := []struct {
 		data [][]byte
@@ -122,3 +182,67 @@ func TestKubeServiceGetAll(t *testing.T) {
 		}
 	}
 }
+
+func TestKubeServiceDeleteTx(t *testing.T) {
+	prefix := DefaultStoragePrefix
+	taskOp := storage.DeleteOp("tasks", "1234")
+
+	m := new(testutils.MockStorage)
+	m.On("Txn", context.Background(), []storage.Op{
+		storage.DeleteOp(prefix, "kube-name-1234"),
+		taskOp,
+	}).Return(nil)
+
+	service := NewService(prefix, m)
+
+	if err := service.DeleteTx(context.Background(), "kube-name-1234", taskOp); err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+
+	m.AssertExpectations(t)
+}
+
+func TestKubeServiceCreateTxBatches(t *testing.T) {
+	prefix := DefaultStoragePrefix
+	ops := make([]storage.Op, 0, 250)
+	for i := 0; i < 250; i++ {
+		ops = append(ops, storage.PutOp("tasks", fmt.Sprintf("%d", i), nil))
+	}
+
+	var batches [][]storage.Op
+	m := new(testutils.MockStorage)
+	m.On("Txn", context.Background(), mock.Anything).
+		Return(nil).
+		Run(func(args mock.Arguments) {
+			batches = append(batches, args.Get(1).([]storage.Op))
+		})
+
+	service := NewService(prefix, m)
+
+	if err := service.CreateTx(context.Background(), &model.Kube{Name: "kube-name-1234"}, ops...); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		return
+	}
+
+	if len(batches) != 3 {
+		t.Errorf("Wrong number of transactions expected 3 actual %d", len(batches))
+		return
+	}
+
+	// Kube is saved with the first batch
+	if batches[0][0].Prefix != prefix || len(batches[0]) != txnBatchSize {
+		t.Errorf("Wrong first transaction %v", batches[0][0])
+	}
+
+	total := 0
+	for _, batch := range batches {
+		if len(batch) > txnBatchSize {
+			t.Errorf("Transaction of %d operations is too large", len(batch))
+		}
+		total += len(batch)
+	}
+
+	if total != len(ops)+1 {
+		t.Errorf("Wrong number of operations expected %d actual %d", len(ops)+1, total)
+	}
+}
@@ -0,0 +1,223 @@
+package migrations
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/supergiant/supergiant/pkg/storage"
+)
+
+// Prefix is a storage prefix where applied migrations are recorded
+const Prefix = "/supergiant/migrations/"
+
+// Migration converts records of the prefixes from the previous schema version to Version
+type Migration struct {
+	Version  int
+	Name     string
+	Prefixes []string
+	// Migrate gets data of the record and returns data in the schema of Version
+	Migrate func(key string, data []byte) ([]byte, error)
+	// Apply is used instead of Migrate by migrations that do not transform records one by one,
+	// e.g. build an index, it returns the number of records that have been or would be written.
+	Apply func(ctx context.Context, repository storage.Interface, dryRun bool) (int, error)
+}
+
+// Applied is a record of the migration that has been applied to the storage
+type Applied struct {
+	Version   int       `json:"version"`
+	Name      string    `json:"name"`
+	Records   int       `json:"records"`
+	AppliedAt time.Time `json:"appliedAt"`
+}
+
+// Result describes migration that has been run, in dry run mode Records
+// is the number of records that would be changed.
+type Result struct {
+	Version int
+	Name    string
+	Records int
+}
+
+// Runner applies pending migrations in the order of their versions
+type Runner struct {
+	repository storage.Interface
+	migrations []Migration
+}
+
+// errSkip means that record has been removed while being migrated
+var errSkip = errors.New("record has been removed")
+
+// NewRunner creates runner of migrations, repository must store records
+// as is, without stripping schema version envelope.
+func NewRunner(repository storage.Interface, migrations []Migration) *Runner {
+	sorted := make([]Migration, len(migrations))
+	copy(sorted, migrations)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Version < sorted[j].Version
+	})
+
+	return &Runner{
+		repository: repository,
+		migrations: sorted,
+	}
+}
+
+// Latest returns the schema version records have after all migrations are applied
+func (r *Runner) Latest() int {
+	if len(r.migrations) == 0 {
+		return 0
+	}
+
+	return r.migrations[len(r.migrations)-1].Version
+}
+
+// Run applies all migrations that have not been applied yet,
+// in dry run mode storage is left untouched.
+func (r *Runner) Run(ctx context.Context, dryRun bool) ([]Result, error) {
+	applied, err := r.Applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	done := make(map[int]bool, len(applied))
+	for _, a := range applied {
+		if a.Version > r.Latest() {
+			return nil, errors.Errorf("storage schema version %d is newer than supported %d",
+				a.Version, r.Latest())
+		}
+		done[a.Version] = true
+	}
+
+	results := make([]Result, 0)
+	for _, m := range r.migrations {
+		if done[m.Version] {
+			continue
+		}
+
+		var count int
+		if m.Apply != nil {
+			count, err = m.Apply(ctx, r.repository, dryRun)
+		} else {
+			count, err = r.migrate(ctx, m, dryRun)
+		}
+		if err != nil {
+			return results, errors.Wrapf(err, "migration %d %s", m.Version, m.Name)
+		}
+
+		results = append(results, Result{
+			Version: m.Version,
+			Name:    m.Name,
+			Records: count,
+		})
+
+		if dryRun {
+			logrus.Infof("migration %d %s would change %d records", m.Version, m.Name, count)
+			continue
+		}
+
+		if err := r.record(ctx, m, count); err != nil {
+			return results, errors.Wrapf(err, "record migration %d %s", m.Version, m.Name)
+		}
+		logrus.Infof("migration %d %s has changed %d records", m.Version, m.Name, count)
+	}
+
+	return results, nil
+}
+
+// Applied returns migrations that have been applied to the storage
+func (r *Runner) Applied(ctx context.Context) ([]Applied, error) {
+	values, err := r.repository.GetAll(ctx, Prefix)
+	if err != nil {
+		return nil, errors.Wrap(err, "read applied migrations")
+	}
+
+	applied := make([]Applied, 0, len(values))
+	for _, v := range values {
+		_, data := storage.UnwrapVersion(v)
+
+		a := Applied{}
+		if err := json.Unmarshal(data, &a); err != nil {
+			return nil, errors.Wrap(err, "unmarshal applied migration")
+		}
+		applied = append(applied, a)
+	}
+
+	return applied, nil
+}
+
+func (r *Runner) migrate(ctx context.Context, m Migration, dryRun bool) (int, error) {
+	count := 0
+
+	for _, prefix := range m.Prefixes {
+		kvs, err := r.repository.List(ctx, prefix)
+		if err != nil {
+			return count, errors.Wrapf(err, "list %s", prefix)
+		}
+
+		for _, kv := range kvs {
+			if version, _ := storage.UnwrapVersion(kv.Value); version >= m.Version {
+				continue
+			}
+
+			if dryRun {
+				// Make sure that migration does not fail on the existing data
+				_, data := storage.UnwrapVersion(kv.Value)
+				if _, err := m.Migrate(kv.Key, data); err != nil {
+					return count, errors.Wrapf(err, "migrate %s", kv.Key)
+				}
+				count++
+				continue
+			}
+
+			err := r.repository.Update(ctx, "", kv.Key, func(current []byte) ([]byte, error) {
+				if current == nil {
+					return nil, errSkip
+				}
+
+				version, data := storage.UnwrapVersion(current)
+				if version >= m.Version {
+					return current, nil
+				}
+
+				data, err := m.Migrate(kv.Key, data)
+				if err != nil {
+					return nil, err
+				}
+
+				return storage.WrapVersion(m.Version, data)
+			})
+
+			if err == errSkip {
+				continue
+			}
+
+			if err != nil {
+				return count, errors.Wrapf(err, "migrate %s", kv.Key)
+			}
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+func (r *Runner) record(ctx context.Context, m Migration, count int) error {
+	data, err := json.Marshal(&Applied{
+		Version:   m.Version,
+		Name:      m.Name,
+		Records:   count,
+		AppliedAt: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	// Zero padded key keeps applied migrations sorted by version
+	return r.repository.Put(ctx, Prefix, fmt.Sprintf("%06d", m.Version), data)
+}
@@ -0,0 +1,156 @@
+package migrations
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/supergiant/supergiant/pkg/storage"
+	"github.com/supergiant/supergiant/pkg/workflows"
+)
+
+func newTestRepository(t *testing.T) (storage.Interface, func()) {
+	dir, err := ioutil.TempDir("", "sg-migrations")
+	require.NoError(t, err)
+
+	r, err := storage.NewFileRepository(path.Join(dir, "supergiant.db"))
+	require.NoError(t, err)
+
+	return r, func() {
+		os.RemoveAll(dir)
+	}
+}
+
+func testMigrations() []Migration {
+	return []Migration{
+		{
+			Version:  2,
+			Name:     "rename",
+			Prefixes: []string{"/kube/"},
+			Migrate: func(key string, data []byte) ([]byte, error) {
+				return bytes.Replace(data, []byte("node"), []byte("machine"), -1), nil
+			},
+		},
+		{
+			Version:  1,
+			Name:     "envelope",
+			Prefixes: []string{"/kube/"},
+			Migrate: func(key string, data []byte) ([]byte, error) {
+				return data, nil
+			},
+		},
+	}
+}
+
+func TestRunner(t *testing.T) {
+	r, cleanup := newTestRepository(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	require.NoError(t, r.Put(ctx, "/kube/", "test", []byte(`{"node":"1"}`)))
+
+	runner := NewRunner(r, testMigrations())
+	require.Equal(t, 2, runner.Latest())
+
+	results, err := runner.Run(ctx, true)
+	require.NoError(t, err)
+	require.Equal(t, []Result{
+		{Version: 1, Name: "envelope", Records: 1},
+		{Version: 2, Name: "rename", Records: 1},
+	}, results)
+
+	raw, err := r.Get(ctx, "/kube/", "test")
+	require.NoError(t, err)
+	require.Equal(t, `{"node":"1"}`, string(raw), "dry run must not change data")
+
+	applied, err := runner.Applied(ctx)
+	require.NoError(t, err)
+	require.Len(t, applied, 0)
+
+	_, err = runner.Run(ctx, false)
+	require.NoError(t, err)
+
+	raw, err = r.Get(ctx, "/kube/", "test")
+	require.NoError(t, err)
+	version, data := storage.UnwrapVersion(raw)
+	require.Equal(t, 2, version)
+	require.Equal(t, `{"machine":"1"}`, string(data))
+
+	applied, err = runner.Applied(ctx)
+	require.NoError(t, err)
+	require.Len(t, applied, 2)
+	require.Equal(t, 1, applied[0].Version)
+
+	// Applied migrations are not run again
+	results, err = runner.Run(ctx, false)
+	require.NoError(t, err)
+	require.Len(t, results, 0)
+}
+
+func TestRunnerError(t *testing.T) {
+	r, cleanup := newTestRepository(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	require.NoError(t, r.Put(ctx, "/kube/", "test", []byte(`{}`)))
+
+	runner := NewRunner(r, []Migration{
+		{
+			Version:  1,
+			Prefixes: []string{"/kube/"},
+			Migrate: func(key string, data []byte) ([]byte, error) {
+				return nil, errors.New("error")
+			},
+		},
+	})
+
+	_, err := runner.Run(ctx, false)
+	require.Error(t, err)
+
+	applied, err := runner.Applied(ctx)
+	require.NoError(t, err)
+	require.Len(t, applied, 0)
+}
+
+func TestRunnerNewerSchema(t *testing.T) {
+	r, cleanup := newTestRepository(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	require.NoError(t, NewRunner(r, testMigrations()).record(ctx, Migration{Version: 5}, 0))
+
+	_, err := NewRunner(r, testMigrations()).Run(ctx, false)
+	require.Error(t, err)
+}
+
+func TestBuildClusterIndex(t *testing.T) {
+	r, cleanup := newTestRepository(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	require.NoError(t, r.Put(ctx, workflows.Prefix, "1", []byte(`{"id":"1","config":{"clusterName":"test"}}`)))
+	require.NoError(t, r.Put(ctx, workflows.Prefix, "2", []byte(`{"id":"2"}`)))
+
+	count, err := buildClusterIndex(ctx, r, true)
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+
+	kvs, err := r.List(ctx, workflows.ClusterIndexPrefix)
+	require.NoError(t, err)
+	require.Len(t, kvs, 0)
+
+	count, err = buildClusterIndex(ctx, r, false)
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+
+	tasks, _, err := workflows.ClusterTasks(ctx, r, "test", 0, "")
+	require.NoError(t, err)
+	require.Len(t, tasks, 1)
+	require.Equal(t, "1", tasks[0].ID)
+}
@@ -0,0 +1,97 @@
+package migrations
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/supergiant/supergiant/pkg/account"
+	"github.com/supergiant/supergiant/pkg/kube"
+	"github.com/supergiant/supergiant/pkg/profile"
+	"github.com/supergiant/supergiant/pkg/storage"
+	"github.com/supergiant/supergiant/pkg/user"
+	"github.com/supergiant/supergiant/pkg/workflows"
+)
+
+// All is the list of migrations of the persisted records. Every change of a stored
+// struct that is not backward compatible must come with a new migration here.
+var All = []Migration{
+	{
+		Version: 1,
+		Name:    "schema-version-envelope",
+		Prefixes: []string{
+			account.DefaultStoragePrefix,
+			kube.DefaultStoragePrefix,
+			profile.DefaultKubeProfilePreifx,
+			user.DefaultStoragePrefix,
+			workflows.Prefix,
+		},
+		// Data is kept as is, records only get wrapped with the version envelope
+		Migrate: func(key string, data []byte) ([]byte, error) {
+			return data, nil
+		},
+	},
+	{
+		Version: 2,
+		Name:    "tasks-cluster-index",
+		Apply:   buildClusterIndex,
+	},
+}
+
+// indexBatchSize keeps transactions below the etcd limit of operations per transaction
+const indexBatchSize = 100
+
+// buildClusterIndex adds tasks saved before the cluster index has been introduced to the index
+func buildClusterIndex(ctx context.Context, repository storage.Interface, dryRun bool) (int, error) {
+	kvs, err := repository.List(ctx, workflows.Prefix)
+	if err != nil {
+		return 0, errors.Wrap(err, "list tasks")
+	}
+
+	count := 0
+	ops := make([]storage.Op, 0, indexBatchSize)
+
+	for _, kv := range kvs {
+		_, data := storage.UnwrapVersion(kv.Value)
+
+		task := &workflows.Task{}
+		if err := json.Unmarshal(data, task); err != nil {
+			logrus.Warnf("skip task %s: %v", kv.Key, err)
+			continue
+		}
+
+		if task.Config == nil || task.Config.ClusterName == "" {
+			continue
+		}
+
+		count++
+		if dryRun {
+			continue
+		}
+
+		ops = append(ops, storage.PutOp(workflows.ClusterIndexPrefix,
+			workflows.ClusterIndexKey(task.Config.ClusterName, task.ID), []byte(task.ID)))
+
+		if len(ops) == indexBatchSize {
+			if err := repository.Txn(ctx, ops...); err != nil {
+				return count, errors.Wrap(err, "write cluster index")
+			}
+			ops = ops[:0]
+		}
+	}
+
+	if len(ops) > 0 {
+		if err := repository.Txn(ctx, ops...); err != nil {
+			return count, errors.Wrap(err, "write cluster index")
+		}
+	}
+
+	return count, nil
+}
+
+// Latest is the schema version of records written by this build
+func Latest() int {
+	return NewRunner(nil, All).Latest()
+}
@@ -22,6 +22,24 @@ type Profile struct {
 	CIDR            string      `json:"cidr"`
 	HelmVersion     string      `json:"helmVersion"`
 	RBACEnabled     bool        `json:"rbacEnabled"`
+	// Scripts are names of script steps run on every machine of their role once it is provisioned
+	Scripts []string `json:"scripts,omitempty"`
+	// Preflight sets requirements machines are checked against before they are provisioned
+	Preflight PreflightProfile `json:"preflight"`
+}
+
+// PreflightProfile leaves machine minimums unchecked when they are zero, failed
+// checks are only reported unless Enforce is set.
+type PreflightProfile struct {
+	Enforce bool                `json:"enforce"`
+	Master  MachineRequirements `json:"master"`
+	Node    MachineRequirements `json:"node"`
+}
+
+type MachineRequirements struct {
+	MinCPU      int `json:"minCpu"`
+	MinMemoryMB int `json:"minMemoryMb"`
+	MinDiskGB   int `json:"minDiskGb"`
 }
 
 type NodeProfile map[string]string
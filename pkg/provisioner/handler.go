@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"net/http"
+	"strconv"
 
 	"github.com/gorilla/mux"
 	"github.com/pkg/errors"
@@ -36,6 +37,10 @@ type ProvisionRequest struct {
 	ClusterName      string          `json:"clusterName"`
 	Profile          profile.Profile `json:"profile"`
 	CloudAccountName string          `json:"cloudAccountName"`
+	// DryRun renders scripts of all the steps without creating machines, see workflows.StepStatus.Scripts
+	DryRun bool `json:"dryRun"`
+	// RollbackOnFailure removes machines and keys created by the tasks that fail
+	RollbackOnFailure bool `json:"rollbackOnFailure"`
 }
 
 type ProvisionResponse struct {
@@ -44,6 +49,7 @@ type ProvisionResponse struct {
 
 type ClusterProvisioner interface {
 	ProvisionCluster(context.Context, *profile.Profile, *steps.Config) (map[string][]*workflows.Task, error)
+	Cancel(clusterName string, rollback bool) error
 }
 
 func NewHandler(cloudAccountService *account.Service, tokenGetter TokenGetter, provisioner ClusterProvisioner) *Handler {
@@ -56,6 +62,34 @@ func NewHandler(cloudAccountService *account.Service, tokenGetter TokenGetter, p
 
 func (h *Handler) Register(m *mux.Router) {
 	m.HandleFunc("/provision", h.Provision).Methods(http.MethodPost)
+	m.HandleFunc("/provision/{kname}/cancel", h.Cancel).Methods(http.MethodPost)
+}
+
+// Cancel stops provisioning of the cluster, steps interrupted by
+// cancellation are rolled back when rollback query parameter is true.
+func (h *Handler) Cancel(w http.ResponseWriter, r *http.Request) {
+	kname := mux.Vars(r)["kname"]
+
+	rollback := false
+	if value := r.URL.Query().Get("rollback"); value != "" {
+		var err error
+		if rollback, err = strconv.ParseBool(value); err != nil {
+			message.SendValidationFailed(w, errors.Wrap(err, "rollback must be true or false"))
+			return
+		}
+	}
+
+	if err := h.provisioner.Cancel(kname, rollback); err != nil {
+		if sgerrors.IsNotFound(err) {
+			message.SendNotFound(w, "cluster provisioning", err)
+			return
+		}
+
+		message.SendUnknownError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
 }
 
 func (h *Handler) Provision(w http.ResponseWriter, r *http.Request) {
@@ -79,6 +113,8 @@ func (h *Handler) Provision(w http.ResponseWriter, r *http.Request) {
 	logrus.Infof("Got discoveryUrl %s", discoveryUrl)
 
 	config := steps.NewConfig(req.ClusterName, discoveryUrl, req.CloudAccountName, req.Profile)
+	config.DryRun = req.DryRun
+	config.RollbackOnFailure = req.RollbackOnFailure
 
 	acc, err := h.accountGetter.Get(r.Context(), req.CloudAccountName)
 
@@ -105,6 +141,11 @@ func (h *Handler) Provision(w http.ResponseWriter, r *http.Request) {
 	taskMap, err := h.provisioner.ProvisionCluster(ctx, &req.Profile, config)
 
 	if err != nil {
+		if sgerrors.IsNotFound(err) {
+			message.SendNotFound(w, "step", err)
+			return
+		}
+
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		logrus.Error(errors.Wrap(err, "provisionCluster"))
 		return
@@ -8,6 +8,7 @@ import (
 	"net/http/httptest"
 	"testing"
 
+	"github.com/gorilla/mux"
 	"github.com/pkg/errors"
 
 	"github.com/supergiant/supergiant/pkg/clouds"
@@ -29,12 +30,17 @@ func (t *mockTokenGetter) GetToken(ctx context.Context, num int) (string, error)
 type mockProvisioner struct {
 	provisionCluster func(context.Context, *profile.Profile, *steps.Config) (map[string][]*workflows.Task, error)
 	provisionNode    func(context.Context, profile.NodeProfile, *model.Kube, *steps.Config) (*workflows.Task, error)
+	cancel           func(string, bool) error
 }
 
 func (m *mockProvisioner) ProvisionCluster(ctx context.Context, kubeProfile *profile.Profile, config *steps.Config) (map[string][]*workflows.Task, error) {
 	return m.provisionCluster(ctx, kubeProfile, config)
 }
 
+func (m *mockProvisioner) Cancel(clusterName string, rollback bool) error {
+	return m.cancel(clusterName, rollback)
+}
+
 func (m *mockProvisioner) ProvisionNode(ctx context.Context, nodeProfile profile.NodeProfile, kube *model.Kube, config *steps.Config) (*workflows.Task, error) {
 	return m.provisionNode(ctx, nodeProfile, kube, config)
 }
@@ -49,9 +55,9 @@ func (m *mockAccountGetter) Get(ctx context.Context, id string) (*model.CloudAcc
 
 func TestProvisionHandler(t *testing.T) {
 	p := &ProvisionRequest{
-		"test",
-		profile.Profile{},
-		"1234",
+		ClusterName:      "test",
+		Profile:          profile.Profile{},
+		CloudAccountName: "1234",
 	}
 
 	validBody, _ := json.Marshal(p)
@@ -174,3 +180,59 @@ func TestProvisionHandler(t *testing.T) {
 		}
 	}
 }
+
+func TestCancelHandler(t *testing.T) {
+	testCases := []struct {
+		description string
+		url         string
+		cancelErr   error
+
+		expectedCode     int
+		expectedRollback bool
+	}{
+		{
+			description:  "invalid rollback",
+			url:          "/provision/test/cancel?rollback=maybe",
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			description:  "not provisioned",
+			url:          "/provision/test/cancel",
+			cancelErr:    sgerrors.ErrNotFound,
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			description:      "cancelled with rollback",
+			url:              "/provision/test/cancel?rollback=true",
+			expectedCode:     http.StatusAccepted,
+			expectedRollback: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		var rollback bool
+		provisioner := &mockProvisioner{
+			cancel: func(clusterName string, r bool) error {
+				rollback = r
+				return testCase.cancelErr
+			},
+		}
+
+		router := mux.NewRouter()
+		(&Handler{provisioner: provisioner}).Register(router)
+
+		req, _ := http.NewRequest(http.MethodPost, testCase.url, nil)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		if rec.Code != testCase.expectedCode {
+			t.Errorf("%s: wrong status code expected %d actual %d",
+				testCase.description, testCase.expectedCode, rec.Code)
+		}
+
+		if rollback != testCase.expectedRollback {
+			t.Errorf("%s: wrong rollback expected %v actual %v",
+				testCase.description, testCase.expectedRollback, rollback)
+		}
+	}
+}
@@ -22,7 +22,9 @@ const keySize = 4096
 
 type KubeService interface {
 	Create(ctx context.Context, k *model.Kube) error
+	CreateTx(ctx context.Context, k *model.Kube, ops ...storage.Op) error
 	Get(ctx context.Context, name string) (*model.Kube, error)
+	Update(ctx context.Context, name string, fn func(*model.Kube) error) error
 }
 
 type TaskProvisioner struct {
@@ -30,6 +32,16 @@ type TaskProvisioner struct {
 	repository   storage.Interface
 	getWriter    func(string) (io.WriteCloser, error)
 	provisionMap map[clouds.Name]workflows.WorkflowSet
+
+	m sync.Mutex
+	// provisions are clusters being provisioned by name
+	provisions map[string]*provision
+}
+
+// provision keeps tasks of the cluster so the whole provisioning can be cancelled
+type provision struct {
+	cancel context.CancelFunc
+	tasks  []*workflows.Task
 }
 
 func NewProvisioner(repository storage.Interface, kubeService KubeService) *TaskProvisioner {
@@ -51,19 +63,36 @@ func (r *TaskProvisioner) ProvisionCluster(ctx context.Context, profile *profile
 	masterTasks, nodeTasks, clusterTask := r.prepare(config.Provider, len(profile.MasterProfiles),
 		len(profile.NodesProfiles))
 
+	for _, t := range append(append([]*workflows.Task{}, masterTasks...), nodeTasks...) {
+		if err := t.AppendSteps(profile.Scripts); err != nil {
+			return nil, errors.Wrap(err, "add scripts of the profile")
+		}
+	}
+
+	// Keys are made before the cluster is saved and tracked, so there is nothing to undo when it fails
+	if err := bootstrapKeys(config); err != nil {
+		return nil, errors.Wrap(err, "bootstrap keys")
+	}
+
 	// TODO(stgleb): Make node names from task id before provisioning starts
 	masters, nodes := nodesFromProfile(config.ClusterName, masterTasks, nodeTasks, profile)
-	// Save cluster before provisioning
-	r.buildInitialCluster(ctx, profile, masters, nodes, config)
+	tasks := append(append([]*workflows.Task{clusterTask}, masterTasks...), nodeTasks...)
+	// Save cluster with its tasks before provisioning
+	if err := r.buildInitialCluster(ctx, profile, masters, nodes, config, tasks); err != nil {
+		return nil, errors.Wrap(err, "build initial cluster")
+	}
 
-	// monitor cluster state in separate goroutine
-	go r.monitorClusterState(ctx, config)
+	ctx, cancel := context.WithCancel(ctx)
+	r.track(config.ClusterName, cancel, tasks)
 
-	if err := bootstrapKeys(config); err != nil {
-		return nil, errors.Wrap(err, "bootstrap keys")
+	// monitor cluster state in separate goroutine, state channels
+	// have room for all updates of the dry run that has no cluster.
+	if !config.DryRun {
+		go r.monitorClusterState(ctx, config)
 	}
 
 	go func() {
+		defer r.untrack(config.ClusterName)
 		// ProvisionCluster masters and wait until n/2 + 1 of masters with etcd are up and running
 		doneChan, failChan, err := r.provisionMasters(ctx, profile, config, masterTasks)
 
@@ -74,6 +103,7 @@ func (r *TaskProvisioner) ProvisionCluster(ctx context.Context, profile *profile
 		select {
 		case <-ctx.Done():
 			logrus.Errorf("Master cluster has not been created %v", ctx.Err())
+			r.markFailed(config)
 			return
 		case <-doneChan:
 		case <-failChan:
@@ -88,6 +118,12 @@ func (r *TaskProvisioner) ProvisionCluster(ctx context.Context, profile *profile
 		// ProvisionCluster nodes
 		r.provisionNodes(ctx, profile, config, nodeTasks)
 
+		if ctx.Err() != nil {
+			logrus.Errorf("Cluster %s deployment has been stopped %v", config.ClusterName, ctx.Err())
+			r.markFailed(config)
+			return
+		}
+
 		// Wait for cluster checks are finished
 		r.waitCluster(ctx, clusterTask, config)
 		logrus.Infof("Cluster %s deployment has finished", config.ClusterName)
@@ -100,6 +136,70 @@ func (r *TaskProvisioner) ProvisionCluster(ctx context.Context, profile *profile
 	}, nil
 }
 
+// Cancel stops provisioning of the cluster, tasks that are running are cancelled and the ones
+// that have not been started yet are never started. Interrupted steps are rolled back when rollback is set.
+func (r *TaskProvisioner) Cancel(clusterName string, rollback bool) error {
+	r.m.Lock()
+	p, ok := r.provisions[clusterName]
+	r.m.Unlock()
+
+	if !ok {
+		return errors.Wrapf(sgerrors.ErrNotFound, "provisioning of cluster %s", clusterName)
+	}
+
+	// Tasks are cancelled first to let them know whether to roll back
+	for _, t := range p.tasks {
+		if t == nil {
+			continue
+		}
+
+		if err := workflows.Cancel(t.ID, rollback); err != nil && !sgerrors.IsNotFound(err) {
+			logrus.Errorf("cancel task %s of cluster %s: %v", t.ID, clusterName, err)
+		}
+	}
+	p.cancel()
+
+	return nil
+}
+
+func (r *TaskProvisioner) track(clusterName string, cancel context.CancelFunc, tasks []*workflows.Task) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	if r.provisions == nil {
+		r.provisions = make(map[string]*provision)
+	}
+	r.provisions[clusterName] = &provision{
+		cancel: cancel,
+		tasks:  tasks,
+	}
+}
+
+func (r *TaskProvisioner) untrack(clusterName string) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	delete(r.provisions, clusterName)
+}
+
+// markFailed sets failed state of the cluster when the context of provisioning is done
+func (r *TaskProvisioner) markFailed(config *steps.Config) {
+	// Cluster is not saved in dry run
+	if config.DryRun {
+		return
+	}
+
+	clusterName := config.ClusterName
+	err := r.kubeService.Update(context.Background(), clusterName, func(k *model.Kube) error {
+		k.State = model.StateFailed
+		return nil
+	})
+
+	if err != nil {
+		logrus.Errorf("update kube %s state caused %v", clusterName, err)
+	}
+}
+
 func (p *TaskProvisioner) ProvisionNodes(ctx context.Context, nodeProfiles []profile.NodeProfile, kube *model.Kube, config *steps.Config) ([]string, error) {
 	if len(kube.Masters) != 0 {
 		for key := range kube.Masters {
@@ -155,9 +255,17 @@ func (p *TaskProvisioner) ProvisionNodes(ctx context.Context, nodeProfiles []pro
 			}
 
 			if n := cfg.GetNode(); n != nil {
-				kube.Nodes[n.Id] = n
-				// TODO(stgleb): Use some other method like update or Patch instead of recreate
-				p.kubeService.Create(context.Background(), kube)
+				err := p.kubeService.Update(context.Background(), kube.Name, func(k *model.Kube) error {
+					if k.Nodes == nil {
+						k.Nodes = make(map[string]*node.Node)
+					}
+					k.Nodes[n.Id] = n
+					return nil
+				})
+
+				if err != nil {
+					logrus.Errorf("add node %s to cluster %s caused %v", n.Name, kube.Name, err)
+				}
 			} else {
 				logrus.Errorf("Add node to cluster %s node was not added", kube.Name)
 			}
@@ -339,7 +447,9 @@ func (p *TaskProvisioner) waitCluster(ctx context.Context, clusterTask *workflow
 	clusterWg.Wait()
 }
 
-func (p *TaskProvisioner) buildInitialCluster(ctx context.Context, profile *profile.Profile, masters, nodes map[string]*node.Node, config *steps.Config) error {
+// buildInitialCluster saves cluster in provisioning state together with its tasks, the cluster
+// is saved in one transaction with the first of them. Cluster is not saved in dry run.
+func (p *TaskProvisioner) buildInitialCluster(ctx context.Context, profile *profile.Profile, masters, nodes map[string]*node.Node, config *steps.Config, tasks []*workflows.Task) error {
 	cluster := &model.Kube{
 		State:        model.StateProvisioning,
 		Name:         config.ClusterName,
@@ -367,7 +477,32 @@ func (p *TaskProvisioner) buildInitialCluster(ctx context.Context, profile *prof
 		Nodes:   nodes,
 	}
 
-	return p.kubeService.Create(ctx, cluster)
+	ops := make([]storage.Op, 0, len(tasks))
+	for _, t := range tasks {
+		if t == nil {
+			continue
+		}
+
+		taskOps, err := t.PutOps()
+		if err != nil {
+			return errors.Wrapf(err, "task %s", t.ID)
+		}
+
+		// Only tasks with scripts rendered by their steps are kept in dry run
+		if config.DryRun {
+			if err := p.repository.Txn(ctx, taskOps...); err != nil {
+				return errors.Wrapf(err, "task %s", t.ID)
+			}
+			continue
+		}
+		ops = append(ops, taskOps...)
+	}
+
+	if config.DryRun {
+		return nil
+	}
+
+	return p.kubeService.CreateTx(ctx, cluster, ops...)
 }
 
 // Create bootstrap key pair and save to config ssh section
@@ -389,35 +524,30 @@ func (p *TaskProvisioner) monitorClusterState(ctx context.Context, cfg *steps.Co
 	for {
 		select {
 		case n := <-cfg.NodeChan():
-			k, err := p.kubeService.Get(ctx, cfg.ClusterName)
-
-			if err != nil {
-				logrus.Errorf("update kube state caused %v", err)
-				continue
-			}
-
-			if n.Role == node.RoleMaster {
-				k.Masters[n.Name] = &n
-			} else {
-				k.Nodes[n.Name] = &n
-			}
-
-			err = p.kubeService.Create(ctx, k)
+			err := p.kubeService.Update(ctx, cfg.ClusterName, func(k *model.Kube) error {
+				if n.Role == node.RoleMaster {
+					if k.Masters == nil {
+						k.Masters = make(map[string]*node.Node)
+					}
+					k.Masters[n.Name] = &n
+				} else {
+					if k.Nodes == nil {
+						k.Nodes = make(map[string]*node.Node)
+					}
+					k.Nodes[n.Name] = &n
+				}
+				return nil
+			})
 
 			if err != nil {
 				logrus.Errorf("update kube state caused %v", err)
 				continue
 			}
 		case state := <-cfg.KubeStateChan():
-			k, err := p.kubeService.Get(ctx, cfg.ClusterName)
-
-			if err != nil {
-				logrus.Errorf("update kube state caused %v", err)
-				continue
-			}
-
-			k.State = state
-			err = p.kubeService.Create(ctx, k)
+			err := p.kubeService.Update(ctx, cfg.ClusterName, func(k *model.Kube) error {
+				k.State = state
+				return nil
+			})
 
 			if err != nil {
 				logrus.Errorf("update kube state caused %v", err)
@@ -14,6 +14,8 @@ import (
 	"github.com/supergiant/supergiant/pkg/model"
 	"github.com/supergiant/supergiant/pkg/node"
 	"github.com/supergiant/supergiant/pkg/profile"
+	"github.com/supergiant/supergiant/pkg/sgerrors"
+	"github.com/supergiant/supergiant/pkg/storage"
 	"github.com/supergiant/supergiant/pkg/testutils"
 	"github.com/supergiant/supergiant/pkg/workflows"
 	"github.com/supergiant/supergiant/pkg/workflows/steps"
@@ -39,6 +41,28 @@ func (m *mockKubeService) Create(ctx context.Context, k *model.Kube) error {
 	return m.createErr
 }
 
+func (m *mockKubeService) CreateTx(ctx context.Context, k *model.Kube, ops ...storage.Op) error {
+	m.data[k.Name] = k
+	return m.createErr
+}
+
+func (m *mockKubeService) Update(ctx context.Context, kname string, fn func(*model.Kube) error) error {
+	if m.getError != nil {
+		return m.getError
+	}
+
+	k, ok := m.data[kname]
+	if !ok {
+		return sgerrors.ErrNotFound
+	}
+
+	if err := fn(k); err != nil {
+		return err
+	}
+
+	return m.createErr
+}
+
 func (m *mockKubeService) Get(ctx context.Context, kname string) (*model.Kube, error) {
 	return m.data[kname], m.getError
 }
@@ -46,6 +70,7 @@ func (m *mockKubeService) Get(ctx context.Context, kname string) (*model.Kube, e
 func TestProvisionCluster(t *testing.T) {
 	repository := &testutils.MockStorage{}
 	repository.On("Put", context.Background(), mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	repository.On(testutils.StorageTxn, mock.Anything, mock.Anything).Return(nil)
 
 	bc := &bufferCloser{
 		bytes.Buffer{},
@@ -53,14 +78,14 @@ func TestProvisionCluster(t *testing.T) {
 	}
 
 	provisioner := TaskProvisioner{
-		&mockKubeService{
+		kubeService: &mockKubeService{
 			data: make(map[string]*model.Kube),
 		},
-		repository,
-		func(string) (io.WriteCloser, error) {
+		repository: repository,
+		getWriter: func(string) (io.WriteCloser, error) {
 			return bc, nil
 		},
-		map[clouds.Name]workflows.WorkflowSet{
+		provisionMap: map[clouds.Name]workflows.WorkflowSet{
 			clouds.DigitalOcean: {
 				ProvisionMaster: "test_master",
 				ProvisionNode:   "test_node",
@@ -69,6 +94,7 @@ func TestProvisionCluster(t *testing.T) {
 	}
 
 	workflows.Init()
+	workflows.RegisterWorkFlow(workflows.Cluster, []steps.Step{})
 	workflows.RegisterWorkFlow("test_master", []steps.Step{})
 	workflows.RegisterWorkFlow("test_node", []steps.Step{})
 
@@ -116,20 +142,21 @@ func TestProvisionNodes(t *testing.T) {
 	repository.On("Put", context.Background(),
 		mock.Anything, mock.Anything, mock.Anything).
 		Return(nil)
+	repository.On(testutils.StorageTxn, mock.Anything, mock.Anything).Return(nil)
 	bc := &bufferCloser{
 		bytes.Buffer{},
 		nil,
 	}
 
 	provisioner := TaskProvisioner{
-		&mockKubeService{
+		kubeService: &mockKubeService{
 			data: make(map[string]*model.Kube),
 		},
-		repository,
-		func(string) (io.WriteCloser, error) {
+		repository: repository,
+		getWriter: func(string) (io.WriteCloser, error) {
 			return bc, nil
 		},
-		map[clouds.Name]workflows.WorkflowSet{
+		provisionMap: map[clouds.Name]workflows.WorkflowSet{
 			clouds.DigitalOcean: {
 				ProvisionMaster: "test_master",
 				ProvisionNode:   "test_node"},
@@ -312,3 +339,27 @@ func TestMonitorCluster(t *testing.T) {
 		}
 	}
 }
+
+func TestTaskProvisionerCancel(t *testing.T) {
+	p := &TaskProvisioner{}
+
+	if err := p.Cancel("test", false); !sgerrors.IsNotFound(err) {
+		t.Errorf("Unexpected error %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p.track("test", cancel, []*workflows.Task{nil})
+
+	if err := p.Cancel("test", true); err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+
+	if ctx.Err() != context.Canceled {
+		t.Errorf("Provisioning context must be cancelled")
+	}
+
+	p.untrack("test")
+	if err := p.Cancel("test", false); !sgerrors.IsNotFound(err) {
+		t.Errorf("Unexpected error %v", err)
+	}
+}
@@ -0,0 +1,220 @@
+package provisioner
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/supergiant/supergiant/pkg/model"
+	"github.com/supergiant/supergiant/pkg/node"
+	"github.com/supergiant/supergiant/pkg/util"
+	"github.com/supergiant/supergiant/pkg/workflows"
+	"github.com/supergiant/supergiant/pkg/workflows/steps"
+)
+
+// ResumePolicy tells what happens on start with tasks interrupted by the control plane restart
+type ResumePolicy string
+
+const (
+	// ResumeTasks runs steps of the interrupted tasks that have not succeeded again
+	ResumeTasks ResumePolicy = "resume"
+	// FailTasks marks interrupted tasks as failed
+	FailTasks ResumePolicy = "fail"
+)
+
+type resumedTask struct {
+	task   *workflows.Task
+	result chan error
+}
+
+// ResumeInterrupted handles tasks that were running when the control plane stopped according
+// to the policy, state of clusters being provisioned is updated once their tasks are done.
+func (p *TaskProvisioner) ResumeInterrupted(ctx context.Context, policy ResumePolicy) error {
+	if policy == "" {
+		policy = FailTasks
+	}
+
+	if policy != ResumeTasks && policy != FailTasks {
+		return errors.Errorf("unknown resume policy %s", policy)
+	}
+
+	data, err := p.repository.GetAll(ctx, workflows.Prefix)
+	if err != nil {
+		return errors.Wrap(err, "read tasks")
+	}
+
+	byCluster := make(map[string][]resumedTask)
+	for _, raw := range data {
+		task, err := workflows.DeserializeTask(raw, p.repository)
+		if err != nil {
+			logrus.Errorf("resume: deserialize task: %v", err)
+			continue
+		}
+
+		if !task.Interrupted() {
+			continue
+		}
+
+		// Another replica may take the task over at the same time
+		if ok, err := task.Claim(ctx); err != nil || !ok {
+			if err != nil {
+				logrus.Errorf("resume: claim task %s: %v", task.ID, err)
+			}
+			continue
+		}
+
+		clusterName := ""
+		if task.Config != nil {
+			clusterName = task.Config.ClusterName
+		}
+
+		byCluster[clusterName] = append(byCluster[clusterName], resumedTask{
+			task:   task,
+			result: p.resume(ctx, task, policy),
+		})
+	}
+
+	for clusterName, tasks := range byCluster {
+		if clusterName == "" {
+			continue
+		}
+
+		go p.reconcileCluster(ctx, clusterName, tasks)
+	}
+
+	return nil
+}
+
+// TakeOver resumes tasks whose owners have stopped renewing their leases every
+// interval until ctx is done, only one replica of the control plane is expected to run it.
+func (p *TaskProvisioner) TakeOver(ctx context.Context, policy ResumePolicy, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			// Resumed tasks keep running when the replica loses the leadership
+			if err := p.ResumeInterrupted(context.Background(), policy); err != nil {
+				logrus.Errorf("take over tasks: %v", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (p *TaskProvisioner) resume(ctx context.Context, task *workflows.Task, policy ResumePolicy) chan error {
+	if policy == ResumeTasks && task.Resumable() {
+		out, err := p.getWriter(util.MakeFileName(task.ID))
+		if err == nil {
+			logrus.Infof("resume task %s", task.ID)
+			errChan := task.Restart(ctx, task.ID, out, false)
+
+			result := make(chan error, 1)
+			go func() {
+				defer close(result)
+				err := <-errChan
+				out.Close()
+				result <- err
+			}()
+
+			return result
+		}
+
+		logrus.Errorf("resume task %s: get writer %v", task.ID, err)
+	}
+
+	logrus.Infof("mark interrupted task %s as failed", task.ID)
+	if err := task.MarkInterrupted(ctx); err != nil {
+		logrus.Errorf("mark task %s as failed: %v", task.ID, err)
+	}
+
+	result := make(chan error, 1)
+	result <- errors.New(workflows.InterruptedMessage)
+	close(result)
+
+	return result
+}
+
+// reconcileCluster waits for the resumed tasks of the cluster and updates the cluster
+// accordingly. Provisioning that has not been completed by the resumed tasks fails.
+func (p *TaskProvisioner) reconcileCluster(ctx context.Context, clusterName string, tasks []resumedTask) {
+	failed := false
+	done := make([]*node.Node, 0, len(tasks))
+
+	for _, t := range tasks {
+		if err := <-t.result; err != nil {
+			logrus.Errorf("interrupted task %s of cluster %s: %v", t.task.ID, clusterName, err)
+			failed = true
+			continue
+		}
+
+		if t.task.Type != workflows.Cluster && t.task.Config.Node.Name != "" {
+			n := t.task.Config.Node
+			if t.task.Config.IsMaster {
+				n.Role = node.RoleMaster
+			}
+			done = append(done, &n)
+		}
+	}
+
+	provisioned := false
+	if !failed {
+		var err error
+		if provisioned, err = p.clusterTaskSucceeded(ctx, clusterName); err != nil {
+			logrus.Errorf("resume: cluster %s: %v", clusterName, err)
+		}
+	}
+
+	err := p.kubeService.Update(ctx, clusterName, func(k *model.Kube) error {
+		for _, n := range done {
+			if n.Role == node.RoleMaster {
+				if k.Masters == nil {
+					k.Masters = make(map[string]*node.Node)
+				}
+				k.Masters[n.Name] = n
+			} else {
+				if k.Nodes == nil {
+					k.Nodes = make(map[string]*node.Node)
+				}
+				k.Nodes[n.Name] = n
+			}
+		}
+
+		if k.State != model.StateProvisioning {
+			return nil
+		}
+
+		if provisioned {
+			k.State = model.StateOperational
+		} else {
+			logrus.Errorf("provisioning of cluster %s has not been completed after restart", clusterName)
+			k.State = model.StateFailed
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		logrus.Errorf("resume: update kube %s: %v", clusterName, err)
+	}
+}
+
+// clusterTaskSucceeded tells whether final checks of the cluster provisioning are done
+func (p *TaskProvisioner) clusterTaskSucceeded(ctx context.Context, clusterName string) (bool, error) {
+	tasks, _, err := workflows.ClusterTasks(ctx, p.repository, clusterName, 0, "")
+	if err != nil {
+		return false, err
+	}
+
+	for _, t := range tasks {
+		if t.Type == workflows.Cluster && t.Status == steps.StatusSuccess {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
@@ -0,0 +1,131 @@
+package provisioner
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/supergiant/supergiant/pkg/model"
+	"github.com/supergiant/supergiant/pkg/node"
+	"github.com/supergiant/supergiant/pkg/storage"
+	"github.com/supergiant/supergiant/pkg/testutils"
+	"github.com/supergiant/supergiant/pkg/workflows"
+	"github.com/supergiant/supergiant/pkg/workflows/steps"
+)
+
+func TestResumeInterruptedUnknownPolicy(t *testing.T) {
+	p := &TaskProvisioner{}
+	require.Error(t, p.ResumeInterrupted(context.Background(), "unknown"))
+}
+
+func TestResumeNotResumable(t *testing.T) {
+	task := &workflows.Task{
+		ID:     "interrupted",
+		Type:   workflows.DigitalOceanMaster,
+		Status: steps.StatusExecuting,
+		Config: &steps.Config{ClusterName: "test"},
+		StepStatuses: []workflows.StepStatus{
+			{StepName: "done", Status: steps.StatusSuccess},
+			{StepName: "running", Status: steps.StatusExecuting},
+		},
+	}
+	data, err := json.Marshal(task)
+	require.NoError(t, err)
+
+	repository := &testutils.MockStorage{}
+	repository.On(testutils.StorageTxn, mock.Anything, mock.Anything).Return(nil)
+
+	task, err = workflows.DeserializeTask(data, repository)
+	require.NoError(t, err)
+	require.True(t, task.Interrupted())
+
+	p := &TaskProvisioner{repository: repository}
+	// Workflow of the task is not registered, so it cannot be resumed
+	require.Error(t, <-p.resume(context.Background(), task, ResumeTasks))
+
+	require.Equal(t, steps.StatusError, task.Status)
+	require.Equal(t, steps.StatusSuccess, task.StepStatuses[0].Status)
+	require.Equal(t, steps.StatusError, task.StepStatuses[1].Status)
+	require.Equal(t, workflows.InterruptedMessage, task.StepStatuses[1].ErrMsg)
+	repository.AssertCalled(t, testutils.StorageTxn, mock.Anything, mock.Anything)
+}
+
+func TestReconcileCluster(t *testing.T) {
+	testCases := []struct {
+		name          string
+		err           error
+		clusterTask   *workflows.Task
+		expectedState model.KubeState
+		expectMaster  bool
+	}{
+		{
+			name: "provisioned",
+			clusterTask: &workflows.Task{
+				ID:     "cluster",
+				Type:   workflows.Cluster,
+				Status: steps.StatusSuccess,
+			},
+			expectedState: model.StateOperational,
+			expectMaster:  true,
+		},
+		{
+			name:          "cluster task has not been done",
+			expectedState: model.StateFailed,
+			expectMaster:  true,
+		},
+		{
+			name:          "task failed",
+			err:           errors.New(workflows.InterruptedMessage),
+			expectedState: model.StateFailed,
+		},
+	}
+
+	for _, testCase := range testCases {
+		repository := &testutils.MockStorage{}
+		kvs := []storage.KeyValue{}
+		if testCase.clusterTask != nil {
+			data, err := json.Marshal(testCase.clusterTask)
+			require.NoError(t, err)
+
+			kvs = append(kvs, storage.KeyValue{
+				Key: workflows.ClusterIndexPrefix + "test/" + testCase.clusterTask.ID,
+			})
+			repository.On(testutils.StorageGet, mock.Anything, workflows.Prefix, testCase.clusterTask.ID).Return(data, nil)
+		}
+		repository.On(testutils.StoragePage, mock.Anything, mock.Anything, 0, "").Return(kvs, "", nil)
+
+		svc := &mockKubeService{
+			data: map[string]*model.Kube{
+				"test": {Name: "test", State: model.StateProvisioning},
+			},
+		}
+
+		result := make(chan error, 1)
+		result <- testCase.err
+
+		p := &TaskProvisioner{repository: repository, kubeService: svc}
+		p.reconcileCluster(context.Background(), "test", []resumedTask{
+			{
+				task: &workflows.Task{
+					ID:   "master",
+					Type: workflows.DigitalOceanMaster,
+					Config: &steps.Config{
+						ClusterName: "test",
+						IsMaster:    true,
+						Node:        node.Node{Name: "master-1"},
+					},
+				},
+				result: result,
+			},
+		})
+
+		k := svc.data["test"]
+		require.Equal(t, testCase.expectedState, k.State, testCase.name)
+		_, ok := k.Masters["master-1"]
+		require.Equal(t, testCase.expectMaster, ok, testCase.name)
+	}
+}
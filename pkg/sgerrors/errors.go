@@ -26,6 +26,8 @@ var (
 	ErrAlreadyExists       = New("entity already exists", EntityAlreadyExists)
 	ErrUnknownProvider     = New("unknown provider type", UnknownProvider)
 	ErrUnsupportedProvider = New("unsupported provider", UnsupportedProvider)
+	ErrConflict            = New("entity has been modified concurrently", Conflict)
+	ErrInvalidContinue     = New("invalid continue token", ValidationFailed)
 )
 
 func IsNotFound(err error) bool {
@@ -47,3 +49,11 @@ func IsUnknownProvider(err error) bool {
 func IsUnsupportedProvider(err error) bool {
 	return errors.Cause(err) == ErrUnsupportedProvider
 }
+
+func IsConflict(err error) bool {
+	return errors.Cause(err) == ErrConflict
+}
+
+func IsInvalidContinue(err error) bool {
+	return errors.Cause(err) == ErrInvalidContinue
+}
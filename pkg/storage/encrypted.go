@@ -0,0 +1,416 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// encryptedMagic marks values written by EncryptedRepository, values without
+// it are treated as plain text written before encryption has been enabled.
+var encryptedMagic = []byte("sgenc:v1:")
+
+const masterKeySize = 32
+
+// Keyring holds master keys by their ids, new values are always
+// encrypted with the active key while any known key can be used for decryption.
+type Keyring struct {
+	ActiveKeyID string `json:"activeKey"`
+	// Keys are base64 encoded 256 bit AES keys
+	Keys map[string]string `json:"keys"`
+
+	ciphers map[string]cipher.AEAD
+}
+
+// LoadKeyring reads keyring from the JSON file, e.g.
+// {"activeKey": "2", "keys": {"1": "<base64 key>", "2": "<base64 key>"}}
+func LoadKeyring(path string) (*Keyring, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "read key file")
+	}
+
+	k := &Keyring{}
+	if err := json.Unmarshal(data, k); err != nil {
+		return nil, errors.Wrap(err, "unmarshal key file")
+	}
+
+	return k, k.init()
+}
+
+// ParseKeyring builds keyring of a single master key provided in form of <key id>:<base64 key>
+func ParseKeyring(masterKey string) (*Keyring, error) {
+	parts := strings.SplitN(masterKey, ":", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return nil, errors.New("master key must be in form of <key id>:<base64 key>")
+	}
+
+	k := &Keyring{
+		ActiveKeyID: parts[0],
+		Keys: map[string]string{
+			parts[0]: parts[1],
+		},
+	}
+
+	return k, k.init()
+}
+
+func (k *Keyring) init() error {
+	if _, ok := k.Keys[k.ActiveKeyID]; !ok {
+		return errors.Errorf("active key %s not found", k.ActiveKeyID)
+	}
+
+	k.ciphers = make(map[string]cipher.AEAD, len(k.Keys))
+	for id, encoded := range k.Keys {
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return errors.Wrapf(err, "decode key %s", id)
+		}
+
+		if len(key) != masterKeySize {
+			return errors.Errorf("key %s must be %d bytes long", id, masterKeySize)
+		}
+
+		aead, err := newAEAD(key)
+		if err != nil {
+			return errors.Wrapf(err, "key %s", id)
+		}
+		k.ciphers[id] = aead
+	}
+
+	return nil
+}
+
+// envelope is a value encrypted with a random data key, the data key itself
+// is encrypted with the master key identified by KeyID. Both of them are sealed
+// together with the key id and the storage key of the value, so an envelope
+// copied to another record or given another key id can't be opened.
+type envelope struct {
+	KeyID        string `json:"keyId"`
+	EncryptedKey []byte `json:"encryptedKey"`
+	Data         []byte `json:"data"`
+}
+
+// EncryptedRepository transparently encrypts values of the configured prefixes
+// before passing them to the underlying storage and decrypts them on read.
+type EncryptedRepository struct {
+	Interface
+
+	keyring  *Keyring
+	prefixes []string
+}
+
+// NewEncryptedRepository wraps storage so that values of all keys starting with one of prefixes are encrypted
+func NewEncryptedRepository(s Interface, keyring *Keyring, prefixes []string) *EncryptedRepository {
+	return &EncryptedRepository{
+		Interface: s,
+		keyring:   keyring,
+		prefixes:  prefixes,
+	}
+}
+
+func (e *EncryptedRepository) Get(ctx context.Context, prefix string, key string) ([]byte, error) {
+	value, err := e.Interface.Get(ctx, prefix, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return e.decrypt(prefix+key, value)
+}
+
+func (e *EncryptedRepository) GetAll(ctx context.Context, prefix string) ([][]byte, error) {
+	// Values are sealed with their keys, so they are listed together
+	kvs, err := e.List(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([][]byte, 0, len(kvs))
+	for _, kv := range kvs {
+		values = append(values, kv.Value)
+	}
+
+	return values, nil
+}
+
+func (e *EncryptedRepository) List(ctx context.Context, prefix string) ([]KeyValue, error) {
+	kvs, err := e.Interface.List(ctx, prefix)
+	if err != nil {
+		return kvs, err
+	}
+
+	for i := range kvs {
+		if kvs[i].Value, err = e.decrypt(kvs[i].Key, kvs[i].Value); err != nil {
+			return nil, errors.Wrap(err, kvs[i].Key)
+		}
+	}
+
+	return kvs, nil
+}
+
+func (e *EncryptedRepository) Page(ctx context.Context, prefix string, limit int, continueToken string) ([]KeyValue, string, error) {
+	kvs, next, err := e.Interface.Page(ctx, prefix, limit, continueToken)
+	if err != nil {
+		return kvs, next, err
+	}
+
+	for i := range kvs {
+		if kvs[i].Value, err = e.decrypt(kvs[i].Key, kvs[i].Value); err != nil {
+			return nil, "", errors.Wrap(err, kvs[i].Key)
+		}
+	}
+
+	return kvs, next, nil
+}
+
+func (e *EncryptedRepository) Put(ctx context.Context, prefix string, key string, value []byte) error {
+	value, err := e.encryptKey(prefix+key, value)
+	if err != nil {
+		return err
+	}
+
+	return e.Interface.Put(ctx, prefix, key, value)
+}
+
+func (e *EncryptedRepository) Update(ctx context.Context, prefix string, key string, fn UpdateFunc) error {
+	return e.Interface.Update(ctx, prefix, key, func(current []byte) ([]byte, error) {
+		current, err := e.decrypt(prefix+key, current)
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := fn(current)
+		if err != nil {
+			return nil, err
+		}
+
+		return e.encryptKey(prefix+key, value)
+	})
+}
+
+func (e *EncryptedRepository) Txn(ctx context.Context, ops ...Op) error {
+	encrypted := make([]Op, 0, len(ops))
+
+	for _, op := range ops {
+		if op.Type == OpPut {
+			value, err := e.encryptKey(op.Prefix+op.Key, op.Value)
+			if err != nil {
+				return err
+			}
+			op.Value = value
+		}
+		encrypted = append(encrypted, op)
+	}
+
+	return e.Interface.Txn(ctx, encrypted...)
+}
+
+func (e *EncryptedRepository) Watch(ctx context.Context, prefix string) (<-chan Event, error) {
+	events, err := e.Interface.Watch(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	decrypted := make(chan Event)
+	go func() {
+		defer close(decrypted)
+
+		for ev := range events {
+			value, err := e.decrypt(ev.Key, ev.Value)
+			if err != nil {
+				logrus.Errorf("watch %s: decrypt %s: %v", prefix, ev.Key, err)
+				continue
+			}
+			ev.Value = value
+
+			select {
+			case decrypted <- ev:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return decrypted, nil
+}
+
+// Rotate re-encrypts all values of the configured prefixes that are stored in plain text
+// or encrypted with a key other than the active one, it returns the number of rewritten values.
+func (e *EncryptedRepository) Rotate(ctx context.Context) (int, error) {
+	count := 0
+
+	for _, prefix := range e.prefixes {
+		kvs, err := e.Interface.List(ctx, prefix)
+		if err != nil {
+			return count, errors.Wrapf(err, "list %s", prefix)
+		}
+
+		for _, kv := range kvs {
+			if !e.needsRotation(kv.Value) {
+				continue
+			}
+
+			err := e.Interface.Update(ctx, "", kv.Key, func(current []byte) ([]byte, error) {
+				plain, err := e.decrypt(kv.Key, current)
+				if err != nil {
+					return nil, err
+				}
+
+				return e.encrypt(kv.Key, plain)
+			})
+			if err != nil {
+				return count, errors.Wrapf(err, "rotate %s", kv.Key)
+			}
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+func (e *EncryptedRepository) needsRotation(value []byte) bool {
+	if !bytes.HasPrefix(value, encryptedMagic) {
+		return true
+	}
+
+	env := &envelope{}
+	if err := json.Unmarshal(value[len(encryptedMagic):], env); err != nil {
+		return true
+	}
+
+	return env.KeyID != e.keyring.ActiveKeyID
+}
+
+func (e *EncryptedRepository) shouldEncrypt(key string) bool {
+	for _, prefix := range e.prefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (e *EncryptedRepository) encryptKey(key string, value []byte) ([]byte, error) {
+	if !e.shouldEncrypt(key) {
+		return value, nil
+	}
+
+	value, err := e.encrypt(key, value)
+	if err != nil {
+		return nil, errors.Wrapf(err, "encrypt %s", key)
+	}
+
+	return value, nil
+}
+
+func (e *EncryptedRepository) encrypt(key string, value []byte) ([]byte, error) {
+	dataKey := make([]byte, masterKeySize)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return nil, err
+	}
+
+	dataCipher, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	additional := additionalData(e.keyring.ActiveKeyID, key)
+	data, err := seal(dataCipher, value, additional)
+	if err != nil {
+		return nil, err
+	}
+
+	encryptedKey, err := seal(e.keyring.ciphers[e.keyring.ActiveKeyID], dataKey, additional)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := json.Marshal(&envelope{
+		KeyID:        e.keyring.ActiveKeyID,
+		EncryptedKey: encryptedKey,
+		Data:         data,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return append(append([]byte{}, encryptedMagic...), raw...), nil
+}
+
+func (e *EncryptedRepository) decrypt(key string, value []byte) ([]byte, error) {
+	if !bytes.HasPrefix(value, encryptedMagic) {
+		return value, nil
+	}
+
+	env := &envelope{}
+	if err := json.Unmarshal(value[len(encryptedMagic):], env); err != nil {
+		return nil, errors.Wrap(err, "unmarshal encrypted value")
+	}
+
+	masterCipher, ok := e.keyring.ciphers[env.KeyID]
+	if !ok {
+		return nil, errors.Errorf("unknown encryption key %s", env.KeyID)
+	}
+
+	additional := additionalData(env.KeyID, key)
+	dataKey, err := open(masterCipher, env.EncryptedKey, additional)
+	if err != nil {
+		return nil, errors.Wrap(err, "decrypt data key")
+	}
+
+	dataCipher, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	plain, err := open(dataCipher, env.Data, additional)
+	if err != nil {
+		return nil, errors.Wrap(err, "decrypt value")
+	}
+
+	return plain, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// additionalData binds the envelope to the master key and the storage key of the value
+func additionalData(keyID, key string) []byte {
+	return []byte(keyID + "\x00" + key)
+}
+
+// seal encrypts plain text authenticated together with additional data and prepends random nonce to the result
+func seal(aead cipher.AEAD, plain, additional []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, plain, additional), nil
+}
+
+func open(aead cipher.AEAD, sealed, additional []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("encrypted data is too short")
+	}
+
+	nonce, data := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, data, additional)
+}
@@ -0,0 +1,183 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testKey(b byte) string {
+	return base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, masterKeySize))
+}
+
+func TestParseKeyring(t *testing.T) {
+	testCases := []struct {
+		masterKey string
+		hasErr    bool
+	}{
+		{
+			masterKey: "1:" + testKey(1),
+		},
+		{
+			masterKey: testKey(1),
+			hasErr:    true,
+		},
+		{
+			masterKey: "1:" + base64.StdEncoding.EncodeToString([]byte("short")),
+			hasErr:    true,
+		},
+		{
+			masterKey: "1:not base64",
+			hasErr:    true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		_, err := ParseKeyring(testCase.masterKey)
+		require.Equal(t, testCase.hasErr, err != nil, "master key %s", testCase.masterKey)
+	}
+}
+
+func TestLoadKeyring(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sg-keyring")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	keyFile := path.Join(dir, "keys.json")
+	data := `{"activeKey": "2", "keys": {"1": "` + testKey(1) + `", "2": "` + testKey(2) + `"}}`
+	require.NoError(t, ioutil.WriteFile(keyFile, []byte(data), 0600))
+
+	k, err := LoadKeyring(keyFile)
+	require.NoError(t, err)
+	require.Equal(t, "2", k.ActiveKeyID)
+	require.Len(t, k.ciphers, 2)
+}
+
+func TestEncryptedRepository(t *testing.T) {
+	r, cleanup := newTestFileRepository(t)
+	defer cleanup()
+
+	keyring, err := ParseKeyring("1:" + testKey(1))
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	e := NewEncryptedRepository(r, keyring, []string{"/secret/"})
+
+	require.NoError(t, e.Put(ctx, "/secret/", "token", []byte("1234")))
+	require.NoError(t, e.Put(ctx, "/public/", "name", []byte("name")))
+	require.NoError(t, e.Txn(ctx, PutOp("/secret/", "txn", []byte("5678"))))
+	require.NoError(t, e.Update(ctx, "/secret/", "token", func(current []byte) ([]byte, error) {
+		require.Equal(t, "1234", string(current))
+		return []byte("4321"), nil
+	}))
+
+	raw, err := r.Get(ctx, "/secret/", "token")
+	require.NoError(t, err)
+	require.True(t, bytes.HasPrefix(raw, encryptedMagic))
+	require.False(t, bytes.Contains(raw, []byte("4321")))
+
+	raw, err = r.Get(ctx, "/secret/", "txn")
+	require.NoError(t, err)
+	require.True(t, bytes.HasPrefix(raw, encryptedMagic))
+
+	raw, err = r.Get(ctx, "/public/", "name")
+	require.NoError(t, err)
+	require.Equal(t, "name", string(raw))
+
+	value, err := e.Get(ctx, "/secret/", "token")
+	require.NoError(t, err)
+	require.Equal(t, "4321", string(value))
+
+	values, err := e.GetAll(ctx, "/secret/")
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{[]byte("4321"), []byte("5678")}, values)
+}
+
+func TestEncryptedRepositoryRotate(t *testing.T) {
+	r, cleanup := newTestFileRepository(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	// Value written before encryption has been enabled
+	require.NoError(t, r.Put(ctx, "/secret/", "plain", []byte("plain")))
+
+	oldKeyring, err := ParseKeyring("1:" + testKey(1))
+	require.NoError(t, err)
+	require.NoError(t, NewEncryptedRepository(r, oldKeyring, []string{"/secret/"}).
+		Put(ctx, "/secret/", "old", []byte("old")))
+
+	keyring := &Keyring{
+		ActiveKeyID: "2",
+		Keys: map[string]string{
+			"1": testKey(1),
+			"2": testKey(2),
+		},
+	}
+	require.NoError(t, keyring.init())
+
+	e := NewEncryptedRepository(r, keyring, []string{"/secret/"})
+
+	count, err := e.Rotate(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+
+	count, err = e.Rotate(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 0, count)
+
+	// Old key is not needed anymore
+	newKeyring, err := ParseKeyring("2:" + testKey(2))
+	require.NoError(t, err)
+	e = NewEncryptedRepository(r, newKeyring, []string{"/secret/"})
+
+	kvs, err := e.List(ctx, "/secret/")
+	require.NoError(t, err)
+	require.Equal(t, "old", string(kvs[0].Value))
+	require.Equal(t, "plain", string(kvs[1].Value))
+}
+
+func TestEncryptedRepositoryBinding(t *testing.T) {
+	r, cleanup := newTestFileRepository(t)
+	defer cleanup()
+
+	// Keys with different ids share the material, only the id tells them apart
+	keyring := &Keyring{
+		ActiveKeyID: "1",
+		Keys: map[string]string{
+			"1": testKey(1),
+			"2": testKey(1),
+		},
+	}
+	require.NoError(t, keyring.init())
+
+	ctx := context.Background()
+	e := NewEncryptedRepository(r, keyring, []string{"/secret/"})
+	require.NoError(t, e.Put(ctx, "/secret/", "token", []byte("1234")))
+
+	raw, err := r.Get(ctx, "/secret/", "token")
+	require.NoError(t, err)
+
+	// Value copied to another record
+	require.NoError(t, r.Put(ctx, "/secret/", "other", raw))
+	_, err = e.Get(ctx, "/secret/", "other")
+	require.Error(t, err)
+
+	// Envelope given another key id
+	env := &envelope{}
+	require.NoError(t, json.Unmarshal(raw[len(encryptedMagic):], env))
+	env.KeyID = "2"
+	swapped, err := json.Marshal(env)
+	require.NoError(t, err)
+	require.NoError(t, r.Put(ctx, "/secret/", "token", append(append([]byte{}, encryptedMagic...), swapped...)))
+
+	_, err = e.Get(ctx, "/secret/", "token")
+	require.Error(t, err)
+}
@@ -0,0 +1,473 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/supergiant/supergiant/pkg/sgerrors"
+)
+
+// record is a single value stored in the file
+type record struct {
+	Value       []byte `json:"value"`
+	ModRevision int64  `json:"modRevision"`
+}
+
+// fileData is the on-disk layout of the file storage
+type fileData struct {
+	Revision int64              `json:"revision"`
+	Records  map[string]*record `json:"records"`
+}
+
+// FileRepository is an embedded key value storage that keeps all the data
+// in a single file on disk. It is meant for small single operator installs
+// where running a separate etcd is overkill. Every write is flushed to disk
+// atomically, keys have the same prefix semantics as in ETCDRepository.
+type FileRepository struct {
+	m    sync.RWMutex
+	path string
+	data *fileData
+
+	watchers map[*fileWatcher]struct{}
+}
+
+// NewFileRepository opens a file storage located at path, the file is created
+// on the first write if it does not exist yet.
+func NewFileRepository(path string) (*FileRepository, error) {
+	if path == "" {
+		return nil, errors.New("file storage path can't be empty")
+	}
+
+	r := &FileRepository{
+		path: path,
+		data: &fileData{
+			Records: make(map[string]*record),
+		},
+		watchers: make(map[*fileWatcher]struct{}),
+	}
+
+	if err := r.load(); err != nil {
+		return nil, errors.Wrapf(err, "load file storage %s", path)
+	}
+
+	return r, nil
+}
+
+func (r *FileRepository) Get(ctx context.Context, prefix string, key string) ([]byte, error) {
+	r.m.RLock()
+	defer r.m.RUnlock()
+
+	rec, ok := r.data.Records[prefix+key]
+	if !ok {
+		return nil, sgerrors.ErrNotFound
+	}
+
+	return copyBytes(rec.Value), nil
+}
+
+func (r *FileRepository) Put(ctx context.Context, prefix string, key string, value []byte) error {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	return r.put(prefix+key, value)
+}
+
+// put writes value to the key and flushes storage to disk, must be called under lock
+func (r *FileRepository) put(key string, value []byte) error {
+	return errors.Wrap(r.apply([]Op{PutOp("", key, value)}),
+		"failed to write to the file storage")
+}
+
+func (r *FileRepository) Update(ctx context.Context, prefix string, key string, fn UpdateFunc) error {
+	for i := 0; i < UpdateRetries; i++ {
+		var (
+			current     []byte
+			modRevision int64
+		)
+
+		r.m.RLock()
+		if rec, ok := r.data.Records[prefix+key]; ok {
+			current = copyBytes(rec.Value)
+			modRevision = rec.ModRevision
+		}
+		r.m.RUnlock()
+
+		// fn is called without lock so it can use the storage as well
+		value, err := fn(current)
+		if err != nil {
+			return err
+		}
+
+		ok, err := r.putIfModRevision(prefix+key, value, modRevision)
+		if err != nil {
+			return err
+		}
+
+		if ok {
+			return nil
+		}
+	}
+
+	return errors.Wrapf(sgerrors.ErrConflict, "update %s", prefix+key)
+}
+
+// putIfModRevision writes the value only if the key has not been changed since modRevision,
+// zero modRevision means that key must not exist.
+func (r *FileRepository) putIfModRevision(key string, value []byte, modRevision int64) (bool, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	var actual int64
+	if rec, ok := r.data.Records[key]; ok {
+		actual = rec.ModRevision
+	}
+
+	if actual != modRevision {
+		return false, nil
+	}
+
+	return true, r.put(key, value)
+}
+
+// Delete removes all keys that start with prefix+key the same way
+// as ETCDRepository does.
+func (r *FileRepository) Delete(ctx context.Context, prefix string, key string) error {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	if len(r.keysWithPrefix(prefix+key)) == 0 {
+		return nil
+	}
+
+	return errors.Wrap(r.apply([]Op{DeleteOp(prefix, key)}),
+		"failed to delete from the file storage")
+}
+
+func (r *FileRepository) Txn(ctx context.Context, ops ...Op) error {
+	if len(ops) == 0 {
+		return nil
+	}
+
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	return errors.Wrap(r.apply(ops), "failed to commit transaction to the file storage")
+}
+
+// apply performs all operations at the single revision and flushes
+// the result to disk, memory is restored if flush fails. Must be called under lock.
+func (r *FileRepository) apply(ops []Op) error {
+	for _, op := range ops {
+		if op.Type != OpPut && op.Type != OpDelete {
+			return errors.Errorf("unknown operation type %s", op.Type)
+		}
+	}
+
+	// Remember previous state of all touched keys to be able to roll back
+	previous := make(map[string]*record)
+	events := make([]Event, 0, len(ops))
+	revision := r.data.Revision + 1
+
+	for _, op := range ops {
+		switch op.Type {
+		case OpPut:
+			key := op.Prefix + op.Key
+			if _, ok := previous[key]; !ok {
+				previous[key] = r.data.Records[key]
+			}
+
+			r.data.Records[key] = &record{
+				Value:       copyBytes(op.Value),
+				ModRevision: revision,
+			}
+			events = append(events, Event{
+				Type:     EventPut,
+				Key:      key,
+				Value:    copyBytes(op.Value),
+				Revision: revision,
+			})
+		case OpDelete:
+			for _, key := range r.keysWithPrefix(op.Prefix + op.Key) {
+				if _, ok := previous[key]; !ok {
+					previous[key] = r.data.Records[key]
+				}
+
+				delete(r.data.Records, key)
+				events = append(events, Event{
+					Type:     EventDelete,
+					Key:      key,
+					Revision: revision,
+				})
+			}
+		}
+	}
+
+	r.data.Revision = revision
+	if err := r.flush(); err != nil {
+		r.data.Revision--
+		for key, rec := range previous {
+			if rec == nil {
+				delete(r.data.Records, key)
+			} else {
+				r.data.Records[key] = rec
+			}
+		}
+		return err
+	}
+
+	for _, e := range events {
+		r.notify(e)
+	}
+
+	return nil
+}
+
+// GetAll returns values of all keys that start with prefix sorted by key.
+func (r *FileRepository) GetAll(ctx context.Context, prefix string) ([][]byte, error) {
+	r.m.RLock()
+	defer r.m.RUnlock()
+
+	keys := r.keysWithPrefix(prefix)
+	result := make([][]byte, 0, len(keys))
+
+	for _, k := range keys {
+		result = append(result, copyBytes(r.data.Records[k].Value))
+	}
+
+	return result, nil
+}
+
+func (r *FileRepository) List(ctx context.Context, prefix string) ([]KeyValue, error) {
+	r.m.RLock()
+	defer r.m.RUnlock()
+
+	keys := r.keysWithPrefix(prefix)
+	result := make([]KeyValue, 0, len(keys))
+
+	for _, k := range keys {
+		result = append(result, KeyValue{
+			Key:         k,
+			Value:       copyBytes(r.data.Records[k].Value),
+			ModRevision: r.data.Records[k].ModRevision,
+		})
+	}
+
+	return result, nil
+}
+
+func (r *FileRepository) Page(ctx context.Context, prefix string, limit int, continueToken string) ([]KeyValue, string, error) {
+	result := make([]KeyValue, 0)
+
+	last := ""
+	if continueToken != "" {
+		key, err := continueKey(prefix, continueToken)
+		if err != nil {
+			return result, "", err
+		}
+		last = key
+	}
+
+	r.m.RLock()
+	defer r.m.RUnlock()
+
+	keys := r.keysWithPrefix(prefix)
+	start := sort.SearchStrings(keys, last)
+	if start < len(keys) && keys[start] == last {
+		start++
+	}
+	keys = keys[start:]
+
+	next := ""
+	if limit > 0 && len(keys) > limit {
+		keys = keys[:limit]
+		next = ContinueToken(keys[len(keys)-1])
+	}
+
+	for _, k := range keys {
+		result = append(result, KeyValue{
+			Key:         k,
+			Value:       copyBytes(r.data.Records[k].Value),
+			ModRevision: r.data.Records[k].ModRevision,
+		})
+	}
+
+	return result, next, nil
+}
+
+func (r *FileRepository) Watch(ctx context.Context, prefix string) (<-chan Event, error) {
+	w := &fileWatcher{
+		prefix: prefix,
+		notify: make(chan struct{}, 1),
+		events: make(chan Event),
+	}
+
+	r.m.Lock()
+	r.watchers[w] = struct{}{}
+	r.m.Unlock()
+
+	go func() {
+		w.run(ctx)
+
+		r.m.Lock()
+		delete(r.watchers, w)
+		r.m.Unlock()
+	}()
+
+	return w.events, nil
+}
+
+// Close does nothing since every write is flushed to disk immediately.
+func (r *FileRepository) Close() error {
+	return nil
+}
+
+// notify passes event to all interested watchers, must be called under lock
+func (r *FileRepository) notify(e Event) {
+	for w := range r.watchers {
+		if strings.HasPrefix(e.Key, w.prefix) {
+			w.push(e)
+		}
+	}
+}
+
+// keysWithPrefix returns sorted list of keys, must be called under lock
+func (r *FileRepository) keysWithPrefix(prefix string) []string {
+	keys := make([]string, 0)
+	for k := range r.data.Records {
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func (r *FileRepository) load() error {
+	data, err := ioutil.ReadFile(r.path)
+
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if len(data) == 0 {
+		return nil
+	}
+
+	if err := json.Unmarshal(data, r.data); err != nil {
+		return err
+	}
+
+	if r.data.Records == nil {
+		r.data.Records = make(map[string]*record)
+	}
+
+	return nil
+}
+
+// flush writes whole storage to a temporary file and atomically
+// replaces the storage file with it, must be called under lock.
+func (r *FileRepository) flush() error {
+	data, err := json.Marshal(r.data)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(r.path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(dir, filepath.Base(r.path)+".tmp")
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	return os.Rename(f.Name(), r.path)
+}
+
+func copyBytes(b []byte) []byte {
+	if b == nil {
+		return nil
+	}
+
+	c := make([]byte, len(b))
+	copy(c, b)
+
+	return c
+}
+
+// fileWatcher queues events so that slow consumers never block writers
+type fileWatcher struct {
+	prefix string
+
+	m       sync.Mutex
+	pending []Event
+	notify  chan struct{}
+	events  chan Event
+}
+
+func (w *fileWatcher) push(e Event) {
+	w.m.Lock()
+	w.pending = append(w.pending, e)
+	w.m.Unlock()
+
+	select {
+	case w.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (w *fileWatcher) run(ctx context.Context) {
+	defer close(w.events)
+
+	for {
+		select {
+		case <-w.notify:
+		case <-ctx.Done():
+			return
+		}
+
+		w.m.Lock()
+		pending := w.pending
+		w.pending = nil
+		w.m.Unlock()
+
+		for _, e := range pending {
+			select {
+			case w.events <- e:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
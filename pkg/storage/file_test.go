@@ -0,0 +1,271 @@
+package storage
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/supergiant/supergiant/pkg/sgerrors"
+)
+
+func newTestFileRepository(t *testing.T) (*FileRepository, func()) {
+	dir, err := ioutil.TempDir("", "sg-storage")
+	require.NoError(t, err)
+
+	r, err := NewFileRepository(path.Join(dir, "supergiant.db"))
+	require.NoError(t, err)
+
+	return r, func() {
+		os.RemoveAll(dir)
+	}
+}
+
+func TestFileRepositoryGetPut(t *testing.T) {
+	r, cleanup := newTestFileRepository(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	_, err := r.Get(ctx, "/prefix/", "key")
+	require.True(t, sgerrors.IsNotFound(err))
+
+	require.NoError(t, r.Put(ctx, "/prefix/", "key", []byte("value")))
+
+	data, err := r.Get(ctx, "/prefix/", "key")
+	require.NoError(t, err)
+	require.Equal(t, "value", string(data))
+}
+
+func TestFileRepositoryGetAll(t *testing.T) {
+	r, cleanup := newTestFileRepository(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	require.NoError(t, r.Put(ctx, "/kube/", "b", []byte("2")))
+	require.NoError(t, r.Put(ctx, "/kube/", "a", []byte("1")))
+	require.NoError(t, r.Put(ctx, "/account/", "a", []byte("3")))
+
+	values, err := r.GetAll(ctx, "/kube/")
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{[]byte("1"), []byte("2")}, values)
+
+	values, err = r.GetAll(ctx, "/unknown/")
+	require.NoError(t, err)
+	require.Len(t, values, 0)
+
+	kvs, err := r.List(ctx, "/kube/")
+	require.NoError(t, err)
+	require.Equal(t, []KeyValue{
+		{Key: "/kube/a", Value: []byte("1"), ModRevision: 2},
+		{Key: "/kube/b", Value: []byte("2"), ModRevision: 1},
+	}, kvs)
+}
+
+func TestFileRepositoryDeleteWithPrefix(t *testing.T) {
+	r, cleanup := newTestFileRepository(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	require.NoError(t, r.Put(ctx, "/kube/", "test", []byte("1")))
+	require.NoError(t, r.Put(ctx, "/kube/", "test-2", []byte("2")))
+	require.NoError(t, r.Put(ctx, "/kube/", "other", []byte("3")))
+
+	require.NoError(t, r.Delete(ctx, "/kube/", "test"))
+
+	values, err := r.GetAll(ctx, "/kube/")
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{[]byte("3")}, values)
+}
+
+func TestFileRepositoryPersistence(t *testing.T) {
+	r, cleanup := newTestFileRepository(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	require.NoError(t, r.Put(ctx, "/user/", "root", []byte("root")))
+
+	reopened, err := NewFileRepository(r.path)
+	require.NoError(t, err)
+
+	data, err := reopened.Get(ctx, "/user/", "root")
+	require.NoError(t, err)
+	require.Equal(t, "root", string(data))
+}
+
+func TestFileRepositoryWatch(t *testing.T) {
+	r, cleanup := newTestFileRepository(t)
+	defer cleanup()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events, err := r.Watch(ctx, "/kube/")
+	require.NoError(t, err)
+
+	require.NoError(t, r.Put(context.Background(), "/account/", "acc", []byte("acc")))
+	require.NoError(t, r.Put(context.Background(), "/kube/", "test", []byte("1")))
+	require.NoError(t, r.Delete(context.Background(), "/kube/", "test"))
+
+	expected := []Event{
+		{Type: EventPut, Key: "/kube/test", Value: []byte("1"), Revision: 2},
+		{Type: EventDelete, Key: "/kube/test", Revision: 3},
+	}
+
+	for _, e := range expected {
+		select {
+		case actual := <-events:
+			require.Equal(t, e, actual)
+		case <-time.After(time.Second):
+			t.Fatalf("event %v has not been received", e)
+		}
+	}
+
+	cancel()
+	for range events {
+	}
+}
+
+func TestFileRepositoryUpdate(t *testing.T) {
+	r, cleanup := newTestFileRepository(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	require.NoError(t, r.Update(ctx, "/kube/", "test", func(current []byte) ([]byte, error) {
+		require.Nil(t, current)
+		return []byte("1"), nil
+	}))
+
+	attempts := 0
+	require.NoError(t, r.Update(ctx, "/kube/", "test", func(current []byte) ([]byte, error) {
+		attempts++
+		// Simulate concurrent modification on the first attempt
+		if attempts == 1 {
+			require.NoError(t, r.Put(ctx, "/kube/", "test", []byte("2")))
+		}
+		return append(current, '+'), nil
+	}))
+
+	data, err := r.Get(ctx, "/kube/", "test")
+	require.NoError(t, err)
+	require.Equal(t, "2+", string(data))
+	require.Equal(t, 2, attempts)
+
+	err = r.Update(ctx, "/kube/", "test", func(current []byte) ([]byte, error) {
+		return nil, sgerrors.ErrNotFound
+	})
+	require.True(t, sgerrors.IsNotFound(err))
+}
+
+func TestFileRepositoryUpdateConflict(t *testing.T) {
+	r, cleanup := newTestFileRepository(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	err := r.Update(ctx, "/kube/", "test", func(current []byte) ([]byte, error) {
+		require.NoError(t, r.Put(ctx, "/kube/", "test", []byte("concurrent")))
+		return []byte("mine"), nil
+	})
+	require.True(t, sgerrors.IsConflict(err))
+}
+
+func TestFileRepositoryTxn(t *testing.T) {
+	r, cleanup := newTestFileRepository(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	require.NoError(t, r.Put(ctx, "/tasks/", "1", []byte("task1")))
+	require.NoError(t, r.Put(ctx, "/tasks/", "2", []byte("task2")))
+
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	events, err := r.Watch(watchCtx, "/")
+	require.NoError(t, err)
+
+	require.NoError(t, r.Txn(ctx,
+		PutOp("/kube/", "test", []byte("kube")),
+		DeleteOp("/tasks/", ""),
+	))
+
+	data, err := r.Get(ctx, "/kube/", "test")
+	require.NoError(t, err)
+	require.Equal(t, "kube", string(data))
+
+	values, err := r.GetAll(ctx, "/tasks/")
+	require.NoError(t, err)
+	require.Len(t, values, 0)
+
+	// All changes of transaction share the same revision
+	for i := 0; i < 3; i++ {
+		select {
+		case e := <-events:
+			require.Equal(t, int64(3), e.Revision)
+		case <-time.After(time.Second):
+			t.Fatalf("event has not been received")
+		}
+	}
+
+	err = r.Txn(ctx, PutOp("/kube/", "other", []byte("kube")), Op{Type: "unknown"})
+	require.Error(t, err)
+
+	_, err = r.Get(ctx, "/kube/", "other")
+	require.True(t, sgerrors.IsNotFound(err))
+}
+
+func TestNewFileRepositoryEmptyPath(t *testing.T) {
+	_, err := NewFileRepository("")
+	require.Error(t, err)
+}
+
+func TestFileRepositoryPage(t *testing.T) {
+	r, cleanup := newTestFileRepository(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	for _, key := range []string{"a", "b", "c", "d", "e"} {
+		require.NoError(t, r.Put(ctx, "/kube/", key, []byte(key)))
+	}
+	require.NoError(t, r.Put(ctx, "/other/", "a", []byte("a")))
+
+	keys := make([]string, 0)
+	token := ""
+	pages := 0
+
+	for {
+		kvs, next, err := r.Page(ctx, "/kube/", 2, token)
+		require.NoError(t, err)
+		require.True(t, len(kvs) <= 2)
+
+		for _, kv := range kvs {
+			keys = append(keys, kv.Key)
+		}
+		pages++
+
+		if next == "" {
+			break
+		}
+		token = next
+	}
+
+	require.Equal(t, 3, pages)
+	require.Equal(t, []string{"/kube/a", "/kube/b", "/kube/c", "/kube/d", "/kube/e"}, keys)
+
+	kvs, next, err := r.Page(ctx, "/kube/", 0, ContinueToken("/kube/c"))
+	require.NoError(t, err)
+	require.Len(t, kvs, 2)
+	require.Empty(t, next)
+
+	_, _, err = r.Page(ctx, "/kube/", 2, ContinueToken("/other/a"))
+	require.True(t, sgerrors.IsInvalidContinue(err))
+
+	_, _, err = r.Page(ctx, "/kube/", 2, "not a token!")
+	require.True(t, sgerrors.IsInvalidContinue(err))
+}
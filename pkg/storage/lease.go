@@ -0,0 +1,187 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// LeasePrefix keeps leases of the replicas of the control plane
+const LeasePrefix = "/supergiant/leases/"
+
+// Lease is held by the holder until it expires, the holder renews the lease to keep it.
+// Expiration is checked by the clock of the replica, clocks of replicas must not drift
+// apart for more than the time leases are granted for.
+type Lease struct {
+	Holder  string    `json:"holder"`
+	Expires time.Time `json:"expires"`
+}
+
+// Expired tells whether the lease can be taken by another holder
+func (l *Lease) Expired(now time.Time) bool {
+	return l.Holder == "" || now.After(l.Expires)
+}
+
+var errLeaseHeld = errors.New("lease is held by another holder")
+
+// AcquireLease takes the lease of the key for ttl or renews it if the holder has it already,
+// false is returned when the lease is held by another holder and has not expired yet.
+func AcquireLease(ctx context.Context, s Interface, key, holder string, ttl time.Duration) (bool, error) {
+	err := s.Update(ctx, LeasePrefix, key, func(current []byte) ([]byte, error) {
+		now := time.Now()
+		if current != nil {
+			lease := &Lease{}
+			if err := json.Unmarshal(current, lease); err != nil {
+				return nil, errors.Wrapf(err, "unmarshal lease %s", key)
+			}
+
+			if lease.Holder != holder && !lease.Expired(now) {
+				return nil, errLeaseHeld
+			}
+		}
+
+		return json.Marshal(&Lease{
+			Holder:  holder,
+			Expires: now.Add(ttl),
+		})
+	})
+
+	if errors.Cause(err) == errLeaseHeld {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// ReleaseLease gives up the lease of the key so another holder can take it without waiting
+func ReleaseLease(ctx context.Context, s Interface, key, holder string) error {
+	err := s.Update(ctx, LeasePrefix, key, func(current []byte) ([]byte, error) {
+		lease := &Lease{}
+		if current == nil {
+			return nil, errLeaseHeld
+		}
+
+		if err := json.Unmarshal(current, lease); err != nil {
+			return nil, errors.Wrapf(err, "unmarshal lease %s", key)
+		}
+
+		if lease.Holder != holder {
+			return nil, errLeaseHeld
+		}
+
+		return json.Marshal(&Lease{})
+	})
+
+	if errors.Cause(err) == errLeaseHeld {
+		return nil
+	}
+
+	return err
+}
+
+// Elector campaigns for the lease of the key on behalf of the replica, the replica that
+// holds the lease is the leader. Leadership is lost when the lease can not be renewed in time.
+type Elector struct {
+	repository Interface
+	key        string
+	id         string
+	ttl        time.Duration
+
+	m      sync.Mutex
+	leader bool
+}
+
+func NewElector(repository Interface, key, id string, ttl time.Duration) *Elector {
+	return &Elector{
+		repository: repository,
+		key:        key,
+		id:         id,
+		ttl:        ttl,
+	}
+}
+
+// IsLeader tells whether the replica holds the lease
+func (e *Elector) IsLeader() bool {
+	e.m.Lock()
+	defer e.m.Unlock()
+
+	return e.leader
+}
+
+// Run campaigns until ctx is done, lead is run every time the replica becomes the leader
+// with the context that is cancelled as soon as the leadership is lost. TTL of the elector must be positive.
+func (e *Elector) Run(ctx context.Context, lead func(ctx context.Context)) {
+	// Lease is renewed a few times before it expires
+	ticker := time.NewTicker(e.ttl / 3)
+	defer ticker.Stop()
+
+	var (
+		cancel  = func() {}
+		done    chan struct{}
+		renewed time.Time
+	)
+
+	for {
+		start := time.Now()
+		ok, err := AcquireLease(ctx, e.repository, e.key, e.id, e.ttl)
+		if ok {
+			renewed = start
+		}
+
+		if err != nil && ctx.Err() == nil {
+			logrus.Errorf("acquire lease %s: %v", e.key, err)
+			// Leader stays in charge while its lease surely has not expired
+			ok = done != nil && time.Since(renewed) < e.ttl-e.ttl/3
+		}
+
+		switch {
+		case ok && done == nil:
+			logrus.Infof("replica %s has become the leader", e.id)
+			var leaderCtx context.Context
+			leaderCtx, cancel = context.WithCancel(ctx)
+			done = make(chan struct{})
+
+			go func(done chan struct{}) {
+				defer close(done)
+				lead(leaderCtx)
+			}(done)
+		case !ok && done != nil:
+			logrus.Infof("replica %s has lost the leadership", e.id)
+			cancel()
+			<-done
+			done = nil
+		}
+		e.setLeader(ok)
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			cancel()
+			if done != nil {
+				<-done
+			}
+			e.setLeader(false)
+
+			// Context of the replica is done already
+			if err := ReleaseLease(context.Background(), e.repository, e.key, e.id); err != nil {
+				logrus.Errorf("release lease %s: %v", e.key, err)
+			}
+			return
+		}
+	}
+}
+
+func (e *Elector) setLeader(leader bool) {
+	e.m.Lock()
+	defer e.m.Unlock()
+
+	e.leader = leader
+}
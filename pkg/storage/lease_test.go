@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAcquireLease(t *testing.T) {
+	r, cleanup := newTestFileRepository(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	ok, err := AcquireLease(ctx, r, "leader", "first", time.Hour)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	// Holder renews its lease, others have to wait
+	ok, err = AcquireLease(ctx, r, "leader", "first", time.Hour)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	ok, err = AcquireLease(ctx, r, "leader", "second", time.Hour)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	// Lease is free once released
+	require.NoError(t, ReleaseLease(ctx, r, "leader", "second"))
+	require.NoError(t, ReleaseLease(ctx, r, "leader", "first"))
+
+	ok, err = AcquireLease(ctx, r, "leader", "second", time.Millisecond)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	// Expired lease is taken over
+	time.Sleep(2 * time.Millisecond)
+	ok, err = AcquireLease(ctx, r, "leader", "first", time.Hour)
+	require.NoError(t, err)
+	require.True(t, ok)
+}
+
+func TestElector(t *testing.T) {
+	r, cleanup := newTestFileRepository(t)
+	defer cleanup()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	leading := make(chan string, 2)
+	lead := func(id string) func(context.Context) {
+		return func(ctx context.Context) {
+			leading <- id
+			<-ctx.Done()
+		}
+	}
+
+	first := NewElector(r, "leader", "first", 30*time.Millisecond)
+	firstDone := make(chan struct{})
+	go func() {
+		defer close(firstDone)
+		first.Run(ctx, lead("first"))
+	}()
+	require.Equal(t, "first", <-leading)
+	require.True(t, first.IsLeader())
+
+	second := NewElector(r, "leader", "second", 30*time.Millisecond)
+	secondCtx, secondCancel := context.WithCancel(context.Background())
+	defer secondCancel()
+	go second.Run(secondCtx, lead("second"))
+
+	time.Sleep(50 * time.Millisecond)
+	require.False(t, second.IsLeader())
+
+	// Stopped leader releases the lease for another replica
+	cancel()
+	<-firstDone
+	require.False(t, first.IsLeader())
+
+	select {
+	case id := <-leading:
+		require.Equal(t, "second", id)
+	case <-time.After(time.Second):
+		t.Fatal("second replica has not become the leader")
+	}
+}
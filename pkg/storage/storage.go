@@ -2,9 +2,14 @@ package storage
 
 import (
 	"context"
+	"encoding/base64"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/coreos/etcd/clientv3"
 	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
 
 	"github.com/supergiant/supergiant/pkg/sgerrors"
 )
@@ -13,13 +18,129 @@ import (
 // It is up to the services to do data conversion from
 type Interface interface {
 	GetAll(ctx context.Context, prefix string) ([][]byte, error)
+	// List returns keys and values of all keys that start with prefix sorted by key
+	List(ctx context.Context, prefix string) ([]KeyValue, error)
+	// Page returns at most limit keys that start with prefix sorted by key beginning right after
+	// the continue token together with the token of the next page, the token is empty on the last page.
+	// Zero limit returns all the remaining keys.
+	Page(ctx context.Context, prefix string, limit int, continueToken string) ([]KeyValue, string, error)
 	Get(ctx context.Context, prefix string, key string) ([]byte, error)
 	Put(ctx context.Context, prefix string, key string, value []byte) error
 	Delete(ctx context.Context, prefix string, key string) error
+	// Update reads current value of the key, passes it to fn and writes the result
+	// back only if the key has not been modified in between, otherwise the whole
+	// read-modify-write cycle is retried.
+	Update(ctx context.Context, prefix string, key string, fn UpdateFunc) error
+	// Txn applies all operations atomically, either all of them are applied or none.
+	Txn(ctx context.Context, ops ...Op) error
+	// Watch streams changes of all keys that start with prefix until ctx is done,
+	// the returned channel is closed when watching stops.
+	Watch(ctx context.Context, prefix string) (<-chan Event, error)
+	// Close releases resources held by the storage
+	Close() error
 }
 
+// KeyValue is a stored value with its full key
+type KeyValue struct {
+	Key         string
+	Value       []byte
+	ModRevision int64
+}
+
+// UpdateFunc gets current value of the key or nil if it does not exist
+// and returns a new value to be written.
+type UpdateFunc func(current []byte) ([]byte, error)
+
+// UpdateRetries is a number of attempts Update makes before giving up with sgerrors.ErrConflict
+const UpdateRetries = 10
+
+type OpType string
+
+const (
+	OpPut    OpType = "put"
+	OpDelete OpType = "delete"
+)
+
+// Op is a single write of a transaction, delete operation removes all
+// keys that start with Prefix+Key the same way as Delete does.
+type Op struct {
+	Type   OpType
+	Prefix string
+	Key    string
+	Value  []byte
+}
+
+func PutOp(prefix string, key string, value []byte) Op {
+	return Op{
+		Type:   OpPut,
+		Prefix: prefix,
+		Key:    key,
+		Value:  value,
+	}
+}
+
+func DeleteOp(prefix string, key string) Op {
+	return Op{
+		Type:   OpDelete,
+		Prefix: prefix,
+		Key:    key,
+	}
+}
+
+// ContinueToken returns the token of the page that starts after the key
+func ContinueToken(key string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(key))
+}
+
+// continueKey decodes the last key of the previous page from the token,
+// the key must belong to the prefix the page is requested for.
+func continueKey(prefix string, token string) (string, error) {
+	key, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || !strings.HasPrefix(string(key), prefix) {
+		return "", sgerrors.ErrInvalidContinue
+	}
+
+	return string(key), nil
+}
+
+type EventType string
+
+const (
+	EventPut    EventType = "put"
+	EventDelete EventType = "delete"
+)
+
+// Event describes a change of a single key, Revision is a revision of
+// the storage at which the change has happened.
+type Event struct {
+	Type     EventType
+	Key      string
+	Value    []byte
+	Revision int64
+}
+
+// ETCDConfig holds parameters of connection to the etcd cluster
+type ETCDConfig struct {
+	Endpoints []string
+	// DialTimeout limits time of establishing connection to the etcd
+	DialTimeout time.Duration
+	// RequestTimeout limits every single request to the etcd, zero means no limit
+	RequestTimeout time.Duration
+}
+
+// ETCDRepository keeps one long lived etcd client which is created on the
+// first request and is shared by all callers until Close is called.
 type ETCDRepository struct {
-	cfg clientv3.Config
+	cfg ETCDConfig
+
+	m      sync.Mutex
+	client *clientv3.Client
+
+	// watches that use the client are stopped before it is closed
+	wm        sync.Mutex
+	watches   map[int]context.CancelFunc
+	nextWatch int
+	watching  sync.WaitGroup
 }
 
 func (e *ETCDRepository) Get(ctx context.Context, prefix string, key string) ([]byte, error) {
@@ -27,10 +148,11 @@ func (e *ETCDRepository) Get(ctx context.Context, prefix string, key string) ([]
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to connect to the etcd")
 	}
-	defer cl.Close()
-	kv := clientv3.NewKV(cl)
 
-	res, err := kv.Get(ctx, prefix+key)
+	ctx, cancel := e.requestContext(ctx)
+	defer cancel()
+
+	res, err := cl.Get(ctx, prefix+key)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to read from the etcd")
 	}
@@ -45,30 +167,167 @@ func (e *ETCDRepository) Put(ctx context.Context, prefix string, key string, val
 	if err != nil {
 		return errors.Wrap(err, "failed to connect to the etcd")
 	}
-	defer cl.Close()
-	kv := clientv3.NewKV(cl)
 
-	_, err = kv.Put(ctx, prefix+key, string(value))
+	ctx, cancel := e.requestContext(ctx)
+	defer cancel()
+
+	_, err = cl.Put(ctx, prefix+key, string(value))
 	return errors.Wrap(err, "failed to write to the etcd")
 }
 
+func (e *ETCDRepository) Update(ctx context.Context, prefix string, key string, fn UpdateFunc) error {
+	cl, err := e.GetClient()
+	if err != nil {
+		return errors.Wrap(err, "failed to connect to the etcd")
+	}
+
+	for i := 0; i < UpdateRetries; i++ {
+		current, modRevision, err := e.getWithRevision(ctx, cl, prefix+key)
+		if err != nil {
+			return errors.Wrap(err, "failed to read from the etcd")
+		}
+
+		value, err := fn(current)
+		if err != nil {
+			return err
+		}
+
+		ok, err := e.putIfModRevision(ctx, cl, prefix+key, value, modRevision)
+		if err != nil {
+			return errors.Wrap(err, "failed to write to the etcd")
+		}
+
+		if ok {
+			return nil
+		}
+	}
+
+	return errors.Wrapf(sgerrors.ErrConflict, "update %s", prefix+key)
+}
+
+func (e *ETCDRepository) getWithRevision(ctx context.Context, cl *clientv3.Client, key string) ([]byte, int64, error) {
+	ctx, cancel := e.requestContext(ctx)
+	defer cancel()
+
+	res, err := cl.Get(ctx, key)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if res.Count == 0 {
+		return nil, 0, nil
+	}
+
+	return res.Kvs[0].Value, res.Kvs[0].ModRevision, nil
+}
+
+func (e *ETCDRepository) putIfModRevision(ctx context.Context, cl *clientv3.Client, key string, value []byte, modRevision int64) (bool, error) {
+	ctx, cancel := e.requestContext(ctx)
+	defer cancel()
+
+	// Mod revision of the key that does not exist is zero
+	res, err := cl.Txn(ctx).
+		If(clientv3.Compare(clientv3.ModRevision(key), "=", modRevision)).
+		Then(clientv3.OpPut(key, string(value))).
+		Commit()
+	if err != nil {
+		return false, err
+	}
+
+	return res.Succeeded, nil
+}
+
 func (e *ETCDRepository) Delete(ctx context.Context, prefix string, key string) error {
 	cl, err := e.GetClient()
 	if err != nil {
 		return errors.Wrap(err, "failed to connect to the etcd")
 	}
+
+	ctx, cancel := e.requestContext(ctx)
+	defer cancel()
+
 	_, err = cl.Delete(ctx, prefix+key, clientv3.WithPrefix())
-	return errors.Wrap(err, "failed to read from the etcd")
+	return errors.Wrap(err, "failed to delete from the etcd")
+}
+
+func (e *ETCDRepository) Txn(ctx context.Context, ops ...Op) error {
+	if len(ops) == 0 {
+		return nil
+	}
+
+	cl, err := e.GetClient()
+	if err != nil {
+		return errors.Wrap(err, "failed to connect to the etcd")
+	}
+
+	etcdOps := make([]clientv3.Op, 0, len(ops))
+	for _, op := range ops {
+		switch op.Type {
+		case OpPut:
+			etcdOps = append(etcdOps, clientv3.OpPut(op.Prefix+op.Key, string(op.Value)))
+		case OpDelete:
+			etcdOps = append(etcdOps, clientv3.OpDelete(op.Prefix+op.Key, clientv3.WithPrefix()))
+		default:
+			return errors.Errorf("unknown operation type %s", op.Type)
+		}
+	}
+
+	ctx, cancel := e.requestContext(ctx)
+	defer cancel()
+
+	_, err = cl.Txn(ctx).Then(etcdOps...).Commit()
+	return errors.Wrap(err, "failed to commit transaction to the etcd")
 }
 
+// GetClient returns shared etcd client connecting to the etcd on the first call,
+// the client is owned by the repository and must not be closed by the caller.
 func (e *ETCDRepository) GetClient() (*clientv3.Client, error) {
-	client, err := clientv3.New(e.cfg)
+	e.m.Lock()
+	defer e.m.Unlock()
+
+	return e.getClient()
+}
+
+// getClient is called with the lock held
+func (e *ETCDRepository) getClient() (*clientv3.Client, error) {
+	if e.client != nil {
+		return e.client, nil
+	}
+
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints:   e.cfg.Endpoints,
+		DialTimeout: e.cfg.DialTimeout,
+	})
 	if err != nil {
 		return nil, err
 	}
+	e.client = client
+
 	return client, nil
 }
 
+// Close stops watches and closes connection to the etcd, repository reconnects on the next request.
+func (e *ETCDRepository) Close() error {
+	e.m.Lock()
+	defer e.m.Unlock()
+
+	e.wm.Lock()
+	for _, cancel := range e.watches {
+		cancel()
+	}
+	e.wm.Unlock()
+	e.watching.Wait()
+
+	if e.client == nil {
+		return nil
+	}
+
+	err := e.client.Close()
+	e.client = nil
+
+	return err
+}
+
 func (e *ETCDRepository) GetAll(ctx context.Context, prefix string) ([][]byte, error) {
 	result := make([][]byte, 0)
 
@@ -76,10 +335,11 @@ func (e *ETCDRepository) GetAll(ctx context.Context, prefix string) ([][]byte, e
 	if err != nil {
 		return result, errors.Wrap(err, "failed to connect to the etcd")
 	}
-	defer cl.Close()
-	kv := clientv3.NewKV(cl)
 
-	r, err := kv.Get(ctx, prefix, clientv3.WithPrefix())
+	ctx, cancel := e.requestContext(ctx)
+	defer cancel()
+
+	r, err := cl.Get(ctx, prefix, clientv3.WithPrefix())
 	if err != nil {
 		return result, errors.Wrap(err, "failed to read from the etcd")
 	}
@@ -89,7 +349,165 @@ func (e *ETCDRepository) GetAll(ctx context.Context, prefix string) ([][]byte, e
 	return result, nil
 }
 
-func NewETCDRepository(cfg clientv3.Config) Interface {
+func (e *ETCDRepository) List(ctx context.Context, prefix string) ([]KeyValue, error) {
+	result := make([]KeyValue, 0)
+
+	cl, err := e.GetClient()
+	if err != nil {
+		return result, errors.Wrap(err, "failed to connect to the etcd")
+	}
+
+	ctx, cancel := e.requestContext(ctx)
+	defer cancel()
+
+	r, err := cl.Get(ctx, prefix, clientv3.WithPrefix())
+	if err != nil {
+		return result, errors.Wrap(err, "failed to read from the etcd")
+	}
+	for _, v := range r.Kvs {
+		result = append(result, KeyValue{
+			Key:         string(v.Key),
+			Value:       v.Value,
+			ModRevision: v.ModRevision,
+		})
+	}
+	return result, nil
+}
+
+func (e *ETCDRepository) Page(ctx context.Context, prefix string, limit int, continueToken string) ([]KeyValue, string, error) {
+	result := make([]KeyValue, 0)
+
+	start := prefix
+	if continueToken != "" {
+		last, err := continueKey(prefix, continueToken)
+		if err != nil {
+			return result, "", err
+		}
+		// Smallest key that is greater than the last one
+		start = last + "\x00"
+	}
+
+	cl, err := e.GetClient()
+	if err != nil {
+		return result, "", errors.Wrap(err, "failed to connect to the etcd")
+	}
+
+	ctx, cancel := e.requestContext(ctx)
+	defer cancel()
+
+	opts := []clientv3.OpOption{
+		clientv3.WithRange(clientv3.GetPrefixRangeEnd(prefix)),
+		clientv3.WithSort(clientv3.SortByKey, clientv3.SortAscend),
+	}
+	if limit > 0 {
+		opts = append(opts, clientv3.WithLimit(int64(limit)))
+	}
+
+	r, err := cl.Get(ctx, start, opts...)
+	if err != nil {
+		return result, "", errors.Wrap(err, "failed to read from the etcd")
+	}
+	for _, v := range r.Kvs {
+		result = append(result, KeyValue{
+			Key:         string(v.Key),
+			Value:       v.Value,
+			ModRevision: v.ModRevision,
+		})
+	}
+
+	next := ""
+	if r.More && len(result) > 0 {
+		next = ContinueToken(result[len(result)-1].Key)
+	}
+
+	return result, next, nil
+}
+
+// Watch is not limited by the request timeout, it lasts until ctx is done or the repository is closed.
+func (e *ETCDRepository) Watch(ctx context.Context, prefix string) (<-chan Event, error) {
+	e.m.Lock()
+	cl, err := e.getClient()
+	if err != nil {
+		e.m.Unlock()
+		return nil, errors.Wrap(err, "failed to connect to the etcd")
+	}
+	ctx, id := e.addWatch(ctx)
+	e.m.Unlock()
+
+	events := make(chan Event)
+	watchChan := cl.Watch(ctx, prefix, clientv3.WithPrefix())
+
+	go func() {
+		defer e.removeWatch(id)
+		defer close(events)
+
+		for resp := range watchChan {
+			if err := resp.Err(); err != nil {
+				logrus.Errorf("watch %s: %v", prefix, err)
+				return
+			}
+
+			for _, ev := range resp.Events {
+				e := Event{
+					Type:     EventPut,
+					Key:      string(ev.Kv.Key),
+					Value:    ev.Kv.Value,
+					Revision: ev.Kv.ModRevision,
+				}
+
+				if ev.Type == clientv3.EventTypeDelete {
+					e.Type = EventDelete
+				}
+
+				select {
+				case events <- e:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return events, nil
+}
+
+// addWatch makes the watch stop on Close, it is called with the lock held
+func (e *ETCDRepository) addWatch(ctx context.Context) (context.Context, int) {
+	e.wm.Lock()
+	defer e.wm.Unlock()
+
+	if e.watches == nil {
+		e.watches = make(map[int]context.CancelFunc)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	id := e.nextWatch
+	e.nextWatch++
+	e.watches[id] = cancel
+	e.watching.Add(1)
+
+	return ctx, id
+}
+
+func (e *ETCDRepository) removeWatch(id int) {
+	e.wm.Lock()
+	defer e.wm.Unlock()
+
+	e.watches[id]()
+	delete(e.watches, id)
+	e.watching.Done()
+}
+
+// requestContext limits ctx with the request timeout if it is configured
+func (e *ETCDRepository) requestContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if e.cfg.RequestTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, e.cfg.RequestTimeout)
+}
+
+func NewETCDRepository(cfg ETCDConfig) *ETCDRepository {
 	return &ETCDRepository{
 		cfg: cfg,
 	}
@@ -0,0 +1,37 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestETCDRepositoryCloseNotConnected(t *testing.T) {
+	r := NewETCDRepository(ETCDConfig{
+		Endpoints: []string{"127.0.0.1:2379"},
+	})
+
+	require.NoError(t, r.Close())
+}
+
+func TestETCDRepositoryRequestContext(t *testing.T) {
+	r := NewETCDRepository(ETCDConfig{
+		RequestTimeout: time.Minute,
+	})
+
+	ctx, cancel := r.requestContext(context.Background())
+	defer cancel()
+
+	deadline, ok := ctx.Deadline()
+	require.True(t, ok)
+	require.True(t, time.Until(deadline) <= time.Minute)
+
+	r = NewETCDRepository(ETCDConfig{})
+	ctx, cancel = r.requestContext(context.Background())
+	defer cancel()
+
+	_, ok = ctx.Deadline()
+	require.False(t, ok)
+}
@@ -0,0 +1,173 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+)
+
+// versionedMagic is the beginning of every versioned record, json.Marshal
+// keeps fields in the declaration order so it is enough to detect an envelope.
+var versionedMagic = []byte(`{"schemaVersion":`)
+
+// versionedRecord is an envelope that keeps schema version of a record next to its data
+type versionedRecord struct {
+	SchemaVersion int             `json:"schemaVersion"`
+	Data          json.RawMessage `json:"data"`
+}
+
+// WrapVersion puts JSON data to the envelope of the schema version,
+// values that are not valid JSON are returned as is.
+func WrapVersion(version int, data []byte) ([]byte, error) {
+	if !json.Valid(data) {
+		return data, nil
+	}
+
+	return json.Marshal(&versionedRecord{
+		SchemaVersion: version,
+		Data:          data,
+	})
+}
+
+// UnwrapVersion returns schema version and data of the record, records
+// written before versioning has been introduced have zero version.
+func UnwrapVersion(value []byte) (int, []byte) {
+	if !bytes.HasPrefix(value, versionedMagic) {
+		return 0, value
+	}
+
+	r := &versionedRecord{}
+	if err := json.Unmarshal(value, r); err != nil || r.Data == nil {
+		return 0, value
+	}
+
+	return r.SchemaVersion, r.Data
+}
+
+// VersionedRepository stores every JSON value in the envelope of the current schema
+// version and strips the envelope on read so services keep working with raw structs.
+type VersionedRepository struct {
+	Interface
+
+	version int
+}
+
+// NewVersionedRepository wraps storage so that all values are written with the schema version
+func NewVersionedRepository(s Interface, version int) *VersionedRepository {
+	return &VersionedRepository{
+		Interface: s,
+		version:   version,
+	}
+}
+
+func (v *VersionedRepository) Get(ctx context.Context, prefix string, key string) ([]byte, error) {
+	value, err := v.Interface.Get(ctx, prefix, key)
+	if err != nil {
+		return nil, err
+	}
+
+	_, data := UnwrapVersion(value)
+	return data, nil
+}
+
+func (v *VersionedRepository) GetAll(ctx context.Context, prefix string) ([][]byte, error) {
+	values, err := v.Interface.GetAll(ctx, prefix)
+	if err != nil {
+		return values, err
+	}
+
+	for i := range values {
+		_, values[i] = UnwrapVersion(values[i])
+	}
+
+	return values, nil
+}
+
+func (v *VersionedRepository) List(ctx context.Context, prefix string) ([]KeyValue, error) {
+	kvs, err := v.Interface.List(ctx, prefix)
+	if err != nil {
+		return kvs, err
+	}
+
+	for i := range kvs {
+		_, kvs[i].Value = UnwrapVersion(kvs[i].Value)
+	}
+
+	return kvs, nil
+}
+
+func (v *VersionedRepository) Page(ctx context.Context, prefix string, limit int, continueToken string) ([]KeyValue, string, error) {
+	kvs, next, err := v.Interface.Page(ctx, prefix, limit, continueToken)
+	if err != nil {
+		return kvs, next, err
+	}
+
+	for i := range kvs {
+		_, kvs[i].Value = UnwrapVersion(kvs[i].Value)
+	}
+
+	return kvs, next, nil
+}
+
+func (v *VersionedRepository) Put(ctx context.Context, prefix string, key string, value []byte) error {
+	value, err := WrapVersion(v.version, value)
+	if err != nil {
+		return err
+	}
+
+	return v.Interface.Put(ctx, prefix, key, value)
+}
+
+func (v *VersionedRepository) Update(ctx context.Context, prefix string, key string, fn UpdateFunc) error {
+	return v.Interface.Update(ctx, prefix, key, func(current []byte) ([]byte, error) {
+		_, current = UnwrapVersion(current)
+
+		value, err := fn(current)
+		if err != nil {
+			return nil, err
+		}
+
+		return WrapVersion(v.version, value)
+	})
+}
+
+func (v *VersionedRepository) Txn(ctx context.Context, ops ...Op) error {
+	versioned := make([]Op, 0, len(ops))
+
+	for _, op := range ops {
+		if op.Type == OpPut {
+			value, err := WrapVersion(v.version, op.Value)
+			if err != nil {
+				return err
+			}
+			op.Value = value
+		}
+		versioned = append(versioned, op)
+	}
+
+	return v.Interface.Txn(ctx, versioned...)
+}
+
+func (v *VersionedRepository) Watch(ctx context.Context, prefix string) (<-chan Event, error) {
+	events, err := v.Interface.Watch(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	unwrapped := make(chan Event)
+	go func() {
+		defer close(unwrapped)
+
+		for ev := range events {
+			_, ev.Value = UnwrapVersion(ev.Value)
+
+			select {
+			case unwrapped <- ev:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return unwrapped, nil
+}
@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrapVersion(t *testing.T) {
+	value, err := WrapVersion(2, []byte(`{"name":"test"}`))
+	require.NoError(t, err)
+	require.Equal(t, `{"schemaVersion":2,"data":{"name":"test"}}`, string(value))
+
+	version, data := UnwrapVersion(value)
+	require.Equal(t, 2, version)
+	require.Equal(t, `{"name":"test"}`, string(data))
+
+	// Records written before versioning are returned as is
+	version, data = UnwrapVersion([]byte(`{"name":"test"}`))
+	require.Equal(t, 0, version)
+	require.Equal(t, `{"name":"test"}`, string(data))
+
+	value, err = WrapVersion(2, []byte("not json"))
+	require.NoError(t, err)
+	require.Equal(t, "not json", string(value))
+}
+
+func TestVersionedRepository(t *testing.T) {
+	r, cleanup := newTestFileRepository(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	v := NewVersionedRepository(r, 3)
+
+	require.NoError(t, r.Put(ctx, "/kube/", "legacy", []byte(`{"name":"legacy"}`)))
+	require.NoError(t, v.Put(ctx, "/kube/", "test", []byte(`{"name":"test"}`)))
+	require.NoError(t, v.Txn(ctx, PutOp("/kube/", "txn", []byte(`{"name":"txn"}`))))
+	require.NoError(t, v.Update(ctx, "/kube/", "legacy", func(current []byte) ([]byte, error) {
+		require.Equal(t, `{"name":"legacy"}`, string(current))
+		return []byte(`{"name":"updated"}`), nil
+	}))
+
+	for _, key := range []string{"legacy", "test", "txn"} {
+		raw, err := r.Get(ctx, "/kube/", key)
+		require.NoError(t, err)
+
+		version, _ := UnwrapVersion(raw)
+		require.Equal(t, 3, version, key)
+	}
+
+	values, err := v.GetAll(ctx, "/kube/")
+	require.NoError(t, err)
+	require.Equal(t, []string{`{"name":"updated"}`, `{"name":"test"}`, `{"name":"txn"}`},
+		[]string{string(values[0]), string(values[1]), string(values[2])})
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 
 	"github.com/stretchr/testify/mock"
+
+	"github.com/supergiant/supergiant/pkg/storage"
 )
 
 // Method names for MockStorage
@@ -11,7 +13,13 @@ const (
 	StoragePut    = "Put"
 	StorageGet    = "Get"
 	StorageGetAll = "GetAll"
+	StorageList   = "List"
+	StoragePage   = "Page"
 	StorageDelete = "Delete"
+	StorageUpdate = "Update"
+	StorageTxn    = "Txn"
+	StorageWatch  = "Watch"
+	StorageClose  = "Close"
 )
 
 // MockStorage is a reusable mock of storage.Interface
@@ -38,7 +46,53 @@ func (m *MockStorage) GetAll(ctx context.Context, prefix string) ([][]byte, erro
 	return args.Get(0).([][]byte), args.Error(1)
 }
 
+func (m *MockStorage) List(ctx context.Context, prefix string) ([]storage.KeyValue, error) {
+	args := m.Called(ctx, prefix)
+	val, ok := args.Get(0).([]storage.KeyValue)
+	if !ok {
+		return nil, args.Error(1)
+	}
+	return val, args.Error(1)
+}
+
+func (m *MockStorage) Page(ctx context.Context, prefix string, limit int, continueToken string) ([]storage.KeyValue, string, error) {
+	args := m.Called(ctx, prefix, limit, continueToken)
+	val, ok := args.Get(0).([]storage.KeyValue)
+	if !ok {
+		return nil, args.String(1), args.Error(2)
+	}
+	return val, args.String(1), args.Error(2)
+}
+
 func (m *MockStorage) Delete(ctx context.Context, prefix string, key string) error {
 	args := m.Called(ctx, prefix, key)
 	return args.Error(0)
 }
+
+func (m *MockStorage) Update(ctx context.Context, prefix string, key string, fn storage.UpdateFunc) error {
+	args := m.Called(ctx, prefix, key, fn)
+	// Update may return what fn returns the way storage does
+	if update, ok := args.Get(0).(func(storage.UpdateFunc) error); ok {
+		return update(fn)
+	}
+	return args.Error(0)
+}
+
+func (m *MockStorage) Txn(ctx context.Context, ops ...storage.Op) error {
+	args := m.Called(ctx, ops)
+	return args.Error(0)
+}
+
+func (m *MockStorage) Watch(ctx context.Context, prefix string) (<-chan storage.Event, error) {
+	args := m.Called(ctx, prefix)
+	val, ok := args.Get(0).(<-chan storage.Event)
+	if !ok {
+		return nil, args.Error(1)
+	}
+	return val, args.Error(1)
+}
+
+func (m *MockStorage) Close() error {
+	args := m.Called()
+	return args.Error(0)
+}
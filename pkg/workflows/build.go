@@ -0,0 +1,84 @@
+package workflows
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/supergiant/supergiant/pkg/sgerrors"
+	"github.com/supergiant/supergiant/pkg/storage"
+	"github.com/supergiant/supergiant/pkg/workflows/steps"
+)
+
+// BuildTaskType is the type of tasks composed by clients of registered steps
+const BuildTaskType = "build"
+
+// BuildWorkflow makes workflow of registered steps in the given order, step must go
+// after the steps it depends on, dependencies missing in the workflow are considered done.
+func BuildWorkflow(stepNames []string) (Workflow, error) {
+	if len(stepNames) == 0 {
+		return nil, errors.New("no steps to run")
+	}
+
+	workflow := make(Workflow, 0, len(stepNames))
+	positions := make(map[string]int, len(stepNames))
+	for i, name := range stepNames {
+		step := steps.GetStep(name)
+		if step == nil {
+			return nil, errors.Errorf("step %s is not registered", name)
+		}
+
+		workflow = append(workflow, step)
+		positions[name] = i
+	}
+
+	if err := Validate(workflow); err != nil {
+		return nil, err
+	}
+
+	for i, step := range workflow {
+		for _, dep := range step.Depends() {
+			if j, ok := positions[dep]; ok && j > i {
+				return nil, errors.Errorf("step %s must go after step %s it depends on", step.Name(), dep)
+			}
+		}
+	}
+
+	return workflow, nil
+}
+
+// stepNames returns names of the steps of the task in the order they were tracked
+func (w *Task) stepNames() []string {
+	names := make([]string, 0, len(w.StepStatuses))
+	for _, status := range w.StepStatuses {
+		names = append(names, status.StepName)
+	}
+
+	return names
+}
+
+// NodeConfig returns config of the latest task that has run on the node of the cluster,
+// it holds address of the node and the keys steps connect to the node with.
+func NodeConfig(ctx context.Context, repository storage.Interface, clusterName, nodeName string) (*steps.Config, error) {
+	tasks, _, err := ClusterTasks(ctx, repository, clusterName, 0, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var latest *Task
+	for _, task := range tasks {
+		if task.Config == nil || task.Config.Node.Name != nodeName || task.Config.Node.PublicIp == "" {
+			continue
+		}
+
+		if latest == nil || task.CreatedAt.After(latest.CreatedAt) {
+			latest = task
+		}
+	}
+
+	if latest == nil {
+		return nil, errors.Wrapf(sgerrors.ErrNotFound, "node %s of cluster %s", nodeName, clusterName)
+	}
+
+	return latest.Config, nil
+}
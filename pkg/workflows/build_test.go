@@ -0,0 +1,246 @@
+package workflows
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+
+	"github.com/supergiant/supergiant/pkg/node"
+	"github.com/supergiant/supergiant/pkg/runner"
+	"github.com/supergiant/supergiant/pkg/runner/ssh"
+	"github.com/supergiant/supergiant/pkg/sgerrors"
+	"github.com/supergiant/supergiant/pkg/storage"
+	"github.com/supergiant/supergiant/pkg/testutils"
+	"github.com/supergiant/supergiant/pkg/workflows/steps"
+)
+
+func registerBuildSteps() {
+	steps.RegisterStep("build_docker", &MockStep{name: "build_docker"})
+	steps.RegisterStep("build_kubelet", &MockStep{name: "build_kubelet", depends: []string{"build_docker"}})
+}
+
+func TestBuildWorkflow(t *testing.T) {
+	registerBuildSteps()
+
+	testCases := []struct {
+		description string
+		stepNames   []string
+		hasError    bool
+	}{
+		{
+			description: "no steps",
+			hasError:    true,
+		},
+		{
+			description: "unknown step",
+			stepNames:   []string{"build_docker", "unknown"},
+			hasError:    true,
+		},
+		{
+			description: "step goes before its dependency",
+			stepNames:   []string{"build_kubelet", "build_docker"},
+			hasError:    true,
+		},
+		{
+			description: "step is used twice",
+			stepNames:   []string{"build_docker", "build_docker"},
+			hasError:    true,
+		},
+		{
+			description: "dependency is not a part of workflow",
+			stepNames:   []string{"build_kubelet"},
+		},
+		{
+			description: "ordered steps",
+			stepNames:   []string{"build_docker", "build_kubelet"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		workflow, err := BuildWorkflow(testCase.stepNames)
+		if testCase.hasError {
+			require.Error(t, err, testCase.description)
+			continue
+		}
+
+		require.NoError(t, err, testCase.description)
+		require.Len(t, workflow, len(testCase.stepNames), testCase.description)
+	}
+}
+
+func putNodeTask(t *testing.T, repository storage.Interface) {
+	task := &Task{
+		ID:         "node-task",
+		Type:       "node",
+		Status:     steps.StatusSuccess,
+		repository: repository,
+		Config: &steps.Config{
+			ClusterName: "test",
+			Node: node.Node{
+				Name:     "test-node",
+				PublicIp: "10.20.30.40",
+			},
+			SshConfig: steps.SshConfig{
+				User:                "root",
+				Port:                "22",
+				BootstrapPrivateKey: "key",
+			},
+		},
+	}
+
+	require.NoError(t, task.sync(context.Background()))
+}
+
+func TestNodeConfig(t *testing.T) {
+	repository, cleanup := tempRepository(t)
+	defer cleanup()
+	putNodeTask(t, repository)
+
+	config, err := NodeConfig(context.Background(), repository, "test", "test-node")
+	require.NoError(t, err)
+	require.Equal(t, "10.20.30.40", config.Node.PublicIp)
+	require.Equal(t, "key", config.SshConfig.BootstrapPrivateKey)
+
+	_, err = NodeConfig(context.Background(), repository, "test", "unknown")
+	require.True(t, sgerrors.IsNotFound(err))
+}
+
+func TestTaskHandlerBuildTaskOnNode(t *testing.T) {
+	registerBuildSteps()
+	repository, cleanup := tempRepository(t)
+	defer cleanup()
+	putNodeTask(t, repository)
+
+	var sshConfig ssh.Config
+	h := &TaskHandler{
+		repository: repository,
+		runnerFactory: func(cfg ssh.Config) (runner.Runner, error) {
+			sshConfig = cfg
+			return &testutils.MockRunner{}, nil
+		},
+		getWriter: func(string) (io.WriteCloser, error) {
+			return &bufferCloser{}, nil
+		},
+	}
+	router := mux.NewRouter()
+	h.Register(router)
+
+	testCases := []struct {
+		description  string
+		req          map[string]interface{}
+		expectedCode int
+	}{
+		{
+			description: "wrong order",
+			req: map[string]interface{}{
+				"stepNames":   []string{"build_kubelet", "build_docker"},
+				"clusterName": "test",
+				"nodeName":    "test-node",
+			},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			description: "no cluster",
+			req: map[string]interface{}{
+				"stepNames": []string{"build_docker"},
+				"nodeName":  "test-node",
+			},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			description: "unknown node",
+			req: map[string]interface{}{
+				"stepNames":   []string{"build_docker"},
+				"clusterName": "test",
+				"nodeName":    "unknown",
+			},
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			description: "node of the cluster",
+			req: map[string]interface{}{
+				"stepNames":   []string{"build_docker", "build_kubelet"},
+				"clusterName": "test",
+				"nodeName":    "test-node",
+			},
+			expectedCode: http.StatusCreated,
+		},
+	}
+
+	for _, testCase := range testCases {
+		body := &bytes.Buffer{}
+		require.NoError(t, json.NewEncoder(body).Encode(testCase.req))
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/tasks/build", body)
+		router.ServeHTTP(rec, req)
+
+		require.Equal(t, testCase.expectedCode, rec.Code, testCase.description)
+	}
+
+	require.Equal(t, "10.20.30.40", sshConfig.Host)
+	require.Equal(t, []byte("key"), sshConfig.Key)
+}
+
+func TestDeserializeBuildTask(t *testing.T) {
+	registerBuildSteps()
+
+	data, err := json.Marshal(&Task{
+		ID:   "build",
+		Type: BuildTaskType,
+		StepStatuses: []StepStatus{
+			{StepName: "build_docker", Status: steps.StatusSuccess},
+			{StepName: "build_kubelet", Status: steps.StatusError},
+		},
+	})
+	require.NoError(t, err)
+
+	task, err := DeserializeTask(data, &MockRepository{})
+	require.NoError(t, err)
+	require.Len(t, task.workflow, 2)
+	require.Equal(t, "build_kubelet", task.workflow[1].Name())
+}
+
+// deadlineStep reports the time left until the deadline of the task
+type deadlineStep struct {
+	MockStep
+	left chan time.Duration
+}
+
+func (s *deadlineStep) Run(ctx context.Context, out io.Writer, config *steps.Config) error {
+	deadline, _ := ctx.Deadline()
+	s.left <- time.Until(deadline)
+	return nil
+}
+
+func TestTaskHandlerBuildTaskTimeout(t *testing.T) {
+	step := &deadlineStep{MockStep{name: "build_deadline"}, make(chan time.Duration, 1)}
+	steps.RegisterStep(step.Name(), step)
+
+	h := &TaskHandler{
+		repository: &MockRepository{storage: make(map[string][]byte)},
+		runnerFactory: func(cfg ssh.Config) (runner.Runner, error) {
+			return &testutils.MockRunner{}, nil
+		},
+		getWriter: func(string) (io.WriteCloser, error) {
+			return &bufferCloser{}, nil
+		},
+	}
+
+	body := bytes.NewBufferString(`{"stepNames": ["build_deadline"], "config": {"timeout": 600}}`)
+	rec := httptest.NewRecorder()
+	h.BuildAndRunTask(rec, httptest.NewRequest(http.MethodPost, "/tasks/build", body))
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	// Timeout is given in seconds
+	left := <-step.left
+	require.True(t, left > 9*time.Minute && left <= 10*time.Minute, "time left %v", left)
+}
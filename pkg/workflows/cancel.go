@@ -0,0 +1,77 @@
+package workflows
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/supergiant/supergiant/pkg/sgerrors"
+	"github.com/supergiant/supergiant/pkg/workflows/steps"
+)
+
+var (
+	// runningTasks holds tasks executed by this process, they can be cancelled by id
+	runningTasks = make(map[string]*Task)
+	runningMu    sync.RWMutex
+)
+
+// Cancel stops the task running in this process, in-flight steps are marked as
+// cancelled and rolled back when rollback is set. Steps that have not been started are left as is.
+func Cancel(id string, rollback bool) error {
+	runningMu.RLock()
+	w, ok := runningTasks[id]
+	runningMu.RUnlock()
+
+	if !ok {
+		return errors.Wrapf(sgerrors.ErrNotFound, "running task %s", id)
+	}
+
+	w.mu.Lock()
+	w.rollbackOnCancel = rollback
+	w.mu.Unlock()
+	w.cancel()
+
+	return nil
+}
+
+// IsRunning tells whether the task is being executed by this process
+func IsRunning(id string) bool {
+	runningMu.RLock()
+	defer runningMu.RUnlock()
+
+	_, ok := runningTasks[id]
+	return ok
+}
+
+// track makes the task cancellable, the returned context is cancelled by Cancel
+func (w *Task) track(ctx context.Context, id string) (context.Context, error) {
+	runningMu.Lock()
+	defer runningMu.Unlock()
+
+	if _, ok := runningTasks[id]; ok {
+		return nil, errors.Wrapf(sgerrors.ErrAlreadyExists, "running task %s", id)
+	}
+
+	ctx, w.cancel = context.WithCancel(ctx)
+	runningTasks[id] = w
+
+	return ctx, nil
+}
+
+func (w *Task) untrack(id string) {
+	runningMu.Lock()
+	defer runningMu.Unlock()
+
+	delete(runningTasks, id)
+	w.cancel()
+}
+
+// stopStatus tells whether the task has been cancelled or has failed for the other reason, e.g. timeout
+func stopStatus(ctx context.Context) steps.Status {
+	if ctx.Err() == context.Canceled {
+		return steps.StatusCancelled
+	}
+
+	return steps.StatusError
+}
@@ -0,0 +1,101 @@
+package workflows
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+
+	"github.com/supergiant/supergiant/pkg/sgerrors"
+	"github.com/supergiant/supergiant/pkg/workflows/steps"
+)
+
+func waitRunning(t *testing.T, task *Task, i int) {
+	for start := time.Now(); time.Since(start) < time.Second; time.Sleep(time.Millisecond) {
+		task.mu.Lock()
+		started := len(task.StepStatuses) > i && task.StepStatuses[i].Status == steps.StatusExecuting
+		task.mu.Unlock()
+
+		if started {
+			return
+		}
+	}
+
+	t.Fatalf("step %d has not been started", i)
+}
+
+func TestCancel(t *testing.T) {
+	for _, rollback := range []bool{true, false} {
+		blocking := &blockingStep{MockStep{name: "blocking"}}
+		task := &Task{
+			ID: "cancel",
+			repository: &MockRepository{
+				storage: make(map[string][]byte),
+			},
+			workflow: Workflow{
+				blocking,
+				&MockStep{name: "next", depends: []string{"blocking"}},
+			},
+		}
+
+		errChan := task.Run(context.Background(), steps.Config{}, &bufferCloser{})
+		waitRunning(t, task, 0)
+		require.True(t, IsRunning(task.ID))
+
+		require.NoError(t, Cancel(task.ID, rollback))
+
+		err := <-errChan
+		require.Error(t, err)
+		require.False(t, IsRunning(task.ID))
+
+		require.Equal(t, steps.StatusCancelled, task.Status)
+		require.Equal(t, steps.StatusCancelled, task.StepStatuses[0].Status)
+		require.Equal(t, steps.StatusTodo, task.StepStatuses[1].Status)
+		require.Equal(t, rollback, blocking.rollback)
+	}
+}
+
+func TestCancelNotRunning(t *testing.T) {
+	err := Cancel("unknown", false)
+	require.True(t, sgerrors.IsNotFound(err))
+}
+
+func TestTaskHandlerCancelTask(t *testing.T) {
+	task := &Task{
+		ID: "handler-cancel",
+		repository: &MockRepository{
+			storage: make(map[string][]byte),
+		},
+		workflow: Workflow{&blockingStep{MockStep{name: "blocking"}}},
+	}
+
+	errChan := task.Run(context.Background(), steps.Config{}, &bufferCloser{})
+	waitRunning(t, task, 0)
+
+	testCases := []struct {
+		url          string
+		expectedCode int
+	}{
+		{"/tasks/handler-cancel/cancel?rollback=maybe", http.StatusBadRequest},
+		{"/tasks/unknown/cancel", http.StatusNotFound},
+		{"/tasks/handler-cancel/cancel?rollback=true", http.StatusAccepted},
+	}
+
+	h := &TaskHandler{}
+	router := mux.NewRouter()
+	router.HandleFunc("/tasks/{id}/cancel", h.CancelTask)
+
+	for _, testCase := range testCases {
+		resp := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, testCase.url, nil)
+		router.ServeHTTP(resp, req)
+
+		require.Equal(t, testCase.expectedCode, resp.Code, testCase.url)
+	}
+
+	require.Error(t, <-errChan)
+}
@@ -0,0 +1,120 @@
+package workflows
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/supergiant/supergiant/pkg/workflows/steps"
+)
+
+// graph is a workflow turned into DAG, deps holds indices of the steps of the same workflow
+// every step depends on. Dependencies that are not part of the workflow are ignored, e.g.
+// ssh waits for machine creation only in workflows that create machines.
+type graph struct {
+	deps [][]int
+}
+
+// Validate checks that workflow steps are registered, every dependency
+// names a known step and dependencies of the steps do not form a cycle.
+func Validate(workflow Workflow) error {
+	_, err := newGraph(workflow)
+	return err
+}
+
+func newGraph(workflow Workflow) (*graph, error) {
+	indices := make(map[string]int, len(workflow))
+	for i, step := range workflow {
+		if step == nil {
+			return nil, errors.Errorf("step #%d is not registered", i)
+		}
+
+		if _, ok := indices[step.Name()]; ok {
+			return nil, errors.Errorf("step %s is used twice", step.Name())
+		}
+		indices[step.Name()] = i
+	}
+
+	g := &graph{
+		deps: make([][]int, len(workflow)),
+	}
+
+	for i, step := range workflow {
+		for _, dep := range step.Depends() {
+			j, ok := indices[dep]
+			if !ok {
+				if steps.GetStep(dep) == nil {
+					return nil, errors.Errorf("step %s depends on unknown step %s", step.Name(), dep)
+				}
+				continue
+			}
+
+			g.deps[i] = append(g.deps[i], j)
+		}
+	}
+
+	if cycle := g.cycle(); len(cycle) > 0 {
+		names := make([]string, 0, len(cycle))
+		for _, i := range cycle {
+			names = append(names, workflow[i].Name())
+		}
+
+		return nil, errors.Errorf("dependency cycle between steps %s", strings.Join(names, ", "))
+	}
+
+	return g, nil
+}
+
+// cycle returns steps that can never be started because of circular dependencies
+func (g *graph) cycle() []int {
+	done := make([]bool, len(g.deps))
+
+	for progress := true; progress; {
+		progress = false
+		for i := range g.deps {
+			if !done[i] && g.ready(i, func(j int) bool { return done[j] }) {
+				done[i] = true
+				progress = true
+			}
+		}
+	}
+
+	cycle := make([]int, 0)
+	for i := range done {
+		if !done[i] {
+			cycle = append(cycle, i)
+		}
+	}
+
+	return cycle
+}
+
+// order returns steps so that every step follows the steps it depends on
+func (g *graph) order() []int {
+	done := make([]bool, len(g.deps))
+	order := make([]int, 0, len(g.deps))
+
+	for progress := true; progress; {
+		progress = false
+		for i := range g.deps {
+			if !done[i] && g.ready(i, func(j int) bool { return done[j] }) {
+				done[i] = true
+				order = append(order, i)
+				progress = true
+			}
+		}
+	}
+
+	return order
+}
+
+// ready tells whether all dependencies of the step i are done
+func (g *graph) ready(i int, done func(int) bool) bool {
+	for _, j := range g.deps[i] {
+		if !done(j) {
+			return false
+		}
+	}
+
+	return true
+}
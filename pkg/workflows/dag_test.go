@@ -0,0 +1,251 @@
+package workflows
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/supergiant/supergiant/pkg/workflows/steps"
+)
+
+// trackingStep records the order of steps and the number of steps running at once
+type trackingStep struct {
+	MockStep
+	tracker *tracker
+	err     error
+}
+
+type tracker struct {
+	m           sync.Mutex
+	running     int
+	maxRunning  int
+	finished    []string
+	depsMissing []string
+}
+
+func (s *trackingStep) Run(ctx context.Context, out io.Writer, config *steps.Config) error {
+	s.tracker.start(s)
+	// Give independent steps a chance to overlap
+	time.Sleep(time.Millisecond * 10)
+	s.tracker.finish(s)
+
+	return s.err
+}
+
+func (t *tracker) start(s *trackingStep) {
+	t.m.Lock()
+	defer t.m.Unlock()
+
+	t.running++
+	if t.running > t.maxRunning {
+		t.maxRunning = t.running
+	}
+
+	for _, dep := range s.Depends() {
+		if !t.isFinished(dep) {
+			t.depsMissing = append(t.depsMissing, s.Name()+"->"+dep)
+		}
+	}
+}
+
+func (t *tracker) finish(s *trackingStep) {
+	t.m.Lock()
+	defer t.m.Unlock()
+
+	t.running--
+	t.finished = append(t.finished, s.Name())
+}
+
+func (t *tracker) isFinished(name string) bool {
+	for _, f := range t.finished {
+		if f == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+func newTrackingStep(t *tracker, name string, depends ...string) *trackingStep {
+	return &trackingStep{
+		MockStep: MockStep{name: name, depends: depends},
+		tracker:  t,
+	}
+}
+
+func TestValidate(t *testing.T) {
+	steps.RegisterStep("registered", &MockStep{name: "registered"})
+
+	testCases := []struct {
+		name     string
+		workflow Workflow
+		hasError bool
+	}{
+		{
+			name: "valid",
+			workflow: Workflow{
+				&MockStep{name: "a"},
+				&MockStep{name: "b", depends: []string{"a"}},
+				&MockStep{name: "c", depends: []string{"a", "b"}},
+			},
+		},
+		{
+			name: "registered dependency out of workflow",
+			workflow: Workflow{
+				&MockStep{name: "a", depends: []string{"registered"}},
+			},
+		},
+		{
+			name: "unknown dependency",
+			workflow: Workflow{
+				&MockStep{name: "a", depends: []string{"unknown"}},
+			},
+			hasError: true,
+		},
+		{
+			name: "cycle",
+			workflow: Workflow{
+				&MockStep{name: "a", depends: []string{"c"}},
+				&MockStep{name: "b", depends: []string{"a"}},
+				&MockStep{name: "c", depends: []string{"b"}},
+			},
+			hasError: true,
+		},
+		{
+			name: "duplicate step",
+			workflow: Workflow{
+				&MockStep{name: "a"},
+				&MockStep{name: "a"},
+			},
+			hasError: true,
+		},
+		{
+			name:     "unregistered step",
+			workflow: Workflow{nil},
+			hasError: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		err := Validate(testCase.workflow)
+		if testCase.hasError {
+			require.Error(t, err, testCase.name)
+		} else {
+			require.NoError(t, err, testCase.name)
+		}
+	}
+}
+
+func TestTaskRunParallel(t *testing.T) {
+	for _, limit := range []int{1, 2, DefaultParallelism} {
+		SetParallelism(limit)
+		tr := &tracker{}
+
+		task := &Task{
+			ID: "abcd",
+			repository: &MockRepository{
+				storage: make(map[string][]byte),
+			},
+			workflow: Workflow{
+				newTrackingStep(tr, "a"),
+				newTrackingStep(tr, "b", "a"),
+				newTrackingStep(tr, "c", "a"),
+				newTrackingStep(tr, "d", "a"),
+				newTrackingStep(tr, "e", "b", "c", "d"),
+			},
+		}
+
+		err := <-task.Run(context.Background(), steps.Config{}, &bufferCloser{})
+		require.NoError(t, err)
+
+		require.Empty(t, tr.depsMissing)
+		require.Len(t, tr.finished, 5)
+		require.Equal(t, "e", tr.finished[4])
+
+		expected := limit
+		if expected > 3 {
+			expected = 3
+		}
+		require.Equal(t, expected, tr.maxRunning, "parallelism %d", limit)
+
+		for _, status := range task.StepStatuses {
+			require.Equal(t, steps.StatusSuccess, status.Status)
+		}
+	}
+
+	SetParallelism(DefaultParallelism)
+}
+
+func TestTaskRunParallelError(t *testing.T) {
+	tr := &tracker{}
+	failed := newTrackingStep(tr, "b", "a")
+	failed.err = errors.New("error")
+
+	task := &Task{
+		ID: "abcd",
+		repository: &MockRepository{
+			storage: make(map[string][]byte),
+		},
+		workflow: Workflow{
+			newTrackingStep(tr, "a"),
+			failed,
+			newTrackingStep(tr, "c", "a"),
+			newTrackingStep(tr, "d", "b", "c"),
+		},
+	}
+
+	err := <-task.Run(context.Background(), steps.Config{}, &bufferCloser{})
+	require.Error(t, err)
+
+	require.True(t, failed.rollback)
+	require.Equal(t, steps.StatusSuccess, task.StepStatuses[0].Status)
+	require.Equal(t, steps.StatusError, task.StepStatuses[1].Status)
+	// Independent step is let to finish
+	require.Equal(t, steps.StatusSuccess, task.StepStatuses[2].Status)
+	require.Equal(t, steps.StatusTodo, task.StepStatuses[3].Status)
+	require.Equal(t, steps.StatusError, task.Status)
+}
+
+// configStep sets fields of the config the way provisioning steps do
+type configStep struct {
+	MockStep
+}
+
+func (s *configStep) Run(ctx context.Context, out io.Writer, config *steps.Config) error {
+	for i := 0; i < 100; i++ {
+		config.Lock()
+		config.Node.Name = s.name
+		config.EtcdConfig.Name = s.name
+		config.Unlock()
+		time.Sleep(time.Millisecond / 10)
+	}
+
+	return nil
+}
+
+// Steps setting the config in parallel must not race with saving the task, run with -race
+func TestTaskRunParallelConfig(t *testing.T) {
+	task := &Task{
+		ID: "abcd",
+		repository: &MockRepository{
+			storage: make(map[string][]byte),
+		},
+		workflow: Workflow{
+			&configStep{MockStep{name: "a"}},
+			&configStep{MockStep{name: "b"}},
+			newTrackingStep(&tracker{}, "c"),
+		},
+	}
+
+	err := <-task.Run(context.Background(), steps.Config{}, &bufferCloser{})
+	require.NoError(t, err)
+
+	for _, status := range task.StepStatuses {
+		require.Equal(t, steps.StatusSuccess, status.Status)
+	}
+}
@@ -0,0 +1,256 @@
+package workflows
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/supergiant/supergiant/pkg/workflows/steps"
+)
+
+// Definition describes a workflow in a YAML or JSON file. Steps run as DAG built from
+// their dependencies, when Ordered is set every step waits for the previous one instead.
+type Definition struct {
+	Name    string `json:"name"`
+	Ordered bool   `json:"ordered,omitempty"`
+	// Retry is used by steps that do not have their own retry policy
+	Retry *RetryPolicy     `json:"retry,omitempty"`
+	Steps []StepDefinition `json:"steps"`
+}
+
+type StepDefinition struct {
+	Name string `json:"name"`
+	// Depends replaces dependencies declared by the step when set
+	Depends []string `json:"depends,omitempty"`
+	// Timeout limits a single attempt of the step, zero means no limit
+	Timeout Duration     `json:"timeout,omitempty"`
+	Retry   *RetryPolicy `json:"retry,omitempty"`
+}
+
+// Duration is read from strings like 5m or 30s
+type Duration struct {
+	time.Duration
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return errors.Wrap(err, "duration must be a string like 5m")
+	}
+
+	duration, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	d.Duration = duration
+
+	return nil
+}
+
+// definedStep applies options of the workflow definition to the step
+type definedStep struct {
+	steps.Step
+	depends []string
+	timeout time.Duration
+	retry   *RetryPolicy
+}
+
+func (s *definedStep) Depends() []string {
+	if s.depends != nil {
+		return s.depends
+	}
+
+	return s.Step.Depends()
+}
+
+// Run applies timeout to a single attempt, attempts are made by the task according to retry policy
+func (s *definedStep) Run(ctx context.Context, out io.Writer, config *steps.Config) error {
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+
+	return s.Step.Run(ctx, out, config)
+}
+
+// retryPolicy returns retry policy of the workflow step, nil means no retries
+func retryPolicy(step steps.Step) *RetryPolicy {
+	if s, ok := step.(*definedStep); ok {
+		return s.retry
+	}
+
+	return nil
+}
+
+// Build makes workflow of the registered steps and validates it
+func (d Definition) Build() (Workflow, error) {
+	if d.Name == "" {
+		return nil, errors.New("workflow name is empty")
+	}
+
+	if d.Retry != nil {
+		if err := d.Retry.Validate(); err != nil {
+			return nil, errors.Wrapf(err, "workflow %s", d.Name)
+		}
+	}
+
+	workflow := make(Workflow, 0, len(d.Steps))
+	for i, stepDef := range d.Steps {
+		step := steps.GetStep(stepDef.Name)
+		if step == nil {
+			return nil, errors.Errorf("workflow %s: step %s is not registered", d.Name, stepDef.Name)
+		}
+
+		retry := d.Retry
+		if stepDef.Retry != nil {
+			if err := stepDef.Retry.Validate(); err != nil {
+				return nil, errors.Wrapf(err, "workflow %s: step %s", d.Name, stepDef.Name)
+			}
+			retry = stepDef.Retry
+		}
+
+		depends := stepDef.Depends
+		if d.Ordered {
+			depends = []string{}
+			if i > 0 {
+				depends = []string{d.Steps[i-1].Name}
+			}
+		}
+
+		if depends != nil || stepDef.Timeout.Duration > 0 || retry != nil {
+			step = &definedStep{
+				Step:    step,
+				depends: depends,
+				timeout: stepDef.Timeout.Duration,
+				retry:   retry,
+			}
+		}
+
+		workflow = append(workflow, step)
+	}
+
+	if err := Validate(workflow); err != nil {
+		return nil, errors.Wrapf(err, "workflow %s", d.Name)
+	}
+
+	return workflow, nil
+}
+
+// LoadDefinitions reads workflow definitions from .yaml, .yml and .json files of the directory
+func LoadDefinitions(dir string) ([]Definition, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	defs := make([]Definition, 0, len(files))
+	for _, f := range files {
+		ext := strings.ToLower(path.Ext(f.Name()))
+		if f.IsDir() || (ext != ".yaml" && ext != ".yml" && ext != ".json") {
+			continue
+		}
+
+		data, err := ioutil.ReadFile(path.Join(dir, f.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		def := Definition{}
+		// JSON is a subset of YAML
+		if err := yaml.Unmarshal(data, &def); err != nil {
+			return nil, errors.Wrapf(err, "read workflow %s", f.Name())
+		}
+		defs = append(defs, def)
+	}
+
+	return defs, nil
+}
+
+// LoadWorkflows registers workflows defined in files of the directory, they replace
+// built-in workflows with the same name. Missing directory is not an error.
+func LoadWorkflows(dir string) error {
+	defs, err := LoadDefinitions(dir)
+	if os.IsNotExist(err) {
+		logrus.Infof("workflows directory %s does not exist, only built-in workflows are used", dir)
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	// Build all workflows before registering any of them
+	workflows := make(map[string]Workflow, len(defs))
+	for _, def := range defs {
+		if _, ok := workflows[def.Name]; ok {
+			return errors.Errorf("workflow %s is defined twice", def.Name)
+		}
+
+		workflow, err := def.Build()
+		if err != nil {
+			return err
+		}
+		workflows[def.Name] = workflow
+	}
+
+	for name, workflow := range workflows {
+		logrus.Infof("register workflow %s from %s", name, dir)
+		RegisterWorkFlow(name, workflow)
+	}
+
+	return nil
+}
+
+// Definitions describes all registered workflows, dependencies
+// of the steps are listed as they are used to run the workflow.
+func Definitions() []Definition {
+	m.RLock()
+	defer m.RUnlock()
+
+	defs := make([]Definition, 0, len(workflowMap))
+	for name, workflow := range workflowMap {
+		def := Definition{
+			Name:  name,
+			Steps: make([]StepDefinition, 0, len(workflow)),
+		}
+
+		for _, step := range workflow {
+			if step == nil {
+				continue
+			}
+
+			stepDef := StepDefinition{
+				Name:    step.Name(),
+				Depends: step.Depends(),
+			}
+
+			if s, ok := step.(*definedStep); ok {
+				stepDef.Timeout = Duration{s.timeout}
+				stepDef.Retry = s.retry
+			}
+			def.Steps = append(def.Steps, stepDef)
+		}
+		defs = append(defs, def)
+	}
+
+	sort.Slice(defs, func(i, j int) bool {
+		return defs[i].Name < defs[j].Name
+	})
+
+	return defs
+}
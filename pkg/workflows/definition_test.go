@@ -0,0 +1,187 @@
+package workflows
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/supergiant/supergiant/pkg/workflows/steps"
+	"github.com/supergiant/supergiant/pkg/workflows/steps/preflight"
+	"github.com/supergiant/supergiant/pkg/workflows/steps/ssh"
+)
+
+const yamlDefinition = `
+name: Custom
+ordered: true
+steps:
+- name: definition_a
+- name: definition_b
+  timeout: 5m
+  retry:
+    maxAttempts: 3
+    backoff: 10s
+`
+
+const jsonDefinition = `{
+	"name": "CustomDAG",
+	"steps": [
+		{"name": "definition_a"},
+		{"name": "definition_b", "depends": ["definition_a"]}
+	]
+}`
+
+func registerDefinitionSteps() {
+	steps.RegisterStep("definition_a", &MockStep{name: "definition_a"})
+	steps.RegisterStep("definition_b", &MockStep{name: "definition_b"})
+}
+
+func TestLoadWorkflows(t *testing.T) {
+	registerDefinitionSteps()
+	workflowMap = make(map[string]Workflow)
+
+	dir, err := ioutil.TempDir("", "sg-workflows")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	require.NoError(t, ioutil.WriteFile(path.Join(dir, "custom.yaml"), []byte(yamlDefinition), 0600))
+	require.NoError(t, ioutil.WriteFile(path.Join(dir, "custom.json"), []byte(jsonDefinition), 0600))
+	require.NoError(t, ioutil.WriteFile(path.Join(dir, "README.md"), []byte("not a workflow"), 0600))
+
+	require.NoError(t, LoadWorkflows(dir))
+
+	ordered := GetWorkflow("Custom")
+	require.Len(t, ordered, 2)
+	require.Empty(t, ordered[0].Depends())
+	require.Equal(t, []string{"definition_a"}, ordered[1].Depends())
+
+	require.Len(t, GetWorkflow("CustomDAG"), 2)
+
+	defs := Definitions()
+	require.Len(t, defs, 2)
+	require.Equal(t, "Custom", defs[0].Name)
+	require.Equal(t, 5*time.Minute, defs[0].Steps[1].Timeout.Duration)
+	require.Equal(t, 3, defs[0].Steps[1].Retry.MaxAttempts)
+	require.Equal(t, 10*time.Second, defs[0].Steps[1].Retry.Backoff.Duration)
+}
+
+func TestLoadWorkflowsMissingDir(t *testing.T) {
+	require.NoError(t, LoadWorkflows("/path/does/not/exist"))
+}
+
+func TestDefinitionBuild(t *testing.T) {
+	registerDefinitionSteps()
+
+	testCases := []struct {
+		name     string
+		def      Definition
+		hasError bool
+	}{
+		{
+			name: "valid",
+			def: Definition{
+				Name:  "valid",
+				Steps: []StepDefinition{{Name: "definition_a"}, {Name: "definition_b"}},
+			},
+		},
+		{
+			name: "no name",
+			def: Definition{
+				Steps: []StepDefinition{{Name: "definition_a"}},
+			},
+			hasError: true,
+		},
+		{
+			name: "unknown step",
+			def: Definition{
+				Name:  "unknown",
+				Steps: []StepDefinition{{Name: "unknown"}},
+			},
+			hasError: true,
+		},
+		{
+			name: "invalid retry policy",
+			def: Definition{
+				Name:  "retry",
+				Retry: &RetryPolicy{RetryOn: []string{"("}},
+				Steps: []StepDefinition{{Name: "definition_a"}},
+			},
+			hasError: true,
+		},
+		{
+			name: "cycle",
+			def: Definition{
+				Name: "cycle",
+				Steps: []StepDefinition{
+					{Name: "definition_a", Depends: []string{"definition_b"}},
+					{Name: "definition_b", Depends: []string{"definition_a"}},
+				},
+			},
+			hasError: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		_, err := testCase.def.Build()
+		if testCase.hasError {
+			require.Error(t, err, testCase.name)
+		} else {
+			require.NoError(t, err, testCase.name)
+		}
+	}
+}
+
+type blockingStep struct {
+	MockStep
+}
+
+func (s *blockingStep) Run(ctx context.Context, out io.Writer, config *steps.Config) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestDefinedStepTimeout(t *testing.T) {
+	step := &definedStep{
+		Step:    &blockingStep{},
+		timeout: time.Millisecond,
+	}
+
+	err := step.Run(context.Background(), &bufferCloser{}, nil)
+	require.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestTaskHandlerListWorkflows(t *testing.T) {
+	registerDefinitionSteps()
+	workflowMap = make(map[string]Workflow)
+	RegisterWorkFlow("test", Workflow{&MockStep{name: "definition_a"}})
+
+	resp := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/workflows", nil)
+	(&TaskHandler{}).ListWorkflows(resp, req)
+
+	require.Equal(t, http.StatusOK, resp.Code)
+
+	defs := make([]Definition, 0)
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&defs))
+	require.Len(t, defs, 1)
+	require.Equal(t, "test", defs[0].Name)
+	require.Equal(t, "definition_a", defs[0].Steps[0].Name)
+}
+
+func TestAfterPreflight(t *testing.T) {
+	steps.RegisterStep(preflight.StepName, &MockStep{name: preflight.StepName, depends: []string{ssh.StepName}})
+	steps.RegisterStep("after_ssh", &MockStep{name: "after_ssh", depends: []string{ssh.StepName}})
+
+	require.Equal(t, []string{ssh.StepName, preflight.StepName},
+		afterPreflight("after_ssh", []string{ssh.StepName, preflight.StepName, "after_ssh"}))
+	require.Nil(t, afterPreflight("after_ssh", []string{ssh.StepName, "after_ssh"}))
+	require.Nil(t, afterPreflight(preflight.StepName, []string{ssh.StepName, preflight.StepName}))
+}
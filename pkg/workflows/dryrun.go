@@ -0,0 +1,121 @@
+package workflows
+
+import (
+	"context"
+	"io"
+
+	"github.com/pkg/errors"
+
+	"github.com/supergiant/supergiant/pkg/node"
+	"github.com/supergiant/supergiant/pkg/runner"
+	"github.com/supergiant/supergiant/pkg/util"
+	"github.com/supergiant/supergiant/pkg/workflows/steps"
+	"github.com/supergiant/supergiant/pkg/workflows/steps/amazon"
+	"github.com/supergiant/supergiant/pkg/workflows/steps/digitalocean"
+	"github.com/supergiant/supergiant/pkg/workflows/steps/ssh"
+)
+
+// Addresses of the machines faked in dry run, they belong to documentation and private ranges
+const (
+	DryRunPublicIp  = "203.0.113.10"
+	DryRunPrivateIp = "10.0.0.10"
+)
+
+// cloudSteps call cloud provider APIs or connect to machines, they are replaced by fakeStep in dry run
+var cloudSteps = map[string]bool{
+	digitalocean.CreateMachineStepName: true,
+	digitalocean.DeleteMachineStepName: true,
+	digitalocean.DeleteClusterStepName: true,
+	amazon.StepName:                    true,
+	amazon.StepNameCreateEC2Instance:   true,
+	ssh.StepName:                       true,
+}
+
+// createMachineSteps are faked with the machine that has never been created
+var createMachineSteps = map[string]bool{
+	digitalocean.CreateMachineStepName: true,
+	amazon.StepNameCreateEC2Instance:   true,
+}
+
+type stepIndexKey struct{}
+
+// recorder is the runner of the task in dry run, scripts are
+// stored in status of the step that renders them instead of being run.
+type recorder struct {
+	task *Task
+}
+
+func (r *recorder) Run(cmd *runner.Command) error {
+	i, ok := cmd.Ctx.Value(stepIndexKey{}).(int)
+	if !ok {
+		return errors.New("dry run: command has not been issued by a step")
+	}
+
+	r.task.mu.Lock()
+	r.task.StepStatuses[i].Scripts = append(r.task.StepStatuses[i].Scripts, cmd.Script)
+	r.task.mu.Unlock()
+
+	_, err := io.WriteString(cmd.Out, cmd.Script)
+	return err
+}
+
+// fakeStep does nothing but pretends the machine has been created, so the steps
+// that follow render their scripts as if they ran on the real machine.
+type fakeStep struct {
+	steps.Step
+}
+
+func (s *fakeStep) Run(ctx context.Context, out io.Writer, config *steps.Config) error {
+	if !createMachineSteps[s.Name()] || config.Node.PublicIp != "" {
+		return nil
+	}
+
+	role := node.RoleNode
+	if config.IsMaster {
+		role = node.RoleMaster
+	}
+
+	config.Lock()
+	config.Node = node.Node{
+		Id:        config.TaskId,
+		Name:      util.MakeNodeName(config.ClusterName, config.TaskId, config.IsMaster),
+		Role:      role,
+		Provider:  config.Provider,
+		State:     node.StateProvisioning,
+		PublicIp:  DryRunPublicIp,
+		PrivateIp: DryRunPrivateIp,
+	}
+	config.Unlock()
+
+	if config.IsMaster {
+		config.AddMaster(&config.Node)
+	} else {
+		config.AddNode(&config.Node)
+	}
+
+	return nil
+}
+
+func (s *fakeStep) Rollback(context.Context, io.Writer, *steps.Config) error {
+	return nil
+}
+
+// dryRun makes the task render scripts of its steps without running them
+func (w *Task) dryRun() {
+	workflow := make(Workflow, 0, len(w.workflow))
+	for _, step := range w.workflow {
+		if cloudSteps[step.Name()] {
+			step = &fakeStep{step}
+		}
+		workflow = append(workflow, step)
+	}
+
+	w.workflow = workflow
+	w.Config.Runner = &recorder{task: w}
+	// Fake machine is named after the task
+	if w.Config.TaskId == "" {
+		w.Config.TaskId = w.ID
+	}
+	// Task that runs on its own has nobody to read the node update of post start step
+	w.Config.Detach(1)
+}
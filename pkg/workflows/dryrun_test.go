@@ -0,0 +1,136 @@
+package workflows
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/supergiant/supergiant/pkg/clouds"
+	"github.com/supergiant/supergiant/pkg/model"
+	"github.com/supergiant/supergiant/pkg/util"
+	"github.com/supergiant/supergiant/pkg/workflows/steps"
+	"github.com/supergiant/supergiant/pkg/workflows/steps/digitalocean"
+)
+
+type templateStep struct {
+	MockStep
+	script *template.Template
+}
+
+func (s *templateStep) Run(ctx context.Context, out io.Writer, config *steps.Config) error {
+	return steps.RunTemplate(ctx, s.script, config.Runner, out, config)
+}
+
+func dryRunWorkflow() (Workflow, *MockStep) {
+	cloud := &MockStep{name: digitalocean.CreateMachineStepName}
+
+	return Workflow{
+		cloud,
+		&templateStep{
+			MockStep: MockStep{name: "render", depends: []string{digitalocean.CreateMachineStepName}},
+			script:   template.Must(template.New("").Parse("echo {{ .Node.PublicIp }} {{ .ClusterName }}")),
+		},
+	}, cloud
+}
+
+func TestTaskDryRun(t *testing.T) {
+	workflow, cloud := dryRunWorkflow()
+	task := &Task{
+		ID: "dry-run",
+		repository: &MockRepository{
+			storage: make(map[string][]byte),
+		},
+		workflow: workflow,
+	}
+
+	out := &bufferCloser{}
+	errChan := task.Run(context.Background(), steps.Config{
+		ClusterName: "test",
+		IsMaster:    true,
+		DryRun:      true,
+	}, out)
+	require.NoError(t, <-errChan)
+
+	require.Equal(t, 0, cloud.counter, "cloud step must not be run")
+	require.Equal(t, steps.StatusSuccess, task.StepStatuses[0].Status)
+	require.Empty(t, task.StepStatuses[0].Scripts)
+
+	expected := "echo " + DryRunPublicIp + " test"
+	require.Equal(t, []string{expected}, task.StepStatuses[1].Scripts)
+	require.Contains(t, out.String(), expected)
+
+	require.Equal(t, util.MakeNodeName("test", task.ID, true), task.Config.Node.Name)
+	require.NotNil(t, task.Config.GetMasters()[task.Config.Node.Name])
+
+	// Scripts are kept with the task
+	data, err := task.repository.Get(context.Background(), Prefix, task.ID)
+	require.NoError(t, err)
+
+	stored := &Task{}
+	require.NoError(t, json.Unmarshal(data, stored))
+	require.Equal(t, []string{expected}, stored.StepStatuses[1].Scripts)
+	require.True(t, stored.Config.DryRun)
+}
+
+func TestTaskHandlerRunTaskDryRun(t *testing.T) {
+	workflow, _ := dryRunWorkflow()
+	RegisterWorkFlow("dryrun", workflow)
+
+	repository, cleanup := tempRepository(t)
+	defer cleanup()
+
+	h := TaskHandler{
+		repository: repository,
+		cloudAccGetter: &mockCloudAccountService{
+			cloudAccount: &model.CloudAccount{
+				Name:     "testName",
+				Provider: clouds.DigitalOcean,
+				Credentials: map[string]string{
+					"fingerprints": "fingerprint",
+					"accessToken":  "abcd",
+				},
+			},
+		},
+		getWriter: func(string) (io.WriteCloser, error) {
+			return &bufferCloser{}, nil
+		},
+	}
+
+	body := &bytes.Buffer{}
+	require.NoError(t, json.NewEncoder(body).Encode(map[string]interface{}{
+		"workflowName": "dryrun",
+		"config": map[string]interface{}{
+			"clusterName": "test",
+			"dryRun":      true,
+		},
+	}))
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/tasks", body)
+	h.RunTask(rec, req)
+
+	require.Equal(t, http.StatusAccepted, rec.Code)
+
+	resp := &TaskResponse{}
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(resp))
+
+	task := &Task{}
+	for start := time.Now(); time.Since(start) < time.Second; time.Sleep(time.Millisecond) {
+		// Task is saved once it starts
+		data, err := repository.Get(context.Background(), Prefix, resp.ID)
+		if err == nil && json.Unmarshal(data, task) == nil && task.Status == steps.StatusSuccess {
+			break
+		}
+	}
+
+	require.Equal(t, steps.StatusSuccess, task.Status)
+	require.Equal(t, []string{"echo " + DryRunPublicIp + " test"}, task.StepStatuses[1].Scripts)
+}
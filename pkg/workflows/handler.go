@@ -9,12 +9,15 @@ import (
 	"net/http"
 	"os"
 	"path"
+	"strconv"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/gorilla/websocket"
 	"github.com/hpcloud/tail"
+	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
+	"github.com/supergiant/supergiant/pkg/api"
 	"github.com/supergiant/supergiant/pkg/message"
 	"github.com/supergiant/supergiant/pkg/model"
 	"github.com/supergiant/supergiant/pkg/runner"
@@ -45,6 +48,10 @@ type BuildTaskRequest struct {
 	StepNames []string     `json:"stepNames"`
 	Cfg       steps.Config `json:"config"`
 	SshConfig ssh.Config   `json:"sshConfig"`
+	// ClusterName and NodeName select the node of the cluster steps are run on,
+	// config and ssh config of the request are not used in that case.
+	ClusterName string `json:"clusterName,omitempty"`
+	NodeName    string `json:"nodeName,omitempty"`
 }
 
 type TaskResponse struct {
@@ -65,10 +72,153 @@ func NewTaskHandler(repository storage.Interface, runnerFactory func(config ssh.
 
 func (h *TaskHandler) Register(m *mux.Router) {
 	m.HandleFunc("/tasks", h.RunTask).Methods(http.MethodPost)
+	m.HandleFunc("/tasks", h.ListTasks).Methods(http.MethodGet)
+	m.HandleFunc("/tasks/build", h.BuildAndRunTask).Methods(http.MethodPost)
 	m.HandleFunc("/tasks/{id}", h.GetTask).Methods(http.MethodGet)
 	m.HandleFunc("/tasks/{id}/restart", h.RestartTask).Methods(http.MethodPost)
+	m.HandleFunc("/tasks/{id}/cancel", h.CancelTask).Methods(http.MethodPost)
 	m.HandleFunc("/tasks/{id}/logs", h.StreamLogs).Methods(http.MethodGet)
 	m.HandleFunc("/tasks/{id}/logs/ws", h.GetLogs).Methods(http.MethodGet)
+	m.HandleFunc("/workflows", h.ListWorkflows).Methods(http.MethodGet)
+	m.HandleFunc("/metrics", h.Metrics).Methods(http.MethodGet)
+}
+
+// RegisterScripts adds handlers of script steps, they run on every machine
+// provisioned afterwards so the router must require authentication.
+func (h *TaskHandler) RegisterScripts(m *mux.Router) {
+	m.HandleFunc("/steps", h.CreateScript).Methods(http.MethodPost)
+	m.HandleFunc("/steps", h.ListScripts).Methods(http.MethodGet)
+}
+
+// Metrics exposes duration histograms of steps and tasks in Prometheus text format
+func (h *TaskHandler) Metrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	if err := WriteMetrics(w); err != nil {
+		logrus.Error(err)
+	}
+}
+
+// ListWorkflows describes registered workflows and their steps
+func (h *TaskHandler) ListWorkflows(w http.ResponseWriter, r *http.Request) {
+	if err := json.NewEncoder(w).Encode(Definitions()); err != nil {
+		logrus.Error(err)
+	}
+}
+
+// CreateScript saves the script step, it can be used by workflows and profiles right away
+func (h *TaskHandler) CreateScript(w http.ResponseWriter, r *http.Request) {
+	script := Script{}
+	if err := json.NewDecoder(r.Body).Decode(&script); err != nil {
+		message.SendInvalidJSON(w, err)
+		return
+	}
+
+	if _, err := NewScriptStep(script); err != nil {
+		message.SendValidationFailed(w, err)
+		return
+	}
+
+	step, err := RegisterScript(r.Context(), h.repository, script)
+	if err != nil {
+		message.SendUnknownError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(step.Script()); err != nil {
+		logrus.Error(err)
+	}
+}
+
+// ListScripts returns saved script steps
+func (h *TaskHandler) ListScripts(w http.ResponseWriter, r *http.Request) {
+	scripts, err := Scripts(r.Context(), h.repository)
+	if err != nil {
+		message.SendUnknownError(w, err)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(scripts); err != nil {
+		logrus.Error(err)
+	}
+}
+
+// ListTasks returns a page of tasks filtered by type, status, cluster, node and
+// creation time query parameters and sorted by the sort query parameter.
+func (h *TaskHandler) ListTasks(w http.ResponseWriter, r *http.Request) {
+	page, err := api.ParsePage(r)
+	if err != nil {
+		message.SendValidationFailed(w, err)
+		return
+	}
+
+	filter, err := parseTaskFilter(r)
+	if err != nil {
+		message.SendValidationFailed(w, err)
+		return
+	}
+
+	sortField := r.URL.Query().Get("sort")
+	if sortField == "" {
+		sortField = DefaultSort
+	}
+
+	if !validSort(sortField) {
+		message.SendValidationFailed(w, errors.Errorf("tasks can not be sorted by %s", sortField))
+		return
+	}
+
+	tasks, next, err := ListTasks(r.Context(), h.repository, filter, sortField, page.Limit, page.Continue)
+	if err != nil {
+		if sgerrors.IsInvalidContinue(err) {
+			message.SendValidationFailed(w, err)
+			return
+		}
+
+		message.SendUnknownError(w, err)
+		return
+	}
+
+	resp := make([]TaskDTO, 0, len(tasks))
+	for _, task := range tasks {
+		resp = append(resp, NewTaskDTO(task))
+	}
+
+	api.SetContinue(w, next)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logrus.Error(err)
+	}
+}
+
+func parseTaskFilter(r *http.Request) (TaskFilter, error) {
+	query := r.URL.Query()
+	filter := TaskFilter{
+		Type:        query.Get("type"),
+		Status:      steps.Status(query.Get("status")),
+		ClusterName: query.Get("cluster"),
+		NodeName:    query.Get("node"),
+	}
+
+	for _, bound := range []struct {
+		param string
+		value *time.Time
+	}{
+		{"createdAfter", &filter.CreatedAfter},
+		{"createdBefore", &filter.CreatedBefore},
+	} {
+		value := query.Get(bound.param)
+		if value == "" {
+			continue
+		}
+
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return filter, errors.Errorf("%s must be a time in RFC3339 format, got %s", bound.param, value)
+		}
+		*bound.value = t
+	}
+
+	return filter, nil
 }
 
 func (h *TaskHandler) GetTask(w http.ResponseWriter, r *http.Request) {
@@ -126,6 +276,11 @@ func (h *TaskHandler) RunTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if req.Cfg.DryRun {
+		h.dryRun(w, r, task, req)
+		return
+	}
+
 	task.Run(context.Background(), req.Cfg, os.Stdout)
 
 	w.WriteHeader(http.StatusAccepted)
@@ -134,6 +289,35 @@ func (h *TaskHandler) RunTask(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// dryRun starts the task that renders scripts of its steps, the scripts are
+// recorded in the step statuses of the task that is read by its id.
+func (h *TaskHandler) dryRun(w http.ResponseWriter, r *http.Request, task *Task, req *RunTaskRequest) {
+	writer, err := h.getWriter(util.MakeFileName(task.ID))
+
+	if err != nil {
+		http.Error(w, fmt.Sprintf("get writer %v", err), http.StatusInternalServerError)
+		logrus.Errorf("Get writer %v", err)
+		return
+	}
+
+	// Task may wait for its turn, the request is not held until it is done
+	errChan := task.Run(context.Background(), req.Cfg, writer)
+
+	go func() {
+		// Writer is closed by the task only when it succeeds
+		defer writer.Close()
+
+		if err := <-errChan; err != nil {
+			logrus.Errorf("dry run of task %s: %v", task.ID, err)
+		}
+	}()
+
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(&TaskResponse{
+		task.ID,
+	})
+}
+
 func (h *TaskHandler) RestartTask(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, ok := vars["id"]
@@ -143,6 +327,20 @@ func (h *TaskHandler) RestartTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	force := false
+	if value := r.URL.Query().Get("force"); value != "" {
+		var err error
+		if force, err = strconv.ParseBool(value); err != nil {
+			message.SendValidationFailed(w, errors.Wrap(err, "force must be true or false"))
+			return
+		}
+	}
+
+	if IsRunning(id) {
+		http.Error(w, "task is running", http.StatusConflict)
+		return
+	}
+
 	data, err := h.repository.Get(r.Context(), Prefix, id)
 
 	if err != nil {
@@ -165,10 +363,39 @@ func (h *TaskHandler) RestartTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	task.Restart(context.Background(), id, writer)
+	task.Restart(context.Background(), id, writer, force)
 	w.WriteHeader(http.StatusAccepted)
 }
 
+// CancelTask stops the running task, the interrupted steps are
+// rolled back when rollback query parameter is set to true.
+func (h *TaskHandler) CancelTask(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	rollback := false
+	if value := r.URL.Query().Get("rollback"); value != "" {
+		var err error
+		if rollback, err = strconv.ParseBool(value); err != nil {
+			message.SendValidationFailed(w, errors.Wrap(err, "rollback must be true or false"))
+			return
+		}
+	}
+
+	if err := Cancel(id, rollback); err != nil {
+		if sgerrors.IsNotFound(err) {
+			message.SendNotFound(w, "running task", err)
+			return
+		}
+
+		message.SendUnknownError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// BuildAndRunTask runs task of the steps given by the client, steps connect either to the node
+// of the cluster given by name or to the machine described by ssh config of the request.
 func (h *TaskHandler) BuildAndRunTask(w http.ResponseWriter, r *http.Request) {
 	req := &BuildTaskRequest{}
 	err := json.NewDecoder(r.Body).Decode(req)
@@ -178,26 +405,79 @@ func (h *TaskHandler) BuildAndRunTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Create newTask sshRunner with config provided
-	sshRunner, err := h.runnerFactory(req.SshConfig)
+	workflow, err := BuildWorkflow(req.StepNames)
 
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		message.SendValidationFailed(w, err)
 		return
 	}
 
-	req.Cfg.Runner = sshRunner
-	s := make([]steps.Step, 0, len(req.StepNames))
-	// Get steps for task
-	for _, stepName := range req.StepNames {
-		s = append(s, steps.GetStep(stepName))
+	config := &req.Cfg
+	sshConfig := req.SshConfig
+
+	if req.NodeName != "" {
+		if req.ClusterName == "" {
+			message.SendValidationFailed(w, errors.New("need name of the cluster of the node"))
+			return
+		}
+
+		config, err = NodeConfig(r.Context(), h.repository, req.ClusterName, req.NodeName)
+
+		if err != nil {
+			if sgerrors.IsNotFound(err) {
+				message.SendNotFound(w, "node", err)
+				return
+			}
+
+			message.SendUnknownError(w, err)
+			return
+		}
+
+		config.Timeout = req.Cfg.Timeout
+		config.DryRun = req.Cfg.DryRun
+		config.RollbackOnFailure = req.Cfg.RollbackOnFailure
+		sshConfig = nodeSshConfig(config)
 	}
 
-	// TODO(stgleb): pass here workflow type DOMaster or DONode
-	task := newTask("", s, h.repository)
-	// We ignore cancel function since we cannot get it back
-	ctx, _ := context.WithTimeout(context.Background(), req.Cfg.Timeout*time.Second)
-	task.Run(ctx, req.Cfg, os.Stdout)
+	if !config.DryRun {
+		// Create newTask sshRunner with config provided
+		config.Runner, err = h.runnerFactory(sshConfig)
+
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	task := newTask(BuildTaskType, workflow, h.repository)
+	config.TaskId = task.ID
+	// Nobody reads states of the nodes the steps may send
+	config.Detach(len(workflow))
+
+	writer, err := h.getWriter(util.MakeFileName(task.ID))
+
+	if err != nil {
+		http.Error(w, fmt.Sprintf("get writer %v", err), http.StatusInternalServerError)
+		logrus.Errorf("Get writer %v", err)
+		return
+	}
+
+	ctx := context.Background()
+	cancel := func() {}
+	// Timeout of the request is given in seconds
+	if config.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, config.Timeout*time.Second)
+	}
+
+	errChan := task.Run(ctx, *config, writer)
+
+	go func() {
+		defer cancel()
+
+		if err := <-errChan; err != nil {
+			logrus.Errorf("task %s of steps %v: %v", task.ID, req.StepNames, err)
+		}
+	}()
 
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(&TaskResponse{
@@ -138,9 +138,9 @@ func TestTaskHandlerRunTask(t *testing.T) {
 
 func TestTaskHandlerRestartTask(t *testing.T) {
 	Init()
-	repository := &MockRepository{
-		make(map[string][]byte),
-	}
+	// Restarted tasks run at the same time, they need the storage that is safe for it
+	repository, cleanup := tempRepository(t)
+	defer cleanup()
 	h := TaskHandler{
 		runnerFactory: func(cfg ssh.Config) (runner.Runner, error) {
 			return &testutils.MockRunner{}, nil
@@ -223,6 +223,27 @@ dZM6MSCYh9kcT0pi2FPmY9iXba9kx4XAnf+0YB5xCz9QSMk4W5xSTBs=
 	data, _ := json.Marshal(task)
 	repository.Put(context.Background(), Prefix, task.ID, data)
 
+	// Copies of the task are restarted with force, the task itself may be running by then
+	forceCases := []struct {
+		force        string
+		expectedCode int
+	}{
+		{
+			force:        "maybe",
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			force:        "true",
+			expectedCode: http.StatusAccepted,
+		},
+	}
+
+	for _, forceCase := range forceCases {
+		task.ID = fmt.Sprintf("%s-force-%s", taskId, forceCase.force)
+		data, _ := json.Marshal(task)
+		repository.Put(context.Background(), Prefix, task.ID, data)
+	}
+
 	rec := httptest.NewRecorder()
 	req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/%s/%s/restart", Prefix, taskId), nil)
 
@@ -233,6 +254,18 @@ dZM6MSCYh9kcT0pi2FPmY9iXba9kx4XAnf+0YB5xCz9QSMk4W5xSTBs=
 	if rec.Code != http.StatusAccepted {
 		t.Errorf("Wrong response code expected %d received %d", http.StatusAccepted, rec.Code)
 	}
+
+	for _, forceCase := range forceCases {
+		id := fmt.Sprintf("%s-force-%s", taskId, forceCase.force)
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/%s/%s/restart?force=%s", Prefix, id, forceCase.force), nil)
+		router.ServeHTTP(rec, req)
+
+		if rec.Code != forceCase.expectedCode {
+			t.Errorf("force=%s: wrong response code expected %d received %d",
+				forceCase.force, forceCase.expectedCode, rec.Code)
+		}
+	}
 }
 
 func TestWorkflowHandlerBuildWorkflow(t *testing.T) {
@@ -243,6 +276,9 @@ func TestWorkflowHandlerBuildWorkflow(t *testing.T) {
 		repository: &MockRepository{
 			map[string][]byte{},
 		},
+		getWriter: func(string) (io.WriteCloser, error) {
+			return &bufferCloser{}, nil
+		},
 	}
 
 	message := "hello, world!!!"
@@ -0,0 +1,68 @@
+package workflows
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/supergiant/supergiant/pkg/sgerrors"
+	"github.com/supergiant/supergiant/pkg/storage"
+)
+
+// ClusterIndexPrefix keeps ids of tasks under the name of the cluster they belong to,
+// so tasks of the cluster can be found without reading all the tasks.
+const ClusterIndexPrefix = "/supergiant/index/tasks/cluster/"
+
+// ClusterIndexKey returns key of the task in the cluster index relative to ClusterIndexPrefix
+func ClusterIndexKey(clusterName, taskID string) string {
+	return clusterName + "/" + taskID
+}
+
+// DeleteClusterIndexOp returns operation that removes index entries of all tasks of the cluster
+func DeleteClusterIndexOp(clusterName string) storage.Op {
+	return storage.DeleteOp(ClusterIndexPrefix, clusterName+"/")
+}
+
+// ClusterTasks returns a page of tasks of the cluster and the continue token of the next page
+func ClusterTasks(ctx context.Context, repository storage.Interface, clusterName string, limit int, continueToken string) ([]*Task, string, error) {
+	prefix := ClusterIndexPrefix + clusterName + "/"
+
+	kvs, next, err := repository.Page(ctx, prefix, limit, continueToken)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "read cluster index")
+	}
+
+	tasks := make([]*Task, 0, len(kvs))
+	for _, kv := range kvs {
+		id := strings.TrimPrefix(kv.Key, prefix)
+
+		data, err := repository.Get(ctx, Prefix, id)
+		// Task may be removed after the index has been read
+		if sgerrors.IsNotFound(err) {
+			continue
+		}
+
+		if err != nil {
+			return nil, "", errors.Wrapf(err, "get task %s", id)
+		}
+
+		task := &Task{}
+		if err := json.Unmarshal(data, task); err != nil {
+			return nil, "", errors.Wrapf(err, "unmarshal task %s", id)
+		}
+		tasks = append(tasks, task)
+	}
+
+	return tasks, next, nil
+}
+
+// clusterName returns name of the cluster task belongs to or empty string
+func (w *Task) clusterName() string {
+	if w.Config == nil {
+		return ""
+	}
+
+	return w.Config.ClusterName
+}
@@ -0,0 +1,58 @@
+package workflows
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/supergiant/supergiant/pkg/storage"
+	"github.com/supergiant/supergiant/pkg/workflows/steps"
+)
+
+func TestClusterTasks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sg-index")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	repository, err := storage.NewFileRepository(path.Join(dir, "supergiant.db"))
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	for _, clusterName := range []string{"test", "test", "test", "other", ""} {
+		task := newTask("workflow", nil, repository)
+		task.Config = &steps.Config{ClusterName: clusterName}
+		require.NoError(t, task.sync(ctx))
+	}
+
+	tasks, next, err := ClusterTasks(ctx, repository, "test", 2, "")
+	require.NoError(t, err)
+	require.Len(t, tasks, 2)
+	require.NotEmpty(t, next)
+
+	rest, next, err := ClusterTasks(ctx, repository, "test", 2, next)
+	require.NoError(t, err)
+	require.Len(t, rest, 1)
+	require.Empty(t, next)
+
+	for _, task := range append(tasks, rest...) {
+		require.Equal(t, "test", task.Config.ClusterName)
+	}
+
+	// Cluster name is a prefix of another cluster name
+	tasks, _, err = ClusterTasks(ctx, repository, "tes", 0, "")
+	require.NoError(t, err)
+	require.Len(t, tasks, 0)
+
+	require.NoError(t, repository.Txn(ctx, DeleteClusterIndexOp("test")))
+	tasks, _, err = ClusterTasks(ctx, repository, "test", 0, "")
+	require.NoError(t, err)
+	require.Len(t, tasks, 0)
+
+	tasks, _, err = ClusterTasks(ctx, repository, "other", 0, "")
+	require.NoError(t, err)
+	require.Len(t, tasks, 1)
+}
@@ -0,0 +1,227 @@
+package workflows
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/supergiant/supergiant/pkg/message"
+	"github.com/supergiant/supergiant/pkg/storage"
+	"github.com/supergiant/supergiant/pkg/util"
+	"github.com/supergiant/supergiant/pkg/workflows/steps"
+)
+
+// RetentionPolicy tells which finished tasks are kept, zero values mean no limit
+type RetentionPolicy struct {
+	// MaxAge is the time finished task is kept after its last update, age of tasks
+	// saved without timestamps is unknown and they are not removed by age.
+	MaxAge time.Duration
+	// MaxPerCluster is the number of the latest finished tasks kept for every cluster
+	MaxPerCluster int
+	// KeepFailed protects failed tasks from pruning so they can be investigated or restarted
+	KeepFailed bool
+}
+
+// Janitor periodically prunes finished tasks together with their log files
+type Janitor struct {
+	repository storage.Interface
+	policy     RetentionPolicy
+	interval   time.Duration
+	logDir     string
+	removeFile func(string) error
+}
+
+type PruneResponse struct {
+	Removed int `json:"removed"`
+}
+
+func NewJanitor(repository storage.Interface, policy RetentionPolicy, interval time.Duration) *Janitor {
+	return &Janitor{
+		repository: repository,
+		policy:     policy,
+		interval:   interval,
+		// TODO(stgleb): Add log directory to params of supergiant
+		logDir:     "/tmp",
+		removeFile: os.Remove,
+	}
+}
+
+func (j *Janitor) Register(r *mux.Router) {
+	r.HandleFunc("/tasks/prune", j.PruneTasks).Methods(http.MethodPost)
+}
+
+// Run prunes tasks every interval until ctx is done
+func (j *Janitor) Run(ctx context.Context) {
+	if j.interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			count, err := j.Prune(ctx)
+			if err != nil {
+				logrus.Errorf("prune tasks: %v", err)
+			}
+
+			if count > 0 {
+				logrus.Infof("janitor has removed %d tasks", count)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// PruneTasks triggers pruning of tasks right away
+func (j *Janitor) PruneTasks(w http.ResponseWriter, r *http.Request) {
+	count, err := j.Prune(r.Context())
+	if err != nil {
+		logrus.Errorf("prune tasks: %v", err)
+		message.SendUnknownError(w, err)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(&PruneResponse{Removed: count}); err != nil {
+		logrus.Error(err)
+	}
+}
+
+// Prune removes finished tasks that are out of the retention policy and
+// returns the number of removed tasks. Running tasks are never removed.
+func (j *Janitor) Prune(ctx context.Context) (int, error) {
+	data, err := j.repository.GetAll(ctx, Prefix)
+	if err != nil {
+		return 0, errors.Wrap(err, "read tasks")
+	}
+
+	byCluster := make(map[string][]*Task)
+	for _, raw := range data {
+		t := &Task{}
+		if err := json.Unmarshal(raw, t); err != nil {
+			logrus.Errorf("janitor: unmarshal task: %v", err)
+			continue
+		}
+
+		if !isFinished(t) || (j.policy.KeepFailed && isFailed(t)) {
+			continue
+		}
+
+		byCluster[t.clusterName()] = append(byCluster[t.clusterName()], t)
+	}
+
+	now := time.Now()
+	count := 0
+
+	for _, tasks := range byCluster {
+		// Newest tasks go first, tasks saved before timestamps were added are the oldest
+		sort.Slice(tasks, func(i, k int) bool {
+			return lastUpdate(tasks[i]).After(lastUpdate(tasks[k]))
+		})
+
+		for i, t := range tasks {
+			updated := lastUpdate(t)
+			expired := j.policy.MaxAge > 0 && !updated.IsZero() && now.Sub(updated) > j.policy.MaxAge
+			exceeded := j.policy.MaxPerCluster > 0 && i >= j.policy.MaxPerCluster
+
+			if !expired && !exceeded {
+				continue
+			}
+
+			if err := j.remove(ctx, t); err != nil {
+				return count, err
+			}
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+func (j *Janitor) remove(ctx context.Context, t *Task) error {
+	id := t.ID
+	ops := []storage.Op{storage.DeleteOp(Prefix, id)}
+	if clusterName := t.clusterName(); clusterName != "" {
+		ops = append(ops, storage.DeleteOp(ClusterIndexPrefix, ClusterIndexKey(clusterName, id)))
+	}
+
+	if err := j.repository.Txn(ctx, ops...); err != nil {
+		return errors.Wrapf(err, "delete task %s", id)
+	}
+
+	// Some of the task logs are written without extension
+	for _, name := range []string{util.MakeFileName(id), id} {
+		err := j.removeFile(path.Join(j.logDir, name))
+		if err != nil && !os.IsNotExist(err) {
+			logrus.Errorf("janitor: remove log of task %s: %v", id, err)
+		}
+	}
+
+	return nil
+}
+
+// lastUpdate falls back to the creation time for tasks saved before updates were timestamped
+func lastUpdate(t *Task) time.Time {
+	if t.UpdatedAt.IsZero() {
+		return t.CreatedAt
+	}
+
+	return t.UpdatedAt
+}
+
+func isFailed(t *Task) bool {
+	if t.Status == steps.StatusError {
+		return true
+	}
+
+	for _, s := range t.StepStatuses {
+		if s.Status == steps.StatusError {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isFinished also derives the state from steps for tasks saved before task status was maintained
+func isFinished(t *Task) bool {
+	switch t.Status {
+	case steps.StatusSuccess, steps.StatusError, steps.StatusCancelled:
+		return true
+	case steps.StatusExecuting, steps.StatusQueued:
+		return false
+	}
+
+	if len(t.StepStatuses) == 0 {
+		return false
+	}
+
+	for _, s := range t.StepStatuses {
+		if s.Status == steps.StatusExecuting {
+			return false
+		}
+	}
+
+	if isFailed(t) {
+		return true
+	}
+
+	for _, s := range t.StepStatuses {
+		if s.Status != steps.StatusSuccess {
+			return false
+		}
+	}
+
+	return true
+}
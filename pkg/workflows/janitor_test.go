@@ -0,0 +1,87 @@
+package workflows
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+
+	"github.com/supergiant/supergiant/pkg/storage"
+	"github.com/supergiant/supergiant/pkg/util"
+	"github.com/supergiant/supergiant/pkg/workflows/steps"
+)
+
+func putTask(t *testing.T, repository storage.Interface, logDir string, task *Task) {
+	data, err := json.Marshal(task)
+	require.NoError(t, err)
+	require.NoError(t, repository.Put(context.Background(), Prefix, task.ID, data))
+	require.NoError(t, ioutil.WriteFile(path.Join(logDir, util.MakeFileName(task.ID)), []byte("log"), 0600))
+}
+
+func TestJanitorPrune(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sg-janitor")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	repository, err := storage.NewFileRepository(path.Join(dir, "supergiant.db"))
+	require.NoError(t, err)
+
+	now := time.Now()
+	cfg := &steps.Config{ClusterName: "test"}
+	aged := &steps.Config{ClusterName: "aged"}
+	tasks := []*Task{
+		{ID: "old", Status: steps.StatusSuccess, Config: cfg, UpdatedAt: now.Add(-48 * time.Hour)},
+		{ID: "failed", Status: steps.StatusError, Config: cfg, UpdatedAt: now.Add(-48 * time.Hour)},
+		{ID: "running", Status: steps.StatusExecuting, Config: cfg, UpdatedAt: now.Add(-48 * time.Hour)},
+		{ID: "first", Status: steps.StatusSuccess, Config: cfg, UpdatedAt: now.Add(-3 * time.Minute)},
+		{ID: "second", Status: steps.StatusSuccess, Config: cfg, UpdatedAt: now.Add(-2 * time.Minute)},
+		{ID: "third", Status: steps.StatusSuccess, Config: cfg, UpdatedAt: now.Add(-1 * time.Minute)},
+		{ID: "other", Status: steps.StatusSuccess, Config: &steps.Config{ClusterName: "other"}, UpdatedAt: now},
+		{ID: "legacy", StepStatuses: []StepStatus{{Status: steps.StatusSuccess}}},
+		// Tasks saved before updates were timestamped are aged by their creation time
+		{ID: "created", Status: steps.StatusSuccess, Config: aged, CreatedAt: now.Add(-48 * time.Hour)},
+		{ID: "recent", Status: steps.StatusSuccess, Config: aged, CreatedAt: now.Add(-time.Hour)},
+	}
+	for _, task := range tasks {
+		putTask(t, repository, dir, task)
+	}
+
+	j := NewJanitor(repository, RetentionPolicy{
+		MaxAge:        24 * time.Hour,
+		MaxPerCluster: 2,
+		KeepFailed:    true,
+	}, 0)
+	j.logDir = dir
+
+	router := mux.NewRouter()
+	j.Register(router)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/tasks/prune", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	resp := &PruneResponse{}
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(resp))
+	require.Equal(t, 3, resp.Removed)
+
+	for _, id := range []string{"old", "first", "created"} {
+		_, err := repository.Get(context.Background(), Prefix, id)
+		require.Error(t, err, id)
+
+		_, err = os.Stat(path.Join(dir, util.MakeFileName(id)))
+		require.True(t, os.IsNotExist(err), id)
+	}
+
+	for _, id := range []string{"failed", "running", "second", "third", "other", "legacy", "recent"} {
+		_, err := repository.Get(context.Background(), Prefix, id)
+		require.NoError(t, err, id)
+	}
+}
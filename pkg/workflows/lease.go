@@ -0,0 +1,159 @@
+package workflows
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultLeaseTTL is the time the task is owned by the replica after the last heartbeat
+const DefaultLeaseTTL = 30 * time.Second
+
+var (
+	owner    = defaultOwner()
+	leaseTTL = DefaultLeaseTTL
+
+	errNotOwner = errors.New("task is owned by another replica")
+)
+
+func defaultOwner() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "supergiant"
+	}
+
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+// SetOwner sets id of the replica of the control plane that runs tasks of this process
+func SetOwner(id string) {
+	m.Lock()
+	defer m.Unlock()
+	owner = id
+}
+
+func Owner() string {
+	m.RLock()
+	defer m.RUnlock()
+	return owner
+}
+
+// SetLeaseTTL sets the time tasks stay owned by the replica without heartbeats,
+// heartbeats are sent a few times within the time. Non positive ttl is ignored.
+func SetLeaseTTL(ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+
+	m.Lock()
+	defer m.Unlock()
+	leaseTTL = ttl
+}
+
+func LeaseTTL() time.Duration {
+	m.RLock()
+	defer m.RUnlock()
+	return leaseTTL
+}
+
+// leaseExpired tells whether the owner of the task has stopped sending heartbeats,
+// tasks saved before they had owners have no lease.
+func (w *Task) leaseExpired(now time.Time) bool {
+	return w.Owner == "" || w.LeaseExpiresAt == nil || now.After(*w.LeaseExpiresAt)
+}
+
+// renewLease makes this replica the owner of the task for the lease ttl, caller holds the lock
+func (w *Task) renewLease() {
+	expires := time.Now().Add(LeaseTTL())
+	w.Owner = Owner()
+	w.LeaseExpiresAt = &expires
+}
+
+// heartbeat renews the lease of the running task until ctx is done, the task
+// is stopped if another replica has taken it over meanwhile.
+func (w *Task) heartbeat(ctx context.Context) {
+	ticker := time.NewTicker(LeaseTTL() / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+
+		err := w.repository.Update(ctx, Prefix, w.ID, func(current []byte) ([]byte, error) {
+			stored := &Task{}
+			if current != nil {
+				if err := json.Unmarshal(current, stored); err != nil {
+					return nil, errors.Wrapf(err, "unmarshal task %s", w.ID)
+				}
+			}
+
+			if stored.Owner != "" && stored.Owner != Owner() {
+				return nil, errNotOwner
+			}
+
+			// Only the lease is written, state of the task is saved by its steps
+			stored.renewLease()
+
+			w.mu.Lock()
+			w.Owner, w.LeaseExpiresAt = stored.Owner, stored.LeaseExpiresAt
+			w.mu.Unlock()
+
+			return stored.marshal()
+		})
+
+		if errors.Cause(err) == errNotOwner {
+			logrus.Errorf("task %s has been taken over by another replica, stop it", w.ID)
+			w.cancel()
+			return
+		}
+
+		if err != nil && ctx.Err() == nil {
+			logrus.Errorf("heartbeat of task %s: %v", w.ID, err)
+		}
+	}
+}
+
+// Claim makes this replica the owner of the interrupted task, false is returned
+// when the task has been claimed by another replica or is not interrupted anymore.
+func (w *Task) Claim(ctx context.Context) (bool, error) {
+	err := w.repository.Update(ctx, Prefix, w.ID, func(current []byte) ([]byte, error) {
+		stored := &Task{}
+		if current == nil {
+			return nil, errNotOwner
+		}
+
+		if err := json.Unmarshal(current, stored); err != nil {
+			return nil, errors.Wrapf(err, "unmarshal task %s", w.ID)
+		}
+
+		if !stored.Interrupted() {
+			return nil, errNotOwner
+		}
+
+		stored.renewLease()
+
+		w.mu.Lock()
+		w.Owner, w.LeaseExpiresAt = stored.Owner, stored.LeaseExpiresAt
+		w.mu.Unlock()
+
+		return stored.marshal()
+	})
+
+	if errors.Cause(err) == errNotOwner {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
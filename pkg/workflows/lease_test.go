@@ -0,0 +1,118 @@
+package workflows
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/supergiant/supergiant/pkg/workflows/steps"
+)
+
+func TestTaskInterruptedForeignLease(t *testing.T) {
+	expires := time.Now().Add(time.Hour)
+	task := &Task{
+		ID:             "foreign",
+		Status:         steps.StatusExecuting,
+		Owner:          "other replica",
+		LeaseExpiresAt: &expires,
+	}
+	require.False(t, task.Interrupted())
+
+	expired := time.Now().Add(-time.Second)
+	task.LeaseExpiresAt = &expired
+	require.True(t, task.Interrupted())
+
+	// Task of this replica is interrupted as soon as it is not running
+	task.Owner = Owner()
+	task.LeaseExpiresAt = &expires
+	require.True(t, task.Interrupted())
+}
+
+func TestTaskClaim(t *testing.T) {
+	repository, cleanup := tempRepository(t)
+	defer cleanup()
+
+	expired := time.Now().Add(-time.Second)
+	stored := &Task{
+		ID:             "claimed",
+		Status:         steps.StatusExecuting,
+		Owner:          "stopped replica",
+		LeaseExpiresAt: &expired,
+		repository:     repository,
+	}
+	require.NoError(t, stored.sync(context.Background()))
+
+	first := &Task{ID: stored.ID, repository: repository}
+	ok, err := first.Claim(context.Background())
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, Owner(), first.Owner)
+	require.True(t, first.LeaseExpiresAt.After(time.Now()))
+
+	data, err := repository.Get(context.Background(), Prefix, stored.ID)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, stored))
+	require.Equal(t, Owner(), stored.Owner)
+
+	// Task claimed by another replica meanwhile is left to it
+	expires := time.Now().Add(time.Hour)
+	stored.Owner = "other replica"
+	stored.LeaseExpiresAt = &expires
+	require.NoError(t, stored.sync(context.Background()))
+
+	ok, err = (&Task{ID: stored.ID, repository: repository}).Claim(context.Background())
+	require.NoError(t, err)
+	require.False(t, ok)
+}
+
+func TestTaskHeartbeat(t *testing.T) {
+	SetLeaseTTL(30 * time.Millisecond)
+	defer SetLeaseTTL(DefaultLeaseTTL)
+
+	repository, cleanup := tempRepository(t)
+	defer cleanup()
+
+	task := &Task{
+		ID:         "heartbeat",
+		Status:     steps.StatusExecuting,
+		Config:     &steps.Config{ClusterName: "saved"},
+		repository: repository,
+	}
+	task.renewLease()
+	require.NoError(t, task.sync(context.Background()))
+	// Heartbeat writes only the lease, the config is saved by steps
+	task.Config = &steps.Config{ClusterName: "running"}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	task.cancel = cancel
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		task.heartbeat(ctx)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	data, err := repository.Get(context.Background(), Prefix, task.ID)
+	require.NoError(t, err)
+
+	stored := &Task{}
+	require.NoError(t, json.Unmarshal(data, stored))
+	require.Equal(t, Owner(), stored.Owner)
+	require.True(t, stored.LeaseExpiresAt.After(time.Now()))
+	require.Equal(t, "saved", stored.Config.ClusterName)
+
+	// Task taken over by another replica is stopped
+	stored.Owner = "other replica"
+	stored.repository = repository
+	require.NoError(t, stored.sync(context.Background()))
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("heartbeat has not stopped")
+	}
+	require.Error(t, ctx.Err())
+}
@@ -0,0 +1,227 @@
+package workflows
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/supergiant/supergiant/pkg/sgerrors"
+	"github.com/supergiant/supergiant/pkg/storage"
+	"github.com/supergiant/supergiant/pkg/workflows/steps"
+)
+
+// TaskDTO is the view of the task returned by task lists
+type TaskDTO struct {
+	ID           string       `json:"id"`
+	Type         string       `json:"type"`
+	Status       steps.Status `json:"status"`
+	StepStatuses []StepStatus `json:"stepsStatuses"`
+	CreatedAt    time.Time    `json:"createdAt"`
+	StartedAt    *time.Time   `json:"startedAt,omitempty"`
+	FinishedAt   *time.Time   `json:"finishedAt,omitempty"`
+	Duration     *Duration    `json:"duration,omitempty"`
+}
+
+func NewTaskDTO(task *Task) TaskDTO {
+	return TaskDTO{
+		ID:           task.ID,
+		Type:         task.Type,
+		Status:       task.Status,
+		StepStatuses: task.StepStatuses,
+		CreatedAt:    task.CreatedAt,
+		StartedAt:    task.StartedAt,
+		FinishedAt:   task.FinishedAt,
+		Duration:     task.Duration,
+	}
+}
+
+// TaskFilter selects tasks of the list, empty fields match any task
+type TaskFilter struct {
+	Type          string
+	Status        steps.Status
+	ClusterName   string
+	NodeName      string
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+}
+
+// Match tells whether the task passes the filter
+func (f TaskFilter) Match(task *Task) bool {
+	if f.Type != "" && task.Type != f.Type {
+		return false
+	}
+
+	if f.Status != "" && task.Status != f.Status {
+		return false
+	}
+
+	if f.ClusterName != "" && task.clusterName() != f.ClusterName {
+		return false
+	}
+
+	if f.NodeName != "" && (task.Config == nil || task.Config.Node.Name != f.NodeName) {
+		return false
+	}
+
+	if !f.CreatedAfter.IsZero() && !task.CreatedAt.After(f.CreatedAfter) {
+		return false
+	}
+
+	if !f.CreatedBefore.IsZero() && !task.CreatedAt.Before(f.CreatedBefore) {
+		return false
+	}
+
+	return true
+}
+
+// sortFields are the fields tasks can be sorted by, field prefixed
+// with - sorts in descending order. Ties are ordered by task id.
+var sortFields = map[string]func(a, b *Task) bool{
+	"createdAt": func(a, b *Task) bool {
+		return a.CreatedAt.Before(b.CreatedAt)
+	},
+	"updatedAt": func(a, b *Task) bool {
+		return a.UpdatedAt.Before(b.UpdatedAt)
+	},
+	"type": func(a, b *Task) bool {
+		return a.Type < b.Type
+	},
+	"status": func(a, b *Task) bool {
+		return a.Status < b.Status
+	},
+	"duration": func(a, b *Task) bool {
+		return duration(a) < duration(b)
+	},
+}
+
+// DefaultSort lists the newest tasks first
+const DefaultSort = "-createdAt"
+
+func duration(task *Task) time.Duration {
+	if task.Duration == nil {
+		return 0
+	}
+
+	return task.Duration.Duration
+}
+
+// validSort tells whether tasks can be sorted by the field
+func validSort(field string) bool {
+	_, ok := sortFields[strings.TrimPrefix(field, "-")]
+	return ok
+}
+
+// sortTasks sorts tasks by the field, unknown field falls back to the default order
+func sortTasks(tasks []*Task, field string) {
+	if !validSort(field) {
+		field = DefaultSort
+	}
+
+	desc := strings.HasPrefix(field, "-")
+	less := sortFields[strings.TrimPrefix(field, "-")]
+
+	sort.SliceStable(tasks, func(i, k int) bool {
+		a, b := tasks[i], tasks[k]
+		if desc {
+			a, b = b, a
+		}
+
+		if less(a, b) {
+			return true
+		}
+
+		if less(b, a) {
+			return false
+		}
+
+		return tasks[i].ID < tasks[k].ID
+	})
+}
+
+// ListTasks returns a page of tasks that match the filter sorted by the field and the
+// continue token of the next page. The page is found by its offset in the sorted list,
+// so tasks created between requests of pages may shift the pages.
+func ListTasks(ctx context.Context, repository storage.Interface, filter TaskFilter, sortField string, limit int, continueToken string) ([]*Task, string, error) {
+	offset, err := decodeOffset(continueToken)
+	if err != nil {
+		return nil, "", err
+	}
+
+	tasks, err := readTasks(ctx, repository, filter.ClusterName)
+	if err != nil {
+		return nil, "", err
+	}
+
+	matched := make([]*Task, 0, len(tasks))
+	for _, task := range tasks {
+		if filter.Match(task) {
+			matched = append(matched, task)
+		}
+	}
+	sortTasks(matched, sortField)
+
+	if offset > len(matched) {
+		offset = len(matched)
+	}
+	matched = matched[offset:]
+
+	if limit == 0 || limit >= len(matched) {
+		return matched, "", nil
+	}
+
+	return matched[:limit], encodeOffset(offset + limit), nil
+}
+
+// readTasks reads all tasks, tasks of the cluster are found by the cluster index
+func readTasks(ctx context.Context, repository storage.Interface, clusterName string) ([]*Task, error) {
+	if clusterName != "" {
+		tasks, _, err := ClusterTasks(ctx, repository, clusterName, 0, "")
+		return tasks, err
+	}
+
+	data, err := repository.GetAll(ctx, Prefix)
+	if err != nil {
+		return nil, errors.Wrap(err, "read tasks")
+	}
+
+	tasks := make([]*Task, 0, len(data))
+	for _, raw := range data {
+		task := &Task{}
+		if err := json.Unmarshal(raw, task); err != nil {
+			logrus.Errorf("list tasks: unmarshal task: %v", err)
+			continue
+		}
+		tasks = append(tasks, task)
+	}
+
+	return tasks, nil
+}
+
+func encodeOffset(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(offset)))
+}
+
+func decodeOffset(token string) (int, error) {
+	if token == "" {
+		return 0, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, sgerrors.ErrInvalidContinue
+	}
+
+	offset, err := strconv.Atoi(string(data))
+	if err != nil || offset < 0 {
+		return 0, sgerrors.ErrInvalidContinue
+	}
+
+	return offset, nil
+}
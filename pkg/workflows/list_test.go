@@ -0,0 +1,216 @@
+package workflows
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+
+	"github.com/supergiant/supergiant/pkg/api"
+	"github.com/supergiant/supergiant/pkg/node"
+	"github.com/supergiant/supergiant/pkg/storage"
+	"github.com/supergiant/supergiant/pkg/workflows/steps"
+)
+
+// tempRepository returns repository that keeps data in a temporary directory until cleanup
+func tempRepository(t *testing.T) (storage.Interface, func()) {
+	dir, err := ioutil.TempDir("", "sg-tasks")
+	require.NoError(t, err)
+
+	repository, err := storage.NewFileRepository(path.Join(dir, "supergiant.db"))
+	require.NoError(t, err)
+
+	return repository, func() {
+		os.RemoveAll(dir)
+	}
+}
+
+func listRepository(t *testing.T) (storage.Interface, func()) {
+	repository, cleanup := tempRepository(t)
+
+	now := time.Now()
+	tasks := []*Task{
+		{
+			ID: "master", Type: "master", Status: steps.StatusSuccess, CreatedAt: now.Add(-3 * time.Hour),
+			Config: &steps.Config{ClusterName: "test", Node: node.Node{Name: "test-master"}},
+		},
+		{
+			ID: "node", Type: "node", Status: steps.StatusError, CreatedAt: now.Add(-2 * time.Hour),
+			Config: &steps.Config{ClusterName: "test", Node: node.Node{Name: "test-node"}},
+		},
+		{
+			ID: "cluster", Type: "cluster", Status: steps.StatusSuccess, CreatedAt: now.Add(-time.Hour),
+			Config: &steps.Config{ClusterName: "test"},
+		},
+		{
+			ID: "other", Type: "master", Status: steps.StatusExecuting, CreatedAt: now,
+			Config: &steps.Config{ClusterName: "other", Node: node.Node{Name: "other-master"}},
+		},
+	}
+
+	for _, task := range tasks {
+		task.repository = repository
+		require.NoError(t, task.sync(context.Background()))
+	}
+
+	return repository, cleanup
+}
+
+func taskIDs(tasks []*Task) []string {
+	ids := make([]string, 0, len(tasks))
+	for _, task := range tasks {
+		ids = append(ids, task.ID)
+	}
+
+	return ids
+}
+
+func TestListTasks(t *testing.T) {
+	repository, cleanup := listRepository(t)
+	defer cleanup()
+
+	testCases := []struct {
+		description string
+		filter      TaskFilter
+		sort        string
+		expected    []string
+	}{
+		{
+			description: "all tasks newest first",
+			expected:    []string{"other", "cluster", "node", "master"},
+		},
+		{
+			description: "oldest first",
+			sort:        "createdAt",
+			expected:    []string{"master", "node", "cluster", "other"},
+		},
+		{
+			description: "by type",
+			filter:      TaskFilter{Type: "master"},
+			expected:    []string{"other", "master"},
+		},
+		{
+			description: "by status",
+			filter:      TaskFilter{Status: steps.StatusSuccess},
+			sort:        "type",
+			expected:    []string{"cluster", "master"},
+		},
+		{
+			description: "by cluster",
+			filter:      TaskFilter{ClusterName: "test"},
+			expected:    []string{"cluster", "node", "master"},
+		},
+		{
+			description: "by node",
+			filter:      TaskFilter{NodeName: "test-node"},
+			expected:    []string{"node"},
+		},
+		{
+			description: "by creation time",
+			filter: TaskFilter{
+				CreatedAfter:  time.Now().Add(-150 * time.Minute),
+				CreatedBefore: time.Now().Add(-time.Minute),
+			},
+			expected: []string{"cluster", "node"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		tasks, next, err := ListTasks(context.Background(), repository, testCase.filter, testCase.sort, 0, "")
+		require.NoError(t, err, testCase.description)
+		require.Empty(t, next, testCase.description)
+		require.Equal(t, testCase.expected, taskIDs(tasks), testCase.description)
+	}
+}
+
+func TestListTasksPages(t *testing.T) {
+	repository, cleanup := listRepository(t)
+	defer cleanup()
+
+	var ids []string
+	next := ""
+	for {
+		tasks, token, err := ListTasks(context.Background(), repository, TaskFilter{}, "", 3, next)
+		require.NoError(t, err)
+		ids = append(ids, taskIDs(tasks)...)
+
+		if token == "" {
+			break
+		}
+		next = token
+	}
+	require.Equal(t, []string{"other", "cluster", "node", "master"}, ids)
+
+	_, _, err := ListTasks(context.Background(), repository, TaskFilter{}, "", 3, "not a token")
+	require.Error(t, err)
+}
+
+func TestTaskHandlerListTasks(t *testing.T) {
+	repository, cleanup := listRepository(t)
+	defer cleanup()
+
+	h := &TaskHandler{repository: repository}
+	router := mux.NewRouter()
+	h.Register(router)
+
+	testCases := []struct {
+		query        string
+		expectedCode int
+		expected     []string
+		hasNext      bool
+	}{
+		{
+			query:        "?cluster=test&status=success",
+			expectedCode: http.StatusOK,
+			expected:     []string{"cluster", "master"},
+		},
+		{
+			query:        "?sort=createdAt&limit=1",
+			expectedCode: http.StatusOK,
+			expected:     []string{"master"},
+			hasNext:      true,
+		},
+		{
+			query:        "?type=unknown",
+			expectedCode: http.StatusOK,
+			expected:     []string{},
+		},
+		{
+			query:        "?sort=name",
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			query:        "?createdAfter=yesterday",
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, testCase := range testCases {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/tasks"+testCase.query, nil)
+		router.ServeHTTP(rec, req)
+
+		require.Equal(t, testCase.expectedCode, rec.Code, testCase.query)
+		if testCase.expectedCode != http.StatusOK {
+			continue
+		}
+
+		resp := make([]TaskDTO, 0)
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp), testCase.query)
+
+		ids := make([]string, 0, len(resp))
+		for _, task := range resp {
+			ids = append(ids, task.ID)
+		}
+		require.Equal(t, testCase.expected, ids, testCase.query)
+		require.Equal(t, testCase.hasNext, rec.Header().Get(api.ContinueHeader) != "", testCase.query)
+	}
+}
@@ -0,0 +1,139 @@
+package workflows
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/supergiant/supergiant/pkg/workflows/steps"
+)
+
+// DurationBuckets are upper bounds in seconds of step and task duration histograms
+var DurationBuckets = []float64{1, 5, 10, 30, 60, 120, 300, 600, 1200, 1800, 3600}
+
+// histogram counts observations that fall into buckets, counts are cumulative
+// as in Prometheus, so the count of the bucket includes the ones below it.
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func (h *histogram) observe(v float64) {
+	for i, bound := range DurationBuckets {
+		if v <= bound {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+type labels struct {
+	name   string
+	status steps.Status
+}
+
+// histogramVec is a set of histograms of the metric partitioned by name and status
+type histogramVec struct {
+	metric string
+	help   string
+	label  string
+	m      sync.Mutex
+	values map[labels]*histogram
+}
+
+func newHistogramVec(metric, help, label string) *histogramVec {
+	return &histogramVec{
+		metric: metric,
+		help:   help,
+		label:  label,
+		values: make(map[labels]*histogram),
+	}
+}
+
+func (v *histogramVec) observe(name string, status steps.Status, d time.Duration) {
+	v.m.Lock()
+	defer v.m.Unlock()
+
+	key := labels{name, status}
+	h, ok := v.values[key]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(DurationBuckets))}
+		v.values[key] = h
+	}
+
+	h.observe(d.Seconds())
+}
+
+// write writes histograms in Prometheus text format
+func (v *histogramVec) write(out io.Writer) error {
+	v.m.Lock()
+	defer v.m.Unlock()
+
+	keys := make([]labels, 0, len(v.values))
+	for key := range v.values {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].name != keys[j].name {
+			return keys[i].name < keys[j].name
+		}
+		return keys[i].status < keys[j].status
+	})
+
+	if _, err := fmt.Fprintf(out, "# HELP %s %s\n# TYPE %s histogram\n", v.metric, v.help, v.metric); err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		h := v.values[key]
+		l := fmt.Sprintf("%s=%q,status=%q", v.label, key.name, key.status)
+
+		for i, bound := range DurationBuckets {
+			le := strconv.FormatFloat(bound, 'f', -1, 64)
+			if _, err := fmt.Fprintf(out, "%s_bucket{%s,le=%q} %d\n", v.metric, l, le, h.counts[i]); err != nil {
+				return err
+			}
+		}
+
+		if _, err := fmt.Fprintf(out, "%s_bucket{%s,le=\"+Inf\"} %d\n%s_sum{%s} %s\n%s_count{%s} %d\n",
+			v.metric, l, h.count,
+			v.metric, l, strconv.FormatFloat(h.sum, 'f', -1, 64),
+			v.metric, l, h.count); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+var (
+	stepDurations = newHistogramVec("supergiant_step_duration_seconds",
+		"Time steps of tasks take to finish.", "step")
+	taskDurations = newHistogramVec("supergiant_task_duration_seconds",
+		"Time tasks take to finish.", "type")
+)
+
+// WriteMetrics writes duration histograms of steps and tasks finished by this process in Prometheus text format
+func WriteMetrics(out io.Writer) error {
+	for _, v := range []*histogramVec{stepDurations, taskDurations} {
+		if err := v.write(out); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// elapsed returns the time passed since start rounded to milliseconds
+func elapsed(start *time.Time, end time.Time) *Duration {
+	if start == nil {
+		return nil
+	}
+
+	return &Duration{end.Sub(*start).Round(time.Millisecond)}
+}
@@ -0,0 +1,52 @@
+package workflows
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/supergiant/supergiant/pkg/workflows/steps"
+)
+
+func TestHistogramVecWrite(t *testing.T) {
+	v := newHistogramVec("test_duration_seconds", "Test.", "step")
+	v.observe("ssh", steps.StatusSuccess, 3*time.Second)
+	v.observe("ssh", steps.StatusSuccess, 45*time.Second)
+	v.observe("docker", steps.StatusError, 2*time.Hour)
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, v.write(buf))
+	out := buf.String()
+
+	require.Contains(t, out, "# TYPE test_duration_seconds histogram\n")
+	require.Contains(t, out, `test_duration_seconds_bucket{step="ssh",status="success",le="1"} 0`)
+	require.Contains(t, out, `test_duration_seconds_bucket{step="ssh",status="success",le="5"} 1`)
+	require.Contains(t, out, `test_duration_seconds_bucket{step="ssh",status="success",le="60"} 2`)
+	require.Contains(t, out, `test_duration_seconds_bucket{step="ssh",status="success",le="+Inf"} 2`)
+	require.Contains(t, out, `test_duration_seconds_sum{step="ssh",status="success"} 48`)
+	require.Contains(t, out, `test_duration_seconds_count{step="ssh",status="success"} 2`)
+	require.Contains(t, out, `test_duration_seconds_bucket{step="docker",status="error",le="3600"} 0`)
+	require.Contains(t, out, `test_duration_seconds_bucket{step="docker",status="error",le="+Inf"} 1`)
+
+	// Series are sorted by labels
+	require.True(t, strings.Index(out, `step="docker"`) < strings.Index(out, `step="ssh"`))
+}
+
+func TestTaskHandlerMetrics(t *testing.T) {
+	stepDurations.observe("metrics-step", steps.StatusSuccess, time.Second)
+	taskDurations.observe("metrics-task", steps.StatusError, time.Minute)
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	(&TaskHandler{}).Metrics(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Header().Get("Content-Type"), "text/plain")
+	require.Contains(t, rec.Body.String(), `supergiant_step_duration_seconds_count{step="metrics-step",status="success"} 1`)
+	require.Contains(t, rec.Body.String(), `supergiant_task_duration_seconds_count{type="metrics-task",status="error"} 1`)
+}
@@ -0,0 +1,68 @@
+package workflows
+
+import (
+	"context"
+	"time"
+
+	"github.com/supergiant/supergiant/pkg/workflows/steps"
+)
+
+// InterruptedMessage is an error of the steps that were running when the control plane stopped
+const InterruptedMessage = "interrupted by control plane restart"
+
+// Interrupted tells whether the task was running or waiting for its turn when
+// the control plane stopped, tasks running in this process or owned by another
+// replica whose lease has not expired are not interrupted.
+func (w *Task) Interrupted() bool {
+	if IsRunning(w.ID) {
+		return false
+	}
+
+	if w.Owner != Owner() && !w.leaseExpired(time.Now()) {
+		return false
+	}
+
+	if w.Status == steps.StatusExecuting || w.Status == steps.StatusQueued {
+		return true
+	}
+
+	for _, s := range w.StepStatuses {
+		if s.Status == steps.StatusExecuting {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Resumable tells whether the task can be restarted, workflow
+// of the task may have been changed while the control plane was down.
+func (w *Task) Resumable() bool {
+	if len(w.workflow) == 0 || len(w.workflow) != len(w.StepStatuses) {
+		return false
+	}
+
+	for i, step := range w.workflow {
+		if step == nil || step.Name() != w.StepStatuses[i].StepName {
+			return false
+		}
+	}
+
+	return true
+}
+
+// MarkInterrupted fails the interrupted steps and the task
+func (w *Task) MarkInterrupted(ctx context.Context) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for i := range w.StepStatuses {
+		if w.StepStatuses[i].Status == steps.StatusExecuting {
+			w.StepStatuses[i].Status = steps.StatusError
+			w.StepStatuses[i].ErrMsg = InterruptedMessage
+		}
+	}
+	w.Status = steps.StatusError
+
+	return w.sync(ctx)
+}
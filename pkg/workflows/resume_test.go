@@ -0,0 +1,86 @@
+package workflows
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/supergiant/supergiant/pkg/workflows/steps"
+)
+
+func TestTaskInterrupted(t *testing.T) {
+	task := &Task{
+		ID: "interrupted",
+		StepStatuses: []StepStatus{
+			{StepName: "a", Status: steps.StatusSuccess},
+			{StepName: "b", Status: steps.StatusTodo},
+		},
+	}
+	require.False(t, task.Interrupted())
+
+	task.StepStatuses[1].Status = steps.StatusExecuting
+	require.True(t, task.Interrupted())
+}
+
+func TestTaskResumable(t *testing.T) {
+	task := &Task{
+		workflow: Workflow{&MockStep{name: "a"}, &MockStep{name: "b"}},
+		StepStatuses: []StepStatus{
+			{StepName: "a"},
+			{StepName: "b"},
+		},
+	}
+	require.True(t, task.Resumable())
+
+	task.StepStatuses[1].StepName = "c"
+	require.False(t, task.Resumable())
+
+	task.StepStatuses = task.StepStatuses[:1]
+	require.False(t, task.Resumable())
+}
+
+func TestResumeInterruptedTask(t *testing.T) {
+	step := &MockStep{name: "b"}
+	task := &Task{
+		ID: "resume",
+		repository: &MockRepository{
+			storage: make(map[string][]byte),
+		},
+		Config:   &steps.Config{},
+		Status:   steps.StatusExecuting,
+		workflow: Workflow{&MockStep{name: "a"}, step},
+		StepStatuses: []StepStatus{
+			{StepName: "a", Status: steps.StatusSuccess},
+			{StepName: "b", Status: steps.StatusExecuting},
+		},
+	}
+	require.True(t, task.Interrupted())
+	require.True(t, task.Resumable())
+	require.NoError(t, task.sync(context.Background()))
+
+	require.NoError(t, <-task.Restart(context.Background(), task.ID, &bufferCloser{}, false))
+	require.Equal(t, steps.StatusSuccess, task.Status)
+	require.Equal(t, steps.StatusSuccess, task.StepStatuses[1].Status)
+}
+
+func TestTaskMarkInterrupted(t *testing.T) {
+	task := &Task{
+		ID: "interrupted",
+		repository: &MockRepository{
+			storage: make(map[string][]byte),
+		},
+		Status: steps.StatusExecuting,
+		StepStatuses: []StepStatus{
+			{StepName: "a", Status: steps.StatusSuccess},
+			{StepName: "b", Status: steps.StatusExecuting},
+		},
+	}
+
+	require.NoError(t, task.MarkInterrupted(context.Background()))
+	require.Equal(t, steps.StatusError, task.Status)
+	require.Equal(t, steps.StatusSuccess, task.StepStatuses[0].Status)
+	require.Equal(t, steps.StatusError, task.StepStatuses[1].Status)
+	require.Equal(t, InterruptedMessage, task.StepStatuses[1].ErrMsg)
+	require.False(t, task.Interrupted())
+}
@@ -0,0 +1,90 @@
+package workflows
+
+import (
+	"context"
+	"math"
+	"regexp"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// RetryPolicy tells how many times and how soon a failed step is run again
+type RetryPolicy struct {
+	// MaxAttempts is the number of runs of the step including the first one
+	MaxAttempts int `json:"maxAttempts,omitempty"`
+	// Backoff is the delay before the second attempt
+	Backoff Duration `json:"backoff,omitempty"`
+	// Multiplier grows the delay after every attempt, the delay stays the same when it is below 1
+	Multiplier float64 `json:"multiplier,omitempty"`
+	// MaxBackoff caps the delay, zero means no cap
+	MaxBackoff Duration `json:"maxBackoff,omitempty"`
+	// RetryOn are regular expressions matched against the error of the step,
+	// any error is retried when empty.
+	RetryOn []string `json:"retryOn,omitempty"`
+}
+
+func (p *RetryPolicy) Validate() error {
+	if p.MaxAttempts < 0 || p.Backoff.Duration < 0 || p.MaxBackoff.Duration < 0 {
+		return errors.New("retry policy values must not be negative")
+	}
+
+	for _, pattern := range p.RetryOn {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return errors.Wrapf(err, "retry on %s", pattern)
+		}
+	}
+
+	return nil
+}
+
+// attempts returns the number of runs of the step, nil policy means a single run
+func (p *RetryPolicy) attempts() int {
+	if p == nil || p.MaxAttempts < 1 {
+		return 1
+	}
+
+	return p.MaxAttempts
+}
+
+// delay returns the time to wait after the failed attempt with the given number
+func (p *RetryPolicy) delay(attempt int) time.Duration {
+	delay := p.Backoff.Duration
+	if p.Multiplier > 1 {
+		delay = time.Duration(float64(delay) * math.Pow(p.Multiplier, float64(attempt-1)))
+	}
+
+	if p.MaxBackoff.Duration > 0 && delay > p.MaxBackoff.Duration {
+		delay = p.MaxBackoff.Duration
+	}
+
+	return delay
+}
+
+func (p *RetryPolicy) retryable(err error) bool {
+	if len(p.RetryOn) == 0 {
+		return true
+	}
+
+	for _, pattern := range p.RetryOn {
+		// Patterns have been checked by Validate
+		if ok, _ := regexp.MatchString(pattern, err.Error()); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// sleep waits for d and tells whether ctx is still alive after that
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
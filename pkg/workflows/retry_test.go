@@ -0,0 +1,98 @@
+package workflows
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/supergiant/supergiant/pkg/workflows/steps"
+)
+
+func TestRetryPolicyDelay(t *testing.T) {
+	p := &RetryPolicy{
+		Backoff:    Duration{time.Second},
+		Multiplier: 2,
+		MaxBackoff: Duration{3 * time.Second},
+	}
+
+	require.Equal(t, time.Second, p.delay(1))
+	require.Equal(t, 2*time.Second, p.delay(2))
+	require.Equal(t, 3*time.Second, p.delay(3))
+
+	p.Multiplier = 0
+	require.Equal(t, time.Second, p.delay(3))
+}
+
+func TestRetryPolicyRetryable(t *testing.T) {
+	p := &RetryPolicy{}
+	require.True(t, p.retryable(errors.New("any")))
+
+	p.RetryOn = []string{"connection (reset|refused)", "timeout"}
+	require.True(t, p.retryable(errors.New("dial tcp: connection refused")))
+	require.False(t, p.retryable(errors.New("permission denied")))
+}
+
+func TestRetryPolicyAttempts(t *testing.T) {
+	var p *RetryPolicy
+	require.Equal(t, 1, p.attempts())
+	require.Equal(t, 3, (&RetryPolicy{MaxAttempts: 3}).attempts())
+}
+
+func TestTaskRunRetry(t *testing.T) {
+	testCases := []struct {
+		name     string
+		policy   *RetryPolicy
+		errs     []error
+		attempts int
+		hasError bool
+	}{
+		{
+			name:     "success after retry",
+			policy:   &RetryPolicy{MaxAttempts: 3},
+			errs:     []error{errors.New("first"), errors.New("second")},
+			attempts: 3,
+		},
+		{
+			name:     "out of attempts",
+			policy:   &RetryPolicy{MaxAttempts: 2},
+			errs:     []error{errors.New("first"), errors.New("second")},
+			attempts: 2,
+			hasError: true,
+		},
+		{
+			name:     "not retryable",
+			policy:   &RetryPolicy{MaxAttempts: 3, RetryOn: []string{"timeout"}},
+			errs:     []error{errors.New("permission denied")},
+			attempts: 1,
+			hasError: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		step := &MockStep{name: "step", errs: testCase.errs}
+		task := &Task{
+			ID: "abcd",
+			repository: &MockRepository{
+				storage: make(map[string][]byte),
+			},
+			workflow: Workflow{
+				&definedStep{Step: step, retry: testCase.policy},
+			},
+		}
+
+		err := <-task.Run(context.Background(), steps.Config{}, &bufferCloser{})
+		if testCase.hasError {
+			require.Error(t, err, testCase.name)
+		} else {
+			require.NoError(t, err, testCase.name)
+		}
+
+		status := task.StepStatuses[0]
+		require.Equal(t, testCase.attempts, status.Attempts, testCase.name)
+		require.Len(t, status.AttemptErrors, len(testCase.errs), testCase.name)
+		require.Equal(t, testCase.errs[0].Error(), status.AttemptErrors[0], testCase.name)
+	}
+}
@@ -0,0 +1,92 @@
+package workflows
+
+import (
+	"context"
+	"io"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/supergiant/supergiant/pkg/util"
+	"github.com/supergiant/supergiant/pkg/workflows/steps"
+)
+
+// rollbackOnFailure tells whether the task undoes all its steps when it fails
+func (w *Task) rollbackOnFailure() bool {
+	return w.Config != nil && w.Config.RollbackOnFailure
+}
+
+// undo rolls back the started steps of the stopped task in reverse order, every step is rolled back
+// before the steps it depends on. Cancelled task is rolled back only when it was asked to.
+func (w *Task) undo(ctx context.Context, out io.Writer, g *graph) {
+	if !w.rollbackOnFailure() {
+		return
+	}
+
+	if ctx.Err() == context.Canceled && !w.shouldRollbackOnCancel() {
+		return
+	}
+
+	util.GetLogger(out).Infof("rolling back task %s", w.ID)
+
+	order := g.order()
+	for k := len(order) - 1; k >= 0; k-- {
+		i := order[k]
+
+		w.mu.Lock()
+		status := w.StepStatuses[i].Status
+		w.mu.Unlock()
+
+		if status == steps.StatusTodo || status == steps.StatusExecuting {
+			continue
+		}
+
+		// Context of the task may be done already, rollback
+		// goes on since resources have to be released anyway.
+		w.rollbackStep(context.Background(), out, i)
+	}
+}
+
+// rollbackStep rolls back step with index i and tracks the rollback status, succeeded
+// step that has been rolled back is run again when the task is restarted.
+func (w *Task) rollbackStep(ctx context.Context, out io.Writer, i int) {
+	step := w.workflow[i]
+	wsLog := util.GetLogger(out)
+
+	wsLog.Infof("[%s] - rollback started", step.Name())
+	w.setRollbackStatus(ctx, i, steps.StatusExecuting, nil)
+
+	if err := w.waitCloudAPI(ctx, step); err != nil {
+		w.setRollbackStatus(ctx, i, steps.StatusError, err)
+		return
+	}
+
+	if err := step.Rollback(ctx, out, w.Config); err != nil {
+		wsLog.Infof("[%s] - rollback failed: %s", step.Name(), err.Error())
+		logrus.Errorf("rollback: step %s : %v", step.Name(), err)
+		w.setRollbackStatus(ctx, i, steps.StatusError, err)
+		return
+	}
+
+	wsLog.Infof("[%s] - rollback success", step.Name())
+	w.setRollbackStatus(ctx, i, steps.StatusSuccess, nil)
+}
+
+func (w *Task) setRollbackStatus(ctx context.Context, i int, status steps.Status, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.StepStatuses[i].RollbackStatus = status
+	switch status {
+	case steps.StatusError:
+		w.StepStatuses[i].RollbackErrMsg = err.Error()
+	case steps.StatusSuccess:
+		w.StepStatuses[i].RollbackErrMsg = ""
+		if w.StepStatuses[i].Status == steps.StatusSuccess {
+			w.StepStatuses[i].Status = steps.StatusTodo
+		}
+	}
+
+	if err := w.sync(ctx); err != nil {
+		logrus.Errorf("sync error %v for step %s", err, w.StepStatuses[i].StepName)
+	}
+}
@@ -0,0 +1,136 @@
+package workflows
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/supergiant/supergiant/pkg/workflows/steps"
+)
+
+// rollbackStep records the order in which steps are rolled back
+type rollbackStep struct {
+	MockStep
+	err         error
+	rollbackErr error
+	rolledBack  *[]string
+	m           *sync.Mutex
+}
+
+func (s *rollbackStep) Run(ctx context.Context, out io.Writer, config *steps.Config) error {
+	return s.err
+}
+
+func (s *rollbackStep) Rollback(ctx context.Context, out io.Writer, config *steps.Config) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	*s.rolledBack = append(*s.rolledBack, s.name)
+	return s.rollbackErr
+}
+
+func rollbackWorkflow(rolledBack *[]string) Workflow {
+	m := &sync.Mutex{}
+
+	return Workflow{
+		&rollbackStep{MockStep: MockStep{name: "machine"}, rolledBack: rolledBack, m: m},
+		&rollbackStep{
+			MockStep:    MockStep{name: "keys", depends: []string{"machine"}},
+			rollbackErr: errors.New("keys are gone"),
+			rolledBack:  rolledBack,
+			m:           m,
+		},
+		&rollbackStep{MockStep: MockStep{name: "docker", depends: []string{"keys"}}, rolledBack: rolledBack, m: m},
+		&rollbackStep{
+			MockStep:   MockStep{name: "kubelet", depends: []string{"docker"}},
+			err:        errors.New("kubelet"),
+			rolledBack: rolledBack,
+			m:          m,
+		},
+		&rollbackStep{MockStep: MockStep{name: "tiller", depends: []string{"kubelet"}}, rolledBack: rolledBack, m: m},
+	}
+}
+
+func TestTaskRollbackOnFailure(t *testing.T) {
+	rolledBack := make([]string, 0)
+	task := &Task{
+		ID: "rollback",
+		repository: &MockRepository{
+			storage: make(map[string][]byte),
+		},
+		workflow: rollbackWorkflow(&rolledBack),
+	}
+
+	err := <-task.Run(context.Background(), steps.Config{RollbackOnFailure: true}, &bufferCloser{})
+	require.Error(t, err)
+
+	require.Equal(t, []string{"kubelet", "docker", "keys", "machine"}, rolledBack)
+	expected := []struct {
+		status         steps.Status
+		rollbackStatus steps.Status
+	}{
+		{steps.StatusTodo, steps.StatusSuccess},
+		{steps.StatusSuccess, steps.StatusError},
+		{steps.StatusTodo, steps.StatusSuccess},
+		{steps.StatusError, steps.StatusSuccess},
+		{steps.StatusTodo, ""},
+	}
+
+	for i, e := range expected {
+		require.Equal(t, e.status, task.StepStatuses[i].Status, task.StepStatuses[i].StepName)
+		require.Equal(t, e.rollbackStatus, task.StepStatuses[i].RollbackStatus, task.StepStatuses[i].StepName)
+	}
+	require.Equal(t, "keys are gone", task.StepStatuses[1].RollbackErrMsg)
+}
+
+func TestTaskRollbackFailedStepOnly(t *testing.T) {
+	rolledBack := make([]string, 0)
+	task := &Task{
+		ID: "rollback",
+		repository: &MockRepository{
+			storage: make(map[string][]byte),
+		},
+		workflow: rollbackWorkflow(&rolledBack),
+	}
+
+	require.Error(t, <-task.Run(context.Background(), steps.Config{}, &bufferCloser{}))
+	require.Equal(t, []string{"kubelet"}, rolledBack)
+	require.Equal(t, steps.StatusSuccess, task.StepStatuses[3].RollbackStatus)
+	require.Equal(t, steps.StatusSuccess, task.StepStatuses[0].Status)
+	require.Empty(t, task.StepStatuses[0].RollbackStatus)
+}
+
+func TestTaskRollbackOnCancel(t *testing.T) {
+	for _, rollback := range []bool{true, false} {
+		rolledBack := make([]string, 0)
+		blocking := &blockingStep{MockStep{name: "blocking", depends: []string{"machine"}}}
+		task := &Task{
+			ID: "rollback-cancel",
+			repository: &MockRepository{
+				storage: make(map[string][]byte),
+			},
+			workflow: Workflow{
+				&rollbackStep{MockStep: MockStep{name: "machine"}, rolledBack: &rolledBack, m: &sync.Mutex{}},
+				blocking,
+			},
+		}
+
+		errChan := task.Run(context.Background(), steps.Config{RollbackOnFailure: true}, &bufferCloser{})
+		waitRunning(t, task, 1)
+		require.NoError(t, Cancel(task.ID, rollback))
+		require.Error(t, <-errChan)
+
+		require.Equal(t, rollback, blocking.rollback)
+		if rollback {
+			require.Equal(t, []string{"machine"}, rolledBack)
+			require.Equal(t, steps.StatusTodo, task.StepStatuses[0].Status)
+		} else {
+			require.Empty(t, rolledBack)
+			require.Equal(t, steps.StatusSuccess, task.StepStatuses[0].Status)
+		}
+	}
+}
@@ -0,0 +1,356 @@
+package workflows
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/supergiant/supergiant/pkg/workflows/steps"
+	"github.com/supergiant/supergiant/pkg/workflows/steps/amazon"
+	"github.com/supergiant/supergiant/pkg/workflows/steps/digitalocean"
+)
+
+const (
+	// DefaultMaxRunningTasks is the number of tasks this process runs at the same time
+	DefaultMaxRunningTasks = 10
+	// DefaultCloudRate is the number of cloud API steps of an account started per second
+	DefaultCloudRate = 1.0
+	// DefaultCloudBurst is the number of cloud API steps of an account started at once
+	DefaultCloudBurst = 5
+)
+
+// cloudAPISteps call APIs of cloud providers, they are rate limited by cloud account
+var cloudAPISteps = map[string]bool{
+	digitalocean.CreateMachineStepName: true,
+	digitalocean.DeleteMachineStepName: true,
+	digitalocean.DeleteClusterStepName: true,
+	amazon.StepName:                    true,
+	amazon.StepNameCreateEC2Instance:   true,
+}
+
+// ticket is a place of the task in the queue, ready is closed when the task may run
+type ticket struct {
+	ready   chan struct{}
+	granted bool
+	master  bool
+}
+
+// scheduler limits the number of running tasks, tasks that wait for their turn are
+// queued by cluster. Clusters take turns, tasks of a cluster run in the order they came.
+//
+// Etcd of a master waits for all masters of the cluster, so masters of a cluster are
+// admitted together: once one of them gets a place the rest run too, even over the limit.
+type scheduler struct {
+	m       sync.Mutex
+	limit   int
+	running int
+	queues  map[string][]*ticket
+	// clusters that have queued tasks in the order of their turns
+	turns []string
+	// number of running masters by cluster
+	masters map[string]int
+}
+
+func newScheduler(limit int) *scheduler {
+	return &scheduler{
+		limit:   limit,
+		queues:  make(map[string][]*ticket),
+		masters: make(map[string]int),
+	}
+}
+
+var taskScheduler = newScheduler(DefaultMaxRunningTasks)
+
+// SetMaxRunningTasks limits the number of tasks that run at the same time,
+// values below one make tasks run one by one. Queued tasks are started if the limit grows.
+// Masters of a cluster take a place together, the rest of them run over the limit
+// so the masters that wait for each other never wait for a place.
+func SetMaxRunningTasks(n int) {
+	if n < 1 {
+		n = 1
+	}
+
+	taskScheduler.m.Lock()
+	defer taskScheduler.m.Unlock()
+
+	taskScheduler.limit = n
+	taskScheduler.dispatch()
+}
+
+// acquire waits until the task of the cluster may run, queued is called when the task has to wait.
+// Task that has been admitted must call release when it is done.
+func (s *scheduler) acquire(ctx context.Context, clusterName string, queued func()) error {
+	return s.admit(ctx, clusterName, false, queued)
+}
+
+// acquireMaster waits until the master task of the cluster may run, it runs right away when other
+// masters of the cluster are running. Task that has been admitted must call releaseMaster when it is done.
+func (s *scheduler) acquireMaster(ctx context.Context, clusterName string, queued func()) error {
+	return s.admit(ctx, clusterName, true, queued)
+}
+
+func (s *scheduler) admit(ctx context.Context, clusterName string, master bool, queued func()) error {
+	s.m.Lock()
+	if (s.running < s.limit && len(s.turns) == 0) || (master && s.masters[clusterName] > 0) {
+		s.start(clusterName, master)
+		s.m.Unlock()
+		return nil
+	}
+
+	t := &ticket{ready: make(chan struct{}), master: master}
+	if _, ok := s.queues[clusterName]; !ok {
+		s.turns = append(s.turns, clusterName)
+	}
+	s.queues[clusterName] = append(s.queues[clusterName], t)
+	s.m.Unlock()
+
+	queued()
+
+	select {
+	case <-t.ready:
+		return nil
+	case <-ctx.Done():
+	}
+
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	// Turn may come at the same time the task is stopped
+	if t.granted {
+		s.stop(clusterName, master)
+		s.dispatch()
+	} else {
+		s.remove(clusterName, t)
+	}
+
+	return ctx.Err()
+}
+
+func (s *scheduler) release() {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.running--
+	s.dispatch()
+}
+
+func (s *scheduler) releaseMaster(clusterName string) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.stop(clusterName, true)
+	s.dispatch()
+}
+
+func (s *scheduler) start(clusterName string, master bool) {
+	s.running++
+	if master {
+		s.masters[clusterName]++
+	}
+}
+
+func (s *scheduler) stop(clusterName string, master bool) {
+	s.running--
+	if !master {
+		return
+	}
+
+	s.masters[clusterName]--
+	if s.masters[clusterName] == 0 {
+		delete(s.masters, clusterName)
+	}
+}
+
+// dispatch starts queued tasks while there is room, a cluster
+// that has got its turn goes to the end of the line.
+func (s *scheduler) dispatch() {
+	for s.running < s.limit && len(s.turns) > 0 {
+		clusterName := s.turns[0]
+		s.turns = s.turns[1:]
+
+		queue := s.queues[clusterName]
+		t := queue[0]
+		if len(queue) > 1 {
+			s.queues[clusterName] = queue[1:]
+			s.turns = append(s.turns, clusterName)
+		} else {
+			delete(s.queues, clusterName)
+		}
+
+		s.grant(clusterName, t)
+
+		// Masters that wait for the one that has got the place start with it
+		if t.master {
+			for _, queued := range append([]*ticket{}, s.queues[clusterName]...) {
+				if queued.master {
+					s.remove(clusterName, queued)
+					s.grant(clusterName, queued)
+				}
+			}
+		}
+	}
+}
+
+func (s *scheduler) grant(clusterName string, t *ticket) {
+	t.granted = true
+	s.start(clusterName, t.master)
+	close(t.ready)
+}
+
+func (s *scheduler) remove(clusterName string, t *ticket) {
+	queue := s.queues[clusterName]
+	for i := range queue {
+		if queue[i] == t {
+			queue = append(queue[:i], queue[i+1:]...)
+			break
+		}
+	}
+
+	if len(queue) > 0 {
+		s.queues[clusterName] = queue
+		return
+	}
+
+	delete(s.queues, clusterName)
+	for i := range s.turns {
+		if s.turns[i] == clusterName {
+			s.turns = append(s.turns[:i], s.turns[i+1:]...)
+			break
+		}
+	}
+}
+
+// schedule waits for the turn of the task, the task is saved as queued while it waits.
+// Returned function gives the place of the task to the next one.
+func (w *Task) schedule(ctx context.Context) (func(), error) {
+	clusterName := w.clusterName()
+	queued := func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+
+		w.Status = steps.StatusQueued
+		if err := w.sync(ctx); err != nil {
+			logrus.Errorf("sync error %v for task %s", err, w.ID)
+		}
+	}
+
+	// Etcd of the master waits for all masters of the cluster, masters that had to wait
+	// for a place would never let the running ones finish.
+	if w.Config != nil && w.Config.IsMaster {
+		if err := taskScheduler.acquireMaster(ctx, clusterName, queued); err != nil {
+			return nil, err
+		}
+
+		return func() {
+			taskScheduler.releaseMaster(clusterName)
+		}, nil
+	}
+
+	if err := taskScheduler.acquire(ctx, clusterName, queued); err != nil {
+		return nil, err
+	}
+
+	return taskScheduler.release, nil
+}
+
+// tokenBucket lets rate events per second happen with bursts of up to burst events
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// reserve takes a token and returns the time to wait until the token is available
+func (b *tokenBucket) reserve(now time.Time) time.Duration {
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+	b.tokens--
+
+	if b.tokens >= 0 {
+		return 0
+	}
+
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// rateLimiter keeps token bucket of every cloud account
+type rateLimiter struct {
+	m       sync.Mutex
+	rate    float64
+	burst   int
+	buckets map[string]*tokenBucket
+}
+
+var cloudLimiter = &rateLimiter{
+	rate:    DefaultCloudRate,
+	burst:   DefaultCloudBurst,
+	buckets: make(map[string]*tokenBucket),
+}
+
+// SetCloudRateLimit limits the rate of cloud API steps of every cloud account,
+// zero rate turns the limit off. Burst below one is treated as one.
+func SetCloudRateLimit(rate float64, burst int) {
+	if burst < 1 {
+		burst = 1
+	}
+
+	cloudLimiter.m.Lock()
+	defer cloudLimiter.m.Unlock()
+
+	cloudLimiter.rate = rate
+	cloudLimiter.burst = burst
+	cloudLimiter.buckets = make(map[string]*tokenBucket)
+}
+
+// wait blocks until the account may call cloud API or ctx is done
+func (l *rateLimiter) wait(ctx context.Context, account string) error {
+	l.m.Lock()
+	if l.rate <= 0 {
+		l.m.Unlock()
+		return nil
+	}
+
+	now := time.Now()
+	b, ok := l.buckets[account]
+	if !ok {
+		b = &tokenBucket{
+			rate:   l.rate,
+			burst:  float64(l.burst),
+			tokens: float64(l.burst),
+			last:   now,
+		}
+		l.buckets[account] = b
+	}
+	delay := b.reserve(now)
+	l.m.Unlock()
+
+	if delay == 0 {
+		return nil
+	}
+
+	if sleep(ctx, delay) {
+		return nil
+	}
+
+	// Token that has not been used is given back
+	l.m.Lock()
+	b.tokens++
+	l.m.Unlock()
+
+	return ctx.Err()
+}
+
+// waitCloudAPI rate limits steps that call cloud API by cloud account of the task
+func (w *Task) waitCloudAPI(ctx context.Context, step steps.Step) error {
+	if !cloudAPISteps[step.Name()] || w.Config == nil || w.Config.DryRun {
+		return nil
+	}
+
+	return cloudLimiter.wait(ctx, w.Config.CloudAccountName)
+}
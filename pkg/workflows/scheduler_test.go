@@ -0,0 +1,217 @@
+package workflows
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/supergiant/supergiant/pkg/node"
+	"github.com/supergiant/supergiant/pkg/workflows/steps"
+)
+
+func TestSchedulerClustersTakeTurns(t *testing.T) {
+	s := newScheduler(1)
+	require.NoError(t, s.acquire(context.Background(), "a", func() {}))
+
+	started := make(chan string)
+	for _, id := range []string{"a1", "a2", "a3", "b1"} {
+		queued := make(chan struct{})
+		go func(id string) {
+			require.NoError(t, s.acquire(context.Background(), id[:1], func() { close(queued) }))
+			started <- id
+		}(id)
+		<-queued
+	}
+
+	order := make([]string, 0, 4)
+	for range []int{1, 2, 3, 4} {
+		s.release()
+		order = append(order, <-started)
+	}
+
+	require.Equal(t, []string{"a1", "b1", "a2", "a3"}, order)
+}
+
+func TestSchedulerStopQueued(t *testing.T) {
+	s := newScheduler(1)
+	require.NoError(t, s.acquire(context.Background(), "a", func() {}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errChan := make(chan error)
+	go func() {
+		errChan <- s.acquire(ctx, "b", cancel)
+	}()
+
+	require.Equal(t, context.Canceled, <-errChan)
+	require.Empty(t, s.queues)
+	require.Empty(t, s.turns)
+
+	s.release()
+	require.Equal(t, 0, s.running)
+}
+
+func TestSchedulerMastersTakePlaceTogether(t *testing.T) {
+	s := newScheduler(1)
+	require.NoError(t, s.acquire(context.Background(), "b", func() {}))
+
+	started := make(chan string)
+	for _, id := range []string{"a1", "a2", "a3"} {
+		queued := make(chan struct{})
+		go func(id string) {
+			require.NoError(t, s.acquireMaster(context.Background(), "a", func() { close(queued) }))
+			started <- id
+		}(id)
+		<-queued
+	}
+
+	queued := make(chan struct{})
+	go func() {
+		require.NoError(t, s.acquire(context.Background(), "c", func() { close(queued) }))
+		started <- "c1"
+	}()
+	<-queued
+
+	// All the masters start once one of them has got the place
+	s.release()
+	order := []string{<-started, <-started, <-started}
+	require.ElementsMatch(t, []string{"a1", "a2", "a3"}, order)
+
+	// Master that comes while masters of its cluster run does not wait
+	require.NoError(t, s.acquireMaster(context.Background(), "a", func() { t.Error("master has been queued") }))
+
+	for range []int{1, 2, 3, 4} {
+		s.releaseMaster("a")
+	}
+	require.Equal(t, "c1", <-started)
+	s.release()
+
+	require.Equal(t, 0, s.running)
+	require.Empty(t, s.masters)
+}
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Now()
+	b := &tokenBucket{rate: 2, burst: 2, tokens: 2, last: now}
+
+	require.Equal(t, time.Duration(0), b.reserve(now))
+	require.Equal(t, time.Duration(0), b.reserve(now))
+	require.Equal(t, 500*time.Millisecond, b.reserve(now))
+	require.Equal(t, time.Second, b.reserve(now))
+
+	// Tokens never pile up above the burst
+	require.Equal(t, time.Duration(0), b.reserve(now.Add(time.Hour)))
+	require.Equal(t, time.Duration(0), b.reserve(now.Add(time.Hour)))
+	require.Equal(t, 500*time.Millisecond, b.reserve(now.Add(time.Hour)))
+}
+
+func TestTaskQueued(t *testing.T) {
+	SetMaxRunningTasks(1)
+	defer SetMaxRunningTasks(DefaultMaxRunningTasks)
+
+	running := &Task{
+		ID:         "running",
+		repository: &MockRepository{storage: make(map[string][]byte)},
+		workflow:   Workflow{&blockingStep{MockStep{name: "blocking"}}},
+	}
+	queued := &Task{
+		ID:         "queued",
+		repository: &MockRepository{storage: make(map[string][]byte)},
+		workflow:   Workflow{&MockStep{name: "step"}},
+	}
+
+	runningErr := running.Run(context.Background(), steps.Config{}, &bufferCloser{})
+	waitRunning(t, running, 0)
+
+	queuedErr := queued.Run(context.Background(), steps.Config{}, &bufferCloser{})
+	for start := time.Now(); time.Since(start) < time.Second; time.Sleep(time.Millisecond) {
+		queued.mu.Lock()
+		status := queued.Status
+		queued.mu.Unlock()
+
+		if status == steps.StatusQueued {
+			break
+		}
+	}
+
+	queued.mu.Lock()
+	require.Equal(t, steps.StatusQueued, queued.Status)
+	require.Equal(t, steps.StatusTodo, queued.StepStatuses[0].Status)
+	queued.mu.Unlock()
+
+	require.NoError(t, Cancel(running.ID, false))
+	require.Error(t, <-runningErr)
+	require.NoError(t, <-queuedErr)
+	require.Equal(t, steps.StatusSuccess, queued.Status)
+}
+
+func TestRateLimiterCancel(t *testing.T) {
+	l := &rateLimiter{rate: 1, burst: 1, buckets: make(map[string]*tokenBucket)}
+	require.NoError(t, l.wait(context.Background(), "account"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	require.Error(t, l.wait(ctx, "account"))
+
+	// Other accounts are not limited
+	require.NoError(t, l.wait(context.Background(), "other"))
+}
+
+// siblingStep waits for the steps of all the masters to start the way etcd does
+type siblingStep struct {
+	MockStep
+	wg *sync.WaitGroup
+}
+
+func (s *siblingStep) Run(ctx context.Context, out io.Writer, config *steps.Config) error {
+	s.wg.Done()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-time.After(time.Second):
+		return fmt.Errorf("siblings of %s have not started", config.Node.Name)
+	}
+}
+
+func TestTaskMastersOverLimit(t *testing.T) {
+	SetMaxRunningTasks(1)
+	defer SetMaxRunningTasks(DefaultMaxRunningTasks)
+
+	masters := 3
+	wg := &sync.WaitGroup{}
+	wg.Add(masters)
+
+	errChans := make([]chan error, 0, masters)
+	for i := 0; i < masters; i++ {
+		task := &Task{
+			ID:         fmt.Sprintf("master-%d", i),
+			repository: &MockRepository{storage: make(map[string][]byte)},
+			workflow:   Workflow{&siblingStep{MockStep{name: "etcd"}, wg}},
+		}
+
+		errChans = append(errChans, task.Run(context.Background(), steps.Config{
+			ClusterName: "test",
+			IsMaster:    true,
+			Node:        node.Node{Name: task.ID},
+		}, &bufferCloser{}))
+	}
+
+	for _, errChan := range errChans {
+		require.NoError(t, <-errChan)
+	}
+
+	taskScheduler.m.Lock()
+	defer taskScheduler.m.Unlock()
+	require.Equal(t, 0, taskScheduler.running)
+}
@@ -0,0 +1,314 @@
+package workflows
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"regexp"
+	"text/template"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/supergiant/supergiant/pkg/sgerrors"
+	"github.com/supergiant/supergiant/pkg/storage"
+	"github.com/supergiant/supergiant/pkg/workflows/steps"
+)
+
+// ScriptPrefix keeps script steps registered through the API
+const ScriptPrefix = "/supergiant/steps/"
+
+// ScriptRole selects machines of the cluster the script runs on
+type ScriptRole string
+
+const (
+	RoleMaster ScriptRole = "master"
+	RoleNode   ScriptRole = "node"
+	RoleAll    ScriptRole = "all"
+)
+
+// scriptWatchRetry is the pause before the watch of scripts is started again
+const scriptWatchRetry = 5 * time.Second
+
+var scriptNameRe = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// Script is a user-defined step, its template is rendered against the config
+// of the task and run on the machine the same way templates of built-in steps are.
+type Script struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Template    string `json:"template"`
+	// Timeout limits a single run of the script, zero means no limit
+	Timeout Duration `json:"timeout,omitempty"`
+	// Role is all when empty
+	Role    ScriptRole `json:"role,omitempty"`
+	Depends []string   `json:"depends,omitempty"`
+}
+
+// ScriptStep runs the script on the machines of its role and skips the rest
+type ScriptStep struct {
+	script Script
+	tpl    *template.Template
+}
+
+// NewScriptStep validates the script and parses its template
+func NewScriptStep(script Script) (*ScriptStep, error) {
+	if !scriptNameRe.MatchString(script.Name) {
+		return nil, errors.Errorf("script name %q must be alphanumeric with _ . - allowed", script.Name)
+	}
+
+	switch script.Role {
+	case "":
+		script.Role = RoleAll
+	case RoleMaster, RoleNode, RoleAll:
+	default:
+		return nil, errors.Errorf("script %s: unknown role %s", script.Name, script.Role)
+	}
+
+	if script.Timeout.Duration < 0 {
+		return nil, errors.Errorf("script %s: timeout can't be negative", script.Name)
+	}
+
+	if step := steps.GetStep(script.Name); step != nil {
+		if _, ok := step.(*ScriptStep); !ok {
+			return nil, errors.Errorf("script %s: built-in step has the same name", script.Name)
+		}
+	}
+
+	for _, dep := range script.Depends {
+		if dep == script.Name {
+			return nil, errors.Errorf("script %s depends on itself", script.Name)
+		}
+
+		if steps.GetStep(dep) == nil {
+			return nil, errors.Errorf("script %s depends on unknown step %s", script.Name, dep)
+		}
+	}
+
+	tpl, err := template.New(script.Name).Parse(script.Template)
+	if err != nil {
+		return nil, errors.Wrapf(err, "script %s", script.Name)
+	}
+
+	return &ScriptStep{
+		script: script,
+		tpl:    tpl,
+	}, nil
+}
+
+func (s *ScriptStep) Run(ctx context.Context, out io.Writer, config *steps.Config) error {
+	if !s.runsOn(config) {
+		logrus.Debugf("script %s is skipped on the machine of another role", s.script.Name)
+		return nil
+	}
+
+	if s.script.Timeout.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.script.Timeout.Duration)
+		defer cancel()
+	}
+
+	if err := steps.RunTemplate(ctx, s.tpl, config.Runner, out, config); err != nil {
+		return errors.Wrapf(err, "script %s", s.script.Name)
+	}
+
+	return nil
+}
+
+func (s *ScriptStep) runsOn(config *steps.Config) bool {
+	switch s.script.Role {
+	case RoleMaster:
+		return config.IsMaster
+	case RoleNode:
+		return !config.IsMaster
+	}
+
+	return true
+}
+
+func (s *ScriptStep) Rollback(context.Context, io.Writer, *steps.Config) error {
+	return nil
+}
+
+func (s *ScriptStep) Name() string {
+	return s.script.Name
+}
+
+func (s *ScriptStep) Description() string {
+	return s.script.Description
+}
+
+func (s *ScriptStep) Depends() []string {
+	return s.script.Depends
+}
+
+// Script returns definition of the step
+func (s *ScriptStep) Script() Script {
+	return s.script
+}
+
+// RegisterScript saves the script and registers its step, the script
+// with the same name is replaced. Tasks that are running keep the old one.
+// Other replicas register the step once they see the change in WatchScripts.
+func RegisterScript(ctx context.Context, repository storage.Interface, script Script) (*ScriptStep, error) {
+	step, err := NewScriptStep(script)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(step.script)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := repository.Put(ctx, ScriptPrefix, script.Name, data); err != nil {
+		return nil, errors.Wrapf(err, "save script %s", script.Name)
+	}
+	steps.RegisterStep(script.Name, step)
+
+	return step, nil
+}
+
+// LoadScripts registers steps of the saved scripts, scripts that
+// are not valid anymore are skipped so the rest can be used.
+func LoadScripts(ctx context.Context, repository storage.Interface) error {
+	data, err := repository.GetAll(ctx, ScriptPrefix)
+	if err != nil {
+		return errors.Wrap(err, "read scripts")
+	}
+
+	// Scripts may depend on each other, they are registered until no more can be
+	pending := make([]Script, 0, len(data))
+	for _, raw := range data {
+		script := Script{}
+		if err := json.Unmarshal(raw, &script); err != nil {
+			logrus.Errorf("load scripts: %v", err)
+			continue
+		}
+		pending = append(pending, script)
+	}
+
+	errs := make(map[string]error)
+	for registered := true; registered && len(pending) > 0; {
+		registered = false
+		rest := pending[:0]
+
+		for _, script := range pending {
+			step, err := NewScriptStep(script)
+			if err != nil {
+				errs[script.Name] = err
+				rest = append(rest, script)
+				continue
+			}
+
+			steps.RegisterStep(script.Name, step)
+			registered = true
+		}
+		pending = rest
+	}
+
+	for _, script := range pending {
+		logrus.Errorf("load scripts: %v", errs[script.Name])
+	}
+
+	return nil
+}
+
+// WatchScripts registers scripts saved by other replicas until ctx is done. Scripts
+// are loaded again every time the watch starts, so changes made while it was down are not missed.
+func WatchScripts(ctx context.Context, repository storage.Interface) {
+	for {
+		events, err := repository.Watch(ctx, ScriptPrefix)
+		if err != nil {
+			logrus.Errorf("watch scripts: %v", err)
+		} else {
+			if err := LoadScripts(ctx, repository); err != nil {
+				logrus.Errorf("watch scripts: %v", err)
+			}
+
+			for ev := range events {
+				if ev.Type == storage.EventPut {
+					registerScript(ev.Value)
+				}
+			}
+		}
+
+		if !sleep(ctx, scriptWatchRetry) {
+			return
+		}
+	}
+}
+
+func registerScript(data []byte) {
+	script := Script{}
+	if err := json.Unmarshal(data, &script); err != nil {
+		logrus.Errorf("watch scripts: %v", err)
+		return
+	}
+
+	step, err := NewScriptStep(script)
+	if err != nil {
+		logrus.Errorf("watch scripts: %v", err)
+		return
+	}
+
+	steps.RegisterStep(script.Name, step)
+}
+
+// Scripts returns saved scripts
+func Scripts(ctx context.Context, repository storage.Interface) ([]Script, error) {
+	data, err := repository.GetAll(ctx, ScriptPrefix)
+	if err != nil {
+		return nil, errors.Wrap(err, "read scripts")
+	}
+
+	scripts := make([]Script, 0, len(data))
+	for _, raw := range data {
+		script := Script{}
+		if err := json.Unmarshal(raw, &script); err != nil {
+			return nil, errors.Wrap(err, "unmarshal script")
+		}
+		scripts = append(scripts, script)
+	}
+
+	return scripts, nil
+}
+
+// AppendSteps adds registered steps to the workflow of the task that has not been run,
+// they run one by one once all the steps of the workflow have succeeded.
+func (w *Task) AppendSteps(names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+
+	// Workflow of the task is shared with other tasks of the same type
+	workflow := make(Workflow, len(w.workflow), len(w.workflow)+len(names))
+	copy(workflow, w.workflow)
+
+	for _, name := range names {
+		step := steps.GetStep(name)
+		if step == nil {
+			return errors.Wrapf(sgerrors.ErrNotFound, "step %s", name)
+		}
+
+		depends := make([]string, 0, len(workflow))
+		for _, s := range workflow {
+			if s != nil {
+				depends = append(depends, s.Name())
+			}
+		}
+
+		workflow = append(workflow, &definedStep{
+			Step:    step,
+			depends: depends,
+		})
+	}
+
+	if err := Validate(workflow); err != nil {
+		return errors.Wrapf(err, "task %s", w.ID)
+	}
+	w.workflow = workflow
+
+	return nil
+}
@@ -0,0 +1,183 @@
+package workflows
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/supergiant/supergiant/pkg/testutils"
+	"github.com/supergiant/supergiant/pkg/workflows/steps"
+)
+
+func TestNewScriptStep(t *testing.T) {
+	steps.RegisterStep("script_builtin", &MockStep{name: "script_builtin"})
+
+	testCases := []struct {
+		name   string
+		script Script
+		err    bool
+	}{
+		{
+			name:   "valid",
+			script: Script{Name: "harden", Template: "echo {{ .ClusterName }}", Depends: []string{"script_builtin"}},
+		},
+		{
+			name:   "empty name",
+			script: Script{Template: "echo"},
+			err:    true,
+		},
+		{
+			name:   "name of built-in step",
+			script: Script{Name: "script_builtin", Template: "echo"},
+			err:    true,
+		},
+		{
+			name:   "unknown role",
+			script: Script{Name: "harden", Template: "echo", Role: "etcd"},
+			err:    true,
+		},
+		{
+			name:   "negative timeout",
+			script: Script{Name: "harden", Template: "echo", Timeout: Duration{-time.Second}},
+			err:    true,
+		},
+		{
+			name:   "unknown dependency",
+			script: Script{Name: "harden", Template: "echo", Depends: []string{"unknown"}},
+			err:    true,
+		},
+		{
+			name:   "broken template",
+			script: Script{Name: "harden", Template: "echo {{ .ClusterName"},
+			err:    true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		step, err := NewScriptStep(testCase.script)
+		if testCase.err {
+			require.Error(t, err, testCase.name)
+			continue
+		}
+
+		require.NoError(t, err, testCase.name)
+		require.Equal(t, RoleAll, step.Script().Role, testCase.name)
+	}
+}
+
+func TestScriptStepRun(t *testing.T) {
+	step, err := NewScriptStep(Script{
+		Name:     "agent",
+		Template: "install agent to {{ .ClusterName }}",
+		Role:     RoleMaster,
+	})
+	require.NoError(t, err)
+
+	out := &bytes.Buffer{}
+	config := &steps.Config{ClusterName: "test", Runner: &testutils.MockRunner{}}
+	require.NoError(t, step.Run(context.Background(), out, config))
+	require.Empty(t, out.String(), "script of masters has run on the node")
+
+	config.IsMaster = true
+	require.NoError(t, step.Run(context.Background(), out, config))
+	require.Equal(t, "install agent to test", out.String())
+}
+
+func TestRegisterAndLoadScripts(t *testing.T) {
+	repository, cleanup := tempRepository(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	_, err := RegisterScript(ctx, repository, Script{Name: "script_first", Template: "echo first"})
+	require.NoError(t, err)
+	_, err = RegisterScript(ctx, repository, Script{
+		Name:     "script_second",
+		Template: "echo second",
+		Depends:  []string{"script_first"},
+	})
+	require.NoError(t, err)
+
+	scripts, err := Scripts(ctx, repository)
+	require.NoError(t, err)
+	require.Len(t, scripts, 2)
+
+	// Scripts are registered regardless of the order they are read in
+	steps.RegisterStep("script_first", nil)
+	steps.RegisterStep("script_second", nil)
+	require.NoError(t, LoadScripts(ctx, repository))
+	require.NotNil(t, steps.GetStep("script_first"))
+	require.NotNil(t, steps.GetStep("script_second"))
+}
+
+func TestTaskAppendSteps(t *testing.T) {
+	steps.RegisterStep("append_script", &MockStep{name: "append_script"})
+	workflow := Workflow{&MockStep{name: "a"}, &MockStep{name: "b"}}
+	task := newTask("test", workflow, nil)
+
+	require.NoError(t, task.AppendSteps([]string{"append_script"}))
+	require.Len(t, task.workflow, 3)
+	require.Len(t, workflow, 2, "registered workflow has been changed")
+	require.Equal(t, []string{"a", "b"}, task.workflow[2].Depends())
+
+	require.Error(t, task.AppendSteps([]string{"unknown"}))
+	require.Error(t, task.AppendSteps([]string{"append_script"}), "step is used twice")
+}
+
+func TestTaskHandlerCreateScript(t *testing.T) {
+	repository, cleanup := tempRepository(t)
+	defer cleanup()
+	h := &TaskHandler{repository: repository}
+
+	resp := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/steps",
+		strings.NewReader(`{"name": "handler_script", "template": "echo", "timeout": "1m", "role": "node"}`))
+	h.CreateScript(resp, req)
+	require.Equal(t, http.StatusCreated, resp.Code)
+	require.NotNil(t, steps.GetStep("handler_script"))
+
+	resp = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodPost, "/steps", strings.NewReader(`{"name": "handler_script", "role": "all nodes"}`))
+	h.CreateScript(resp, req)
+	require.Equal(t, http.StatusBadRequest, resp.Code)
+
+	resp = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/steps", nil)
+	h.ListScripts(resp, req)
+	require.Equal(t, http.StatusOK, resp.Code)
+
+	scripts := make([]Script, 0)
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&scripts))
+	require.Len(t, scripts, 1)
+	require.Equal(t, time.Minute, scripts[0].Timeout.Duration)
+	require.Equal(t, RoleNode, scripts[0].Role)
+}
+
+func TestWatchScripts(t *testing.T) {
+	repository, cleanup := tempRepository(t)
+	defer cleanup()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	steps.RegisterStep("watched_script", nil)
+	go WatchScripts(ctx, repository)
+
+	// Script saved by another replica
+	data, err := json.Marshal(Script{Name: "watched_script", Template: "echo"})
+	require.NoError(t, err)
+	require.NoError(t, repository.Put(ctx, ScriptPrefix, "watched_script", data))
+
+	for start := time.Now(); time.Since(start) < time.Second; time.Sleep(time.Millisecond) {
+		if steps.GetStep("watched_script") != nil {
+			break
+		}
+	}
+	require.NotNil(t, steps.GetStep("watched_script"))
+}
@@ -1,6 +1,8 @@
 package amazon
 
 import (
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
 	"github.com/pkg/errors"
 	"github.com/supergiant/supergiant/pkg/clouds/awssdk"
 	"github.com/supergiant/supergiant/pkg/workflows/steps"
@@ -13,3 +15,18 @@ func GetSDK(cfg steps.AWSConfig) (*awssdk.SDK, error) {
 	}
 	return sdk, nil
 }
+
+// GetEC2 returns EC2 client of the account, steps get it through a field to be tested with mocks
+func GetEC2(cfg steps.AWSConfig) (ec2iface.EC2API, error) {
+	sdk, err := GetSDK(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return sdk.EC2, nil
+}
+
+// isErrCode tells whether err is an AWS error with the code
+func isErrCode(err error, code string) bool {
+	awsErr, ok := errors.Cause(err).(awserr.Error)
+	return ok && awsErr.Code() == code
+}
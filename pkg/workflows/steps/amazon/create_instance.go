@@ -7,6 +7,7 @@ import (
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"github.com/supergiant/supergiant/pkg/clouds"
@@ -22,6 +23,7 @@ const (
 )
 
 type StepCreateInstance struct {
+	getEC2 func(steps.AWSConfig) (ec2iface.EC2API, error)
 }
 
 //InitStepCreateInstance adds the step to the registry
@@ -30,7 +32,9 @@ func InitStepCreateInstance() {
 }
 
 func NewCreateInstance() *StepCreateInstance {
-	return &StepCreateInstance{}
+	return &StepCreateInstance{
+		getEC2: GetEC2,
+	}
 }
 
 func (s *StepCreateInstance) Run(ctx context.Context, w io.Writer, cfg *steps.Config) error {
@@ -117,38 +121,46 @@ func (s *StepCreateInstance) Run(ctx context.Context, w io.Writer, cfg *steps.Co
 		role = node.RoleNode
 	}
 
+	cfg.Lock()
 	cfg.Node = node.Node{
 		Region:   cfg.AWSConfig.Region,
 		Role:     role,
 		Provider: clouds.AWS,
 		State:    node.StateBuilding,
 	}
+	cfg.Unlock()
 
 	// Update node state in cluster
-	cfg.NodeChan() <- cfg.Node
+	cfg.NodeChan() <- cfg.CurrentNode()
 
 	res, err := sdk.EC2.RunInstances(runInstanceInput)
 	if err != nil {
+		cfg.Lock()
 		cfg.Node.State = node.StateError
-		cfg.NodeChan() <- cfg.Node
+		cfg.Unlock()
+		cfg.NodeChan() <- cfg.CurrentNode()
 
 		log.Errorf("[%s] - failed to create ec2 instance: %v", StepNameCreateEC2Instance, err)
 		return errors.Wrap(err, "aws: failed to connect")
 	}
 	if len(res.Instances) == 0 {
+		cfg.Lock()
 		cfg.Node.State = node.StateError
-		cfg.NodeChan() <- cfg.Node
+		cfg.Unlock()
+		cfg.NodeChan() <- cfg.CurrentNode()
 
 		return errors.Wrap(err, "aws: no instances created")
 	}
 	instance := res.Instances[0]
 
+	cfg.Lock()
 	cfg.Node.Region = cfg.AWSConfig.Region
 	cfg.Node.CreatedAt = instance.LaunchTime.Unix()
 	cfg.Node.Id = *instance.InstanceId
+	cfg.Unlock()
 
 	// Update node state in cluster
-	cfg.NodeChan() <- cfg.Node
+	cfg.NodeChan() <- cfg.CurrentNode()
 
 	if ec2Cfg.HasPublicAddr {
 		log.Infof("[%s] - waiting to obtain public IP", StepNameCreateEC2Instance)
@@ -170,8 +182,10 @@ func (s *StepCreateInstance) Run(ctx context.Context, w io.Writer, cfg *steps.Co
 			}
 			out, err := sdk.EC2.DescribeInstancesWithContext(ctx, lookup)
 			if err != nil {
+				cfg.Lock()
 				cfg.Node.State = node.StateError
-				cfg.NodeChan() <- cfg.Node
+				cfg.Unlock()
+				cfg.NodeChan() <- cfg.CurrentNode()
 				log.Errorf("[%s] - failed to obtain public IP for node %s: %v", StepNameCreateEC2Instance, nodeName, err)
 				return errors.Wrap(err, "aws: failed to obtain public IP")
 			}
@@ -183,16 +197,20 @@ func (s *StepCreateInstance) Run(ctx context.Context, w io.Writer, cfg *steps.Co
 			}
 
 			if i := findInstanceWithPublicAddr(out.Reservations); i != nil {
+				cfg.Lock()
 				cfg.Node.PublicIp = *i.PublicIpAddress
 				cfg.Node.PrivateIp = *i.PrivateIpAddress
+				cfg.Unlock()
 				log.Infof("[%s] - found public ip - %s for node %s", StepNameCreateEC2Instance, cfg.Node.PublicIp, nodeName)
 				found = true
 				break
 			}
 		}
 		if !found {
+			cfg.Lock()
 			cfg.Node.State = node.StateError
-			cfg.NodeChan() <- cfg.Node
+			cfg.Unlock()
+			cfg.NodeChan() <- cfg.CurrentNode()
 			log.Errorf("[%s] - failed to find public IP address after %d attempts", StepNameCreateEC2Instance,
 				IPAttempts)
 			return errors.New("aws: failed to obtain public IP")
@@ -202,8 +220,10 @@ func (s *StepCreateInstance) Run(ctx context.Context, w io.Writer, cfg *steps.Co
 	if cfg.IsMaster {
 		cfg.AddMaster(&cfg.Node)
 	}
+	cfg.Lock()
 	cfg.Node.State = node.StateProvisioning
-	cfg.NodeChan() <- cfg.Node
+	cfg.Unlock()
+	cfg.NodeChan() <- cfg.CurrentNode()
 
 	log.Infof("[%s] - success! Created node %s with instanceID %s",
 		StepNameCreateEC2Instance, nodeName, cfg.Node.Id)
@@ -212,27 +232,64 @@ func (s *StepCreateInstance) Run(ctx context.Context, w io.Writer, cfg *steps.Co
 	return nil
 }
 
+// Rollback terminates the instance of the node, instance is looked up by its tags
+// when the step has been stopped before the instance id was known.
 func (s *StepCreateInstance) Rollback(ctx context.Context, w io.Writer, cfg *steps.Config) error {
 	log := util.GetLogger(w)
 	log.Infof("[%s] - rollback initiated", s.Name())
 
-	sdk, err := GetSDK(cfg.AWSConfig)
+	// Instance has never been requested
+	if cfg.Node.Id == "" && cfg.TaskId == "" {
+		return nil
+	}
+
+	client, err := s.getEC2(cfg.AWSConfig)
 	if err != nil {
 		return errors.New("aws: authorization")
 	}
 
+	ids := make([]*string, 0, 1)
 	if cfg.Node.Id != "" {
-		_, err := sdk.EC2.TerminateInstancesWithContext(ctx, &ec2.TerminateInstancesInput{
-			InstanceIds: []*string{
-				aws.String(cfg.Node.Id),
+		ids = append(ids, aws.String(cfg.Node.Id))
+	} else {
+		nodeName := util.MakeNodeName(cfg.ClusterName, cfg.TaskId, cfg.IsMaster)
+		out, err := client.DescribeInstancesWithContext(ctx, &ec2.DescribeInstancesInput{
+			Filters: []*ec2.Filter{
+				{
+					Name:   aws.String("tag:Name"),
+					Values: []*string{aws.String(nodeName)},
+				},
+				{
+					Name:   aws.String("tag:KubernetesCluster"),
+					Values: []*string{aws.String(cfg.ClusterName)},
+				},
 			},
 		})
 		if err != nil {
-			return err
+			return errors.Wrapf(err, "aws: find instance %s", nodeName)
+		}
+
+		for _, r := range out.Reservations {
+			for _, i := range r.Instances {
+				ids = append(ids, i.InstanceId)
+			}
 		}
-		log.Infof("[%s] - deleted ec2 instance %s", s.Name(), cfg.Node.Id)
+	}
+
+	if len(ids) == 0 {
 		return nil
 	}
+
+	_, err = client.TerminateInstancesWithContext(ctx, &ec2.TerminateInstancesInput{
+		InstanceIds: ids,
+	})
+	if err != nil && !isErrCode(err, "InvalidInstanceID.NotFound") {
+		return errors.Wrap(err, "aws: terminate instance")
+	}
+
+	for _, id := range ids {
+		log.Infof("[%s] - deleted ec2 instance %s", s.Name(), aws.StringValue(id))
+	}
 	return nil
 }
 
@@ -1,6 +1,50 @@
 package amazon
 
-import "testing"
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+
+	"github.com/supergiant/supergiant/pkg/workflows/steps"
+)
+
+type mockEC2 struct {
+	ec2iface.EC2API
+
+	importedKeys []string
+	deletedKeys  []string
+	deleteErr    error
+	terminated   []string
+	instances    []*ec2.Instance
+}
+
+func (m *mockEC2) ImportKeyPairWithContext(ctx aws.Context, input *ec2.ImportKeyPairInput, opts ...request.Option) (*ec2.ImportKeyPairOutput, error) {
+	m.importedKeys = append(m.importedKeys, aws.StringValue(input.KeyName))
+	return &ec2.ImportKeyPairOutput{}, nil
+}
+
+func (m *mockEC2) DeleteKeyPairWithContext(ctx aws.Context, input *ec2.DeleteKeyPairInput, opts ...request.Option) (*ec2.DeleteKeyPairOutput, error) {
+	m.deletedKeys = append(m.deletedKeys, aws.StringValue(input.KeyName))
+	return &ec2.DeleteKeyPairOutput{}, m.deleteErr
+}
+
+func (m *mockEC2) DescribeInstancesWithContext(ctx aws.Context, input *ec2.DescribeInstancesInput, opts ...request.Option) (*ec2.DescribeInstancesOutput, error) {
+	return &ec2.DescribeInstancesOutput{
+		Reservations: []*ec2.Reservation{{Instances: m.instances}},
+	}, nil
+}
+
+func (m *mockEC2) TerminateInstancesWithContext(ctx aws.Context, input *ec2.TerminateInstancesInput, opts ...request.Option) (*ec2.TerminateInstancesOutput, error) {
+	for _, id := range input.InstanceIds {
+		m.terminated = append(m.terminated, aws.StringValue(id))
+	}
+	return &ec2.TerminateInstancesOutput{}, nil
+}
 
 func TestCreateInstanceStepName(t *testing.T) {
 	s := StepCreateInstance{}
@@ -17,3 +61,57 @@ func TestDepends(t *testing.T) {
 		t.Errorf("Wrong dependency list %v expected %v", s.Depends(), []string{})
 	}
 }
+
+func TestCreateInstanceRollback(t *testing.T) {
+	testCases := []struct {
+		description string
+		nodeID      string
+		taskID      string
+		instances   []*ec2.Instance
+		terminated  []string
+	}{
+		{
+			description: "not requested",
+		},
+		{
+			description: "instance id is known",
+			nodeID:      "i-1",
+			taskID:      "1234",
+			terminated:  []string{"i-1"},
+		},
+		{
+			description: "found by tags",
+			taskID:      "1234",
+			instances:   []*ec2.Instance{{InstanceId: aws.String("i-2")}},
+			terminated:  []string{"i-2"},
+		},
+		{
+			description: "not found by tags",
+			taskID:      "1234",
+		},
+	}
+
+	for _, testCase := range testCases {
+		client := &mockEC2{instances: testCase.instances}
+		step := &StepCreateInstance{
+			getEC2: func(steps.AWSConfig) (ec2iface.EC2API, error) {
+				return client, nil
+			},
+		}
+
+		cfg := &steps.Config{
+			ClusterName: "test",
+			TaskId:      testCase.taskID,
+		}
+		cfg.Node.Id = testCase.nodeID
+
+		if err := step.Rollback(context.Background(), &bytes.Buffer{}, cfg); err != nil {
+			t.Errorf("%s: unexpected error %v", testCase.description, err)
+		}
+
+		if len(client.terminated) != len(testCase.terminated) {
+			t.Errorf("%s: wrong terminated instances expected %v actual %v",
+				testCase.description, testCase.terminated, client.terminated)
+		}
+	}
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
 	"github.com/supergiant/supergiant/pkg/util"
 	"github.com/supergiant/supergiant/pkg/workflows/steps"
 )
@@ -17,10 +18,13 @@ const StepName = "awskeypairstep"
 //KeyPairStep represents creation of keypair in aws
 //since there is hard cap on keypairs per account supergiant will create one per clster
 type KeyPairStep struct {
+	getEC2 func(steps.AWSConfig) (ec2iface.EC2API, error)
 }
 
 func NewKeyPairStep() *KeyPairStep {
-	return &KeyPairStep{}
+	return &KeyPairStep{
+		getEC2: GetEC2,
+	}
 }
 
 //InitCreateKeyPair add the step to the registry
@@ -32,7 +36,7 @@ func (s *KeyPairStep) Run(ctx context.Context, w io.Writer, cfg *steps.Config) e
 	log := util.GetLogger(w)
 	log.Infof("[%s] - started!", s.Name())
 
-	sdk, err := GetSDK(cfg.AWSConfig)
+	client, err := s.getEC2(cfg.AWSConfig)
 	if err != nil {
 		return errors.New("aws: authorization")
 	}
@@ -45,39 +49,61 @@ func (s *KeyPairStep) Run(ctx context.Context, w io.Writer, cfg *steps.Config) e
 		PublicKeyMaterial: []byte(cfg.SshConfig.PublicKey),
 	}
 
-	output, err := sdk.EC2.ImportKeyPairWithContext(ctx, req)
+	// User key pair is shared by the tasks of the cluster, it may have been imported
+	// by another one and is never deleted on rollback
+	client.ImportKeyPairWithContext(ctx, req)
 
 	keyPairName := util.MakeKeyName(cfg.AWSConfig.KeyPairName, false)
 
-	if err != nil {
-		cfg.AWSConfig.KeyPairName = *output.KeyName
-	}
+	cfg.Lock()
+	cfg.AWSConfig.KeyPairName = userKeyPairName
+	cfg.Unlock()
 
 	req = &ec2.ImportKeyPairInput{
 		KeyName:           &keyPairName,
 		PublicKeyMaterial: []byte(cfg.SshConfig.BootstrapPublicKey),
 	}
 
-	output, err = sdk.EC2.ImportKeyPairWithContext(ctx, req)
+	_, err = client.ImportKeyPairWithContext(ctx, req)
 
 	if err != nil {
 		return errors.Wrap(err, "create provision key pair")
 	}
+	cfg.Lock()
+	cfg.AWSConfig.ImportedKeyPairs = append(cfg.AWSConfig.ImportedKeyPairs, keyPairName)
+	cfg.Unlock()
 
 	log.Infof("[%s] - success!", s.Name())
 	return nil
 }
 
+// Rollback deletes the provision key pair imported by the step, the user key pair is
+// shared by all the machines of the cluster and is kept.
 func (s *KeyPairStep) Rollback(ctx context.Context, w io.Writer, cfg *steps.Config) error {
-	sdk, err := GetSDK(cfg.AWSConfig)
+	if len(cfg.AWSConfig.ImportedKeyPairs) == 0 {
+		return nil
+	}
+
+	client, err := s.getEC2(cfg.AWSConfig)
 	if err != nil {
 		return errors.New("aws: authorization")
 	}
 
-	_, err = sdk.EC2.DeleteKeyPairWithContext(ctx, &ec2.DeleteKeyPairInput{
-		KeyName: aws.String(cfg.AWSConfig.KeyPairName),
-	})
-	return err
+	for len(cfg.AWSConfig.ImportedKeyPairs) > 0 {
+		name := cfg.AWSConfig.ImportedKeyPairs[0]
+		_, err = client.DeleteKeyPairWithContext(ctx, &ec2.DeleteKeyPairInput{
+			KeyName: aws.String(name),
+		})
+
+		if err != nil && !isErrCode(err, "InvalidKeyPair.NotFound") {
+			return errors.Wrapf(err, "delete key pair %s", name)
+		}
+		cfg.Lock()
+		cfg.AWSConfig.ImportedKeyPairs = cfg.AWSConfig.ImportedKeyPairs[1:]
+		cfg.Unlock()
+	}
+
+	return nil
 }
 
 func (*KeyPairStep) Name() string {
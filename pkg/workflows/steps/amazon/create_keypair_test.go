@@ -1,6 +1,15 @@
 package amazon
 
-import "testing"
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+
+	"github.com/supergiant/supergiant/pkg/workflows/steps"
+)
 
 func TestCreateKeyPairStepName(t *testing.T) {
 	s := KeyPairStep{}
@@ -17,3 +26,92 @@ func TestCreateKeyPairDepends(t *testing.T) {
 		t.Errorf("Wrong dependency list %v expected %v", s.Depends(), []string{})
 	}
 }
+
+func TestKeyPairRun(t *testing.T) {
+	client := &mockEC2{}
+	step := &KeyPairStep{
+		getEC2: func(steps.AWSConfig) (ec2iface.EC2API, error) {
+			return client, nil
+		},
+	}
+
+	cfg := &steps.Config{
+		AWSConfig: steps.AWSConfig{
+			KeyPairName: "test",
+		},
+	}
+
+	if err := step.Run(context.Background(), &bytes.Buffer{}, cfg); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if len(client.importedKeys) != 2 {
+		t.Errorf("wrong imported keys %v", client.importedKeys)
+	}
+
+	// User key pair is shared by the cluster and is never rolled back
+	if len(cfg.AWSConfig.ImportedKeyPairs) != 1 || cfg.AWSConfig.ImportedKeyPairs[0] != "test-provision" {
+		t.Errorf("wrong key pairs to roll back %v", cfg.AWSConfig.ImportedKeyPairs)
+	}
+
+	if cfg.AWSConfig.KeyPairName != "test-user" {
+		t.Errorf("wrong key pair name %s", cfg.AWSConfig.KeyPairName)
+	}
+}
+
+func TestKeyPairRollback(t *testing.T) {
+	testCases := []struct {
+		description string
+		imported    []string
+		deleteErr   error
+		hasError    bool
+	}{
+		{
+			description: "nothing imported",
+		},
+		{
+			description: "imported",
+			imported:    []string{"provision"},
+		},
+		{
+			description: "deleted already",
+			imported:    []string{"provision"},
+			deleteErr:   awserr.New("InvalidKeyPair.NotFound", "not found", nil),
+		},
+		{
+			description: "error",
+			imported:    []string{"provision"},
+			deleteErr:   awserr.New("UnauthorizedOperation", "denied", nil),
+			hasError:    true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		client := &mockEC2{deleteErr: testCase.deleteErr}
+		step := &KeyPairStep{
+			getEC2: func(steps.AWSConfig) (ec2iface.EC2API, error) {
+				return client, nil
+			},
+		}
+
+		cfg := &steps.Config{
+			AWSConfig: steps.AWSConfig{
+				ImportedKeyPairs: testCase.imported,
+			},
+		}
+		err := step.Rollback(context.Background(), &bytes.Buffer{}, cfg)
+
+		if testCase.hasError != (err != nil) {
+			t.Errorf("%s: unexpected error %v", testCase.description, err)
+		}
+
+		if len(client.deletedKeys) != len(testCase.imported) {
+			t.Errorf("%s: wrong deleted keys expected %v actual %v",
+				testCase.description, testCase.imported, client.deletedKeys)
+		}
+
+		if !testCase.hasError && len(cfg.AWSConfig.ImportedKeyPairs) != 0 {
+			t.Errorf("%s: deleted keys must be forgotten", testCase.description)
+		}
+	}
+}
@@ -9,6 +9,7 @@ import (
 
 	tm "github.com/supergiant/supergiant/pkg/templatemanager"
 	"github.com/supergiant/supergiant/pkg/workflows/steps"
+	"github.com/supergiant/supergiant/pkg/workflows/steps/ssh"
 )
 
 const StepName = "certificates"
@@ -34,11 +35,13 @@ func New(script *template.Template) *Step {
 }
 
 func (s *Step) Run(ctx context.Context, out io.Writer, config *steps.Config) error {
+	config.Lock()
 	if !config.IsMaster {
 		config.CertificatesConfig.MasterPrivateIP = config.GetMaster().PrivateIp
 	} else {
 		config.CertificatesConfig.MasterPrivateIP = config.Node.PrivateIp
 	}
+	config.Unlock()
 
 	err := steps.RunTemplate(ctx, s.script,
 		config.Runner, out, config.CertificatesConfig)
@@ -59,5 +62,5 @@ func (s *Step) Description() string {
 }
 
 func (s *Step) Depends() []string {
-	return nil
+	return []string{ssh.StepName}
 }
@@ -15,6 +15,7 @@ import (
 	"github.com/supergiant/supergiant/pkg/runner"
 	"github.com/supergiant/supergiant/pkg/templatemanager"
 	"github.com/supergiant/supergiant/pkg/workflows/steps"
+	"github.com/supergiant/supergiant/pkg/workflows/steps/ssh"
 )
 
 type fakeRunner struct {
@@ -132,7 +133,7 @@ func TestStepName(t *testing.T) {
 func TestDepends(t *testing.T) {
 	s := Step{}
 
-	if len(s.Depends()) != 0 {
-		t.Errorf("Wrong dependency list %v expected %v", s.Depends(), []string{})
+	if len(s.Depends()) != 1 || s.Depends()[0] != ssh.StepName {
+		t.Errorf("Wrong dependency list %v expected %v", s.Depends(), []string{ssh.StepName})
 	}
 }
@@ -10,6 +10,7 @@ import (
 	tm "github.com/supergiant/supergiant/pkg/templatemanager"
 	"github.com/supergiant/supergiant/pkg/workflows/steps"
 	"github.com/supergiant/supergiant/pkg/workflows/steps/kubelet"
+	"github.com/supergiant/supergiant/pkg/workflows/steps/ssh"
 )
 
 const StepName = "clustercheck"
@@ -53,5 +54,5 @@ func (s *Step) Description() string {
 }
 
 func (s *Step) Depends() []string {
-	return []string{kubelet.StepName}
+	return []string{kubelet.StepName, ssh.StepName}
 }
@@ -9,6 +9,7 @@ import (
 
 	tm "github.com/supergiant/supergiant/pkg/templatemanager"
 	"github.com/supergiant/supergiant/pkg/workflows/steps"
+	"github.com/supergiant/supergiant/pkg/workflows/steps/ssh"
 )
 
 const StepName = "cni"
@@ -52,5 +53,5 @@ func (s *Step) Description() string {
 }
 
 func (s *Step) Depends() []string {
-	return nil
+	return []string{ssh.StepName}
 }
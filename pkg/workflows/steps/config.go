@@ -49,6 +49,8 @@ type AWSConfig struct {
 	AvailabilityZone string    `json:"availabilityZone"`
 
 	KeyPairName string `json:"keyPairName"`
+	// ImportedKeyPairs are provision key pairs created by key pair step, they are deleted on rollback
+	ImportedKeyPairs []string `json:"importedKeyPairs,omitempty"`
 }
 
 type EC2Config struct {
@@ -146,6 +148,48 @@ type SshConfig struct {
 	Timeout             int    `json:"timeout"`
 }
 
+// PreflightConfig holds requirements the machine is checked against before it is provisioned,
+// empty requirements are not checked. Report is written by the preflight step.
+type PreflightConfig struct {
+	OperatingSystem string `json:"operatingSystem"`
+	// Version is the release code name or the version id of the distribution, e.g. xenial or 16.04
+	Version string `json:"version"`
+	Arch    string `json:"arch"`
+	// Enforce fails the step when checks have failed, otherwise they are reported only
+	Enforce bool `json:"enforce"`
+
+	Master   PreflightRequirements `json:"master"`
+	Node     PreflightRequirements `json:"node"`
+	Binaries []string              `json:"binaries"`
+	// Urls must be reachable from the machine
+	Urls []string `json:"urls"`
+
+	Report *PreflightReport `json:"report,omitempty"`
+}
+
+// PreflightRequirements are minimums of the machine of the role and ports that must be free,
+// memory is compared with some slack since the kernel reserves part of it.
+type PreflightRequirements struct {
+	MinCPU      int   `json:"minCpu"`
+	MinMemoryMB int   `json:"minMemoryMb"`
+	MinDiskGB   int   `json:"minDiskGb"`
+	Ports       []int `json:"ports"`
+}
+
+type PreflightReport struct {
+	Passed bool             `json:"passed"`
+	Checks []PreflightCheck `json:"checks"`
+}
+
+type PreflightCheck struct {
+	Name     string `json:"name"`
+	Passed   bool   `json:"passed"`
+	Expected string `json:"expected,omitempty"`
+	Actual   string `json:"actual"`
+	// Hint tells how to fix the machine when the check has failed
+	Hint string `json:"hint,omitempty"`
+}
+
 type ClusterCheckConfig struct {
 	MachineCount int
 }
@@ -185,6 +229,7 @@ type Config struct {
 	TillerConfig       TillerConfig       `json:"tillerConfig"`
 	EtcdConfig         EtcdConfig         `json:"etcdConfig"`
 	SshConfig          SshConfig          `json:"sshConfig"`
+	PreflightConfig    PreflightConfig    `json:"preflightConfig"`
 
 	ClusterCheckConfig ClusterCheckConfig `json:"clusterCheckConfig"`
 
@@ -192,9 +237,17 @@ type Config struct {
 	CloudAccountName string        `json:"cloudAccountName" valid:"required, length(1|32)"`
 	Timeout          time.Duration `json:"timeout"`
 	Runner           runner.Runner `json:"-"`
+	// DryRun renders scripts of the steps without running them, cloud resources are not created
+	DryRun bool `json:"dryRun"`
+	// RollbackOnFailure rolls back all the started steps of the failed task in reverse order,
+	// otherwise only the failed step is rolled back.
+	RollbackOnFailure bool `json:"rollbackOnFailure"`
 
 	repository storage.Interface `json:"-"`
 
+	// m guards fields that steps set, the task is saved while its steps run
+	m sync.RWMutex
+
 	m1      sync.RWMutex
 	Masters Map `json:"masters"`
 
@@ -294,6 +347,32 @@ func NewConfig(clusterName, discoveryUrl, cloudAccountName string, profile profi
 			RestartTimeout: "5",
 			DiscoveryUrl:   discoveryUrl,
 		},
+		PreflightConfig: PreflightConfig{
+			OperatingSystem: profile.OperatingSystem,
+			Version:         profile.UbuntuVersion,
+			Arch:            profile.Arch,
+			Enforce:         profile.Preflight.Enforce,
+			Master: PreflightRequirements{
+				MinCPU:      profile.Preflight.Master.MinCPU,
+				MinMemoryMB: profile.Preflight.Master.MinMemoryMB,
+				MinDiskGB:   profile.Preflight.Master.MinDiskGB,
+				// etcd client and peer ports, insecure and secure API server ports
+				Ports: []int{2379, 2380, 8080, 443},
+			},
+			Node: PreflightRequirements{
+				MinCPU:      profile.Preflight.Node.MinCPU,
+				MinMemoryMB: profile.Preflight.Node.MinMemoryMB,
+				MinDiskGB:   profile.Preflight.Node.MinDiskGB,
+				// kubelet API port
+				Ports: []int{10250},
+			},
+			Binaries: []string{"systemctl", "curl", "tar"},
+			Urls: []string{
+				"https://github.com",
+				"https://storage.googleapis.com",
+				"https://download.docker.com",
+			},
+		},
 		ClusterCheckConfig: ClusterCheckConfig{
 			MachineCount: len(profile.NodesProfiles) + len(profile.MasterProfiles),
 		},
@@ -312,6 +391,57 @@ func NewConfig(clusterName, discoveryUrl, cloudAccountName string, profile profi
 	}
 }
 
+// Detach prepares the config decoded from request to run a single task outside of cluster
+// provisioning, the task can send up to updates node states that nobody reads.
+// Config made by NewConfig is left as is.
+func (c *Config) Detach(updates int) {
+	if c.Masters.internal == nil {
+		c.Masters.internal = make(map[string]*node.Node)
+	}
+	if c.Nodes.internal == nil {
+		c.Nodes.internal = make(map[string]*node.Node)
+	}
+	if c.nodeChan == nil {
+		c.nodeChan = make(chan node.Node, updates)
+	}
+	if c.kubeStateChan == nil {
+		c.kubeStateChan = make(chan model.KubeState, updates)
+	}
+}
+
+// Lock is taken by steps to set fields of the config, independent steps of the task
+// run in parallel and the config is marshalled every time the task is saved.
+func (c *Config) Lock() {
+	c.m.Lock()
+}
+
+func (c *Config) Unlock() {
+	c.m.Unlock()
+}
+
+// CurrentNode returns copy of the node the task provisions
+func (c *Config) CurrentNode() node.Node {
+	c.m.RLock()
+	defer c.m.RUnlock()
+
+	return c.Node
+}
+
+// plainConfig is marshalled the way Config is by default
+type plainConfig Config
+
+// MarshalJSON marshals the config while steps are not setting its fields
+func (c *Config) MarshalJSON() ([]byte, error) {
+	c.m.RLock()
+	defer c.m.RUnlock()
+	c.m1.RLock()
+	defer c.m1.RUnlock()
+	c.m2.RLock()
+	defer c.m2.RUnlock()
+
+	return json.Marshal((*plainConfig)(c))
+}
+
 // AddMaster to map of master, map is used because it is reference and can be shared among
 // goroutines that run multiple tasks of cluster deployment
 func (c *Config) AddMaster(n *node.Node) {
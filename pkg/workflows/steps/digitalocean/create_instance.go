@@ -21,19 +21,26 @@ import (
 type CreateInstanceStep struct {
 	DropletTimeout time.Duration
 	CheckPeriod    time.Duration
+
+	getDeleteService func(string) DeleteService
 }
 
 func NewCreateInstanceStep(dropletTimeout, checkPeriod time.Duration) *CreateInstanceStep {
 	return &CreateInstanceStep{
 		DropletTimeout: dropletTimeout,
 		CheckPeriod:    checkPeriod,
+		getDeleteService: func(accessToken string) DeleteService {
+			return digitaloceanSDK.New(accessToken).GetClient().Droplets
+		},
 	}
 }
 
 func (s *CreateInstanceStep) Run(ctx context.Context, output io.Writer, config *steps.Config) error {
 	// TODO(stgleb): Extract getting digital ocean sdk to function that will allow it to be mocked.
 	c := digitaloceanSDK.New(config.DigitalOceanConfig.AccessToken).GetClient()
+	config.Lock()
 	config.DigitalOceanConfig.Name = util.MakeNodeName(config.ClusterName, config.TaskId, config.IsMaster)
+	config.Unlock()
 
 	// TODO(stgleb): Move keys creation for provisioning to provisioner to be able to get
 	// this key on cluster check phase.
@@ -68,6 +75,7 @@ func (s *CreateInstanceStep) Run(ctx context.Context, output io.Writer, config *
 		role = node.RoleNode
 	}
 
+	config.Lock()
 	config.Node = node.Node{
 		Role:     role,
 		Provider: clouds.DigitalOcean,
@@ -76,14 +84,17 @@ func (s *CreateInstanceStep) Run(ctx context.Context, output io.Writer, config *
 		State:    node.StateBuilding,
 		Name:     config.DigitalOceanConfig.Name,
 	}
+	config.Unlock()
 
 	// Update node state in cluster
-	config.NodeChan() <- config.Node
+	config.NodeChan() <- config.CurrentNode()
 	droplet, _, err := c.Droplets.Create(ctx, dropletRequest)
 
 	if err != nil {
+		config.Lock()
 		config.Node.State = node.StateError
-		config.NodeChan() <- config.Node
+		config.Unlock()
+		config.NodeChan() <- config.CurrentNode()
 		return errors.Wrap(err, "dropletService has returned an error in Run job")
 	}
 
@@ -104,14 +115,16 @@ func (s *CreateInstanceStep) Run(ctx context.Context, output io.Writer, config *
 
 				createdAt, _ := strconv.Atoi(droplet.Created)
 
+				config.Lock()
 				config.Node.Id = fmt.Sprintf("%d", droplet.ID)
 				config.Node.CreatedAt = int64(createdAt)
 				config.Node.PublicIp = getPublicIpPort(droplet.Networks.V4)
 				config.Node.PrivateIp = getPrivateIpPort(droplet.Networks.V4)
 				config.Node.State = node.StateProvisioning
+				config.Unlock()
 
 				// Update node state in cluster
-				config.NodeChan() <- config.Node
+				config.NodeChan() <- config.CurrentNode()
 
 				if config.IsMaster {
 					config.AddMaster(&config.Node)
@@ -131,7 +144,19 @@ func (s *CreateInstanceStep) Run(ctx context.Context, output io.Writer, config *
 	return nil
 }
 
-func (s *CreateInstanceStep) Rollback(context.Context, io.Writer, *steps.Config) error {
+// Rollback deletes the droplet of the node, the user key is shared by
+// all the droplets of the cluster and is kept like the provision key.
+func (s *CreateInstanceStep) Rollback(ctx context.Context, output io.Writer, config *steps.Config) error {
+	// Droplet is tagged with its name
+	if name := config.DigitalOceanConfig.Name; name != "" {
+		deleteService := s.getDeleteService(config.DigitalOceanConfig.AccessToken)
+		resp, err := deleteService.DeleteByTag(ctx, name)
+
+		if err != nil && !isNotFound(resp) {
+			return errors.Wrapf(err, "delete droplet %s", name)
+		}
+	}
+
 	return nil
 }
 
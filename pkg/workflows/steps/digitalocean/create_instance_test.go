@@ -0,0 +1,83 @@
+package digitalocean
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/digitalocean/godo"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/supergiant/supergiant/pkg/workflows/steps"
+)
+
+func TestCreateInstanceRollback(t *testing.T) {
+	notFound := &godo.Response{
+		Response: &http.Response{
+			StatusCode: http.StatusNotFound,
+		},
+	}
+
+	testCases := []struct {
+		description    string
+		dropletName    string
+		deleteResp     *godo.Response
+		deleteErr      error
+		expectedDelete bool
+		hasError       bool
+	}{
+		{
+			description: "nothing has been created",
+		},
+		{
+			description:    "droplet",
+			dropletName:    "test-master-1234",
+			expectedDelete: true,
+		},
+		{
+			description:    "droplet has been deleted already",
+			dropletName:    "test-master-1234",
+			deleteResp:     notFound,
+			deleteErr:      errors.New("not found"),
+			expectedDelete: true,
+		},
+		{
+			description:    "delete droplet error",
+			dropletName:    "test-master-1234",
+			deleteErr:      errors.New("error"),
+			expectedDelete: true,
+			hasError:       true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		deleteSvc := new(mockDeleteService)
+		deleteSvc.On("DeleteByTag", mock.Anything, testCase.dropletName).
+			Return(testCase.deleteResp, testCase.deleteErr)
+
+		step := NewCreateInstanceStep(time.Second, time.Second)
+		step.getDeleteService = func(string) DeleteService {
+			return deleteSvc
+		}
+
+		config := &steps.Config{
+			DigitalOceanConfig: steps.DOConfig{
+				Name: testCase.dropletName,
+			},
+		}
+		err := step.Rollback(context.Background(), &bytes.Buffer{}, config)
+
+		if testCase.hasError != (err != nil) {
+			t.Errorf("%s: unexpected error %v", testCase.description, err)
+		}
+
+		if testCase.expectedDelete {
+			deleteSvc.AssertCalled(t, "DeleteByTag", mock.Anything, testCase.dropletName)
+		} else {
+			deleteSvc.AssertNotCalled(t, "DeleteByTag", mock.Anything, mock.Anything)
+		}
+	}
+}
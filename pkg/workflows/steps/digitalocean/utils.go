@@ -57,6 +57,10 @@ func fingerprint(key string) (string, error) {
 	return buffer.String(), nil
 }
 
+func isNotFound(resp *godo.Response) bool {
+	return resp != nil && resp.Response != nil && resp.StatusCode == http.StatusNotFound
+}
+
 func createKey(ctx context.Context, keyService KeyService, name, publicKey string) (*godo.Key, error) {
 	req := &godo.KeyCreateRequest{
 		Name:      name,
@@ -9,6 +9,7 @@ import (
 
 	tm "github.com/supergiant/supergiant/pkg/templatemanager"
 	"github.com/supergiant/supergiant/pkg/workflows/steps"
+	"github.com/supergiant/supergiant/pkg/workflows/steps/ssh"
 )
 
 const StepName = "docker"
@@ -28,7 +29,7 @@ func New(tpl *template.Template) *Step {
 }
 
 func (t *Step) Run(ctx context.Context, out io.Writer, config *steps.Config) error {
-	err := steps.RunTemplate(context.Background(), t.scriptTemplate,
+	err := steps.RunTemplate(ctx, t.scriptTemplate,
 		config.Runner, out, config.DockerConfig)
 	if err != nil {
 		return errors.Wrap(err, "install docker step")
@@ -50,5 +51,5 @@ func (t *Step) Description() string {
 }
 
 func (s *Step) Depends() []string {
-	return nil
+	return []string{ssh.StepName}
 }
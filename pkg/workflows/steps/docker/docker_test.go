@@ -13,6 +13,7 @@ import (
 	"github.com/supergiant/supergiant/pkg/templatemanager"
 	"github.com/supergiant/supergiant/pkg/testutils"
 	"github.com/supergiant/supergiant/pkg/workflows/steps"
+	"github.com/supergiant/supergiant/pkg/workflows/steps/ssh"
 
 	"github.com/supergiant/supergiant/pkg/runner"
 )
@@ -112,7 +113,7 @@ func TestStepName(t *testing.T) {
 func TestDepends(t *testing.T) {
 	s := Step{}
 
-	if len(s.Depends()) != 0 {
-		t.Errorf("Wrong dependency list %v expected %v", s.Depends(), []string{})
+	if len(s.Depends()) != 1 || s.Depends()[0] != ssh.StepName {
+		t.Errorf("Wrong dependency list %v expected %v", s.Depends(), []string{ssh.StepName})
 	}
 }
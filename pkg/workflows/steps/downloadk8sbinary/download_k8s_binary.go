@@ -9,6 +9,7 @@ import (
 
 	tm "github.com/supergiant/supergiant/pkg/templatemanager"
 	"github.com/supergiant/supergiant/pkg/workflows/steps"
+	"github.com/supergiant/supergiant/pkg/workflows/steps/ssh"
 )
 
 const StepName = "download_kubernetes_binary"
@@ -28,7 +29,7 @@ func New(tpl *template.Template) *Step {
 }
 
 func (s *Step) Run(ctx context.Context, out io.Writer, config *steps.Config) error {
-	err := steps.RunTemplate(context.Background(), s.scriptTemplate,
+	err := steps.RunTemplate(ctx, s.scriptTemplate,
 		config.Runner, out, config.DownloadK8sBinary)
 	if err != nil {
 		return errors.Wrap(err, "download k8s binary step")
@@ -50,5 +51,5 @@ func (s *Step) Description() string {
 }
 
 func (s *Step) Depends() []string {
-	return nil
+	return []string{ssh.StepName}
 }
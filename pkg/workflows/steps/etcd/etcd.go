@@ -9,6 +9,7 @@ import (
 
 	tm "github.com/supergiant/supergiant/pkg/templatemanager"
 	"github.com/supergiant/supergiant/pkg/workflows/steps"
+	"github.com/supergiant/supergiant/pkg/workflows/steps/certificates"
 	"github.com/supergiant/supergiant/pkg/workflows/steps/docker"
 )
 
@@ -29,8 +30,10 @@ func New(tpl *template.Template) *Step {
 }
 
 func (s *Step) Run(ctx context.Context, out io.Writer, config *steps.Config) error {
+	config.Lock()
 	config.EtcdConfig.Name = config.Node.Id
 	config.EtcdConfig.AdvertiseHost = config.Node.PrivateIp
+	config.Unlock()
 	ctx2, _ := context.WithTimeout(ctx, config.EtcdConfig.Timeout)
 
 	err := steps.RunTemplate(ctx2, s.scriptTemplate,
@@ -55,5 +58,5 @@ func (s *Step) Description() string {
 }
 
 func (s *Step) Depends() []string {
-	return []string{docker.StepName}
+	return []string{docker.StepName, certificates.StepName}
 }
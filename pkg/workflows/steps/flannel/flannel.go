@@ -9,6 +9,7 @@ import (
 
 	tm "github.com/supergiant/supergiant/pkg/templatemanager"
 	"github.com/supergiant/supergiant/pkg/workflows/steps"
+	"github.com/supergiant/supergiant/pkg/workflows/steps/docker"
 	"github.com/supergiant/supergiant/pkg/workflows/steps/etcd"
 	"github.com/supergiant/supergiant/pkg/workflows/steps/network"
 )
@@ -30,7 +31,7 @@ func New(tpl *template.Template) *Step {
 }
 
 func (t *Step) Run(ctx context.Context, out io.Writer, config *steps.Config) error {
-	err := steps.RunTemplate(context.Background(), t.scriptTemplate,
+	err := steps.RunTemplate(ctx, t.scriptTemplate,
 		config.Runner, out, config.FlannelConfig)
 	if err != nil {
 		return errors.Wrap(err, "install flannel step")
@@ -52,5 +53,5 @@ func (t *Step) Description() string {
 }
 
 func (t *Step) Depends() []string {
-	return []string{etcd.StepName, network.StepName}
+	return []string{etcd.StepName, network.StepName, docker.StepName}
 }
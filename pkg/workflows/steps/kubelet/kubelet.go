@@ -10,6 +10,8 @@ import (
 	tm "github.com/supergiant/supergiant/pkg/templatemanager"
 	"github.com/supergiant/supergiant/pkg/workflows/steps"
 	"github.com/supergiant/supergiant/pkg/workflows/steps/docker"
+	"github.com/supergiant/supergiant/pkg/workflows/steps/downloadk8sbinary"
+	"github.com/supergiant/supergiant/pkg/workflows/steps/flannel"
 	"github.com/supergiant/supergiant/pkg/workflows/steps/manifest"
 )
 
@@ -32,7 +34,10 @@ func New(script *template.Template) *Step {
 }
 
 func (t *Step) Run(ctx context.Context, out io.Writer, config *steps.Config) error {
+	config.Lock()
 	config.KubeletConfig.MasterPrivateIP = "0.0.0.0"
+	config.Unlock()
+
 	err := steps.RunTemplate(ctx, t.script, config.Runner, out, config.KubeletConfig)
 
 	if err != nil {
@@ -55,5 +60,5 @@ func (t *Step) Description() string {
 }
 
 func (s *Step) Depends() []string {
-	return []string{docker.StepName, manifest.StepName}
+	return []string{docker.StepName, manifest.StepName, flannel.StepName, downloadk8sbinary.StepName}
 }
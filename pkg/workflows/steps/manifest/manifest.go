@@ -10,6 +10,7 @@ import (
 
 	tm "github.com/supergiant/supergiant/pkg/templatemanager"
 	"github.com/supergiant/supergiant/pkg/workflows/steps"
+	"github.com/supergiant/supergiant/pkg/workflows/steps/certificates"
 )
 
 const StepName = "manifest"
@@ -32,8 +33,10 @@ func New(script *template.Template) *Step {
 
 func (j *Step) Run(ctx context.Context, out io.Writer, config *steps.Config) error {
 	// NOTE(stgleb): This is needed for master node to put advertise address for kube api server.
+	config.Lock()
 	config.ManifestConfig.IsMaster = config.IsMaster
 	config.ManifestConfig.MasterHost = config.GetMaster().PrivateIp
+	config.Unlock()
 
 	err := steps.RunTemplate(ctx, j.script, config.Runner, out, config.ManifestConfig)
 
@@ -57,5 +60,5 @@ func (t *Step) Description() string {
 }
 
 func (s *Step) Depends() []string {
-	return nil
+	return []string{certificates.StepName}
 }
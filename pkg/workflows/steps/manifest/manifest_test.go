@@ -16,6 +16,7 @@ import (
 	"github.com/supergiant/supergiant/pkg/runner"
 	"github.com/supergiant/supergiant/pkg/templatemanager"
 	"github.com/supergiant/supergiant/pkg/workflows/steps"
+	"github.com/supergiant/supergiant/pkg/workflows/steps/certificates"
 )
 
 type fakeRunner struct {
@@ -217,7 +218,7 @@ func TestStepName(t *testing.T) {
 func TestDepends(t *testing.T) {
 	s := Step{}
 
-	if len(s.Depends()) != 0 {
-		t.Errorf("Wrong dependency list %v expected %v", s.Depends(), []string{})
+	if len(s.Depends()) != 1 || s.Depends()[0] != certificates.StepName {
+		t.Errorf("Wrong dependency list %v expected %v", s.Depends(), []string{certificates.StepName})
 	}
 }
@@ -0,0 +1,90 @@
+package steps
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/supergiant/supergiant/pkg/runner"
+)
+
+const (
+	// MarkerDir keeps completion markers of the scripts on the machine
+	MarkerDir = "/var/lib/supergiant/steps"
+	// markerVersion changes when the way scripts are hashed changes, older markers never match
+	markerVersion = "v1"
+)
+
+type markersKey struct{}
+
+// WithMarkers makes scripts run with the context leave completion markers on the machine, the script
+// is skipped if the marker of the same script is found. Force runs scripts regardless of their markers.
+func WithMarkers(ctx context.Context, force bool) context.Context {
+	return context.WithValue(ctx, markersKey{}, &force)
+}
+
+// WithoutMarkers makes scripts run every time, e.g. for scripts that gather facts about the machine
+func WithoutMarkers(ctx context.Context) context.Context {
+	return context.WithValue(ctx, markersKey{}, (*bool)(nil))
+}
+
+// markers tells whether markers are used and whether they are ignored
+func markers(ctx context.Context) (force bool, enabled bool) {
+	f, _ := ctx.Value(markersKey{}).(*bool)
+	if f == nil {
+		return false, false
+	}
+
+	return *f, true
+}
+
+// marker is the content of the marker file of the script, the same script renders the same marker
+func marker(script string) string {
+	sum := sha256.Sum256([]byte(script))
+	return markerVersion + " " + hex.EncodeToString(sum[:])
+}
+
+func markerPath(name string) string {
+	return path.Join(MarkerDir, name)
+}
+
+func skippedMessage(name string) string {
+	return fmt.Sprintf("%s has been completed on the machine already, skipped\n", name)
+}
+
+// completed tells whether the marker of the script is found on the machine
+func completed(ctx context.Context, r runner.Runner, name, script string) (bool, error) {
+	out := &bytes.Buffer{}
+	cmd, err := runner.NewCommand(ctx, fmt.Sprintf("cat %s 2>/dev/null || true", markerPath(name)), out, ioutil.Discard)
+	if err != nil {
+		return false, err
+	}
+
+	if err := r.Run(cmd); err != nil {
+		return false, errors.Wrapf(err, "read marker of %s", name)
+	}
+
+	return strings.TrimSpace(out.String()) == marker(script), nil
+}
+
+// markCompleted leaves the marker of the script on the machine
+func markCompleted(ctx context.Context, r runner.Runner, name, script string) error {
+	cmd, err := runner.NewCommand(ctx, fmt.Sprintf("mkdir -p %s && echo '%s' > %s",
+		MarkerDir, marker(script), markerPath(name)), ioutil.Discard, ioutil.Discard)
+	if err != nil {
+		return err
+	}
+
+	if err := r.Run(cmd); err != nil {
+		return errors.Wrapf(err, "write marker of %s", name)
+	}
+
+	return nil
+}
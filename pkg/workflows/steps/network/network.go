@@ -29,7 +29,7 @@ func New(tpl *template.Template) *Step {
 }
 
 func (t *Step) Run(ctx context.Context, out io.Writer, config *steps.Config) error {
-	err := steps.RunTemplate(context.Background(), t.scriptTemplate,
+	err := steps.RunTemplate(ctx, t.scriptTemplate,
 		config.Runner, out, config.NetworkConfig)
 	if err != nil {
 		return errors.Wrap(err, "configure network step")
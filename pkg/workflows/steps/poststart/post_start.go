@@ -10,6 +10,7 @@ import (
 	"github.com/supergiant/supergiant/pkg/node"
 	tm "github.com/supergiant/supergiant/pkg/templatemanager"
 	"github.com/supergiant/supergiant/pkg/workflows/steps"
+	"github.com/supergiant/supergiant/pkg/workflows/steps/cni"
 	"github.com/supergiant/supergiant/pkg/workflows/steps/kubelet"
 	"time"
 )
@@ -34,7 +35,9 @@ func New(script *template.Template) *Step {
 
 func (s *Step) Run(ctx context.Context, out io.Writer, config *steps.Config) error {
 	ctx2, _ := context.WithTimeout(ctx, time.Duration(config.PostStartConfig.Timeout)*time.Second)
+	config.Lock()
 	config.PostStartConfig.IsMaster = config.IsMaster
+	config.Unlock()
 
 	err := steps.RunTemplate(ctx2, s.script, config.Runner, out, config.PostStartConfig)
 
@@ -43,9 +46,14 @@ func (s *Step) Run(ctx context.Context, out io.Writer, config *steps.Config) err
 	}
 
 	// Mark current node as active to allow cluster check task select it for cluster wide task
+	config.Lock()
 	config.Node.State = node.StateActive
-	config.NodeChan() <- config.Node
+	config.Unlock()
+	config.NodeChan() <- config.CurrentNode()
+
+	config.Lock()
 	config.SshConfig.BootstrapPrivateKey = ""
+	config.Unlock()
 
 	return nil
 }
@@ -63,5 +71,5 @@ func (s *Step) Description() string {
 }
 
 func (s *Step) Depends() []string {
-	return []string{kubelet.StepName}
+	return []string{kubelet.StepName, cni.StepName}
 }
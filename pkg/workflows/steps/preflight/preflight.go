@@ -0,0 +1,247 @@
+package preflight
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+
+	tm "github.com/supergiant/supergiant/pkg/templatemanager"
+	"github.com/supergiant/supergiant/pkg/workflows/steps"
+	"github.com/supergiant/supergiant/pkg/workflows/steps/ssh"
+)
+
+const StepName = "preflight"
+
+// memorySlackPercent of the required memory may be missing, MemTotal of the
+// machine is below its nominal memory since the kernel reserves part of it.
+const memorySlackPercent = 10
+
+// archNames maps machine names reported by uname to architectures of the profile
+var archNames = map[string]string{
+	"x86_64":  "amd64",
+	"aarch64": "arm64",
+	"armv7l":  "arm",
+	"i686":    "386",
+}
+
+type Step struct {
+	scriptTemplate *template.Template
+}
+
+func Init() {
+	steps.RegisterStep(StepName, New(tm.GetTemplate(StepName)))
+}
+
+func New(tpl *template.Template) *Step {
+	return &Step{
+		scriptTemplate: tpl,
+	}
+}
+
+// templateData lists what the script has to find out for the machine of the role
+type templateData struct {
+	Binaries []string
+	Ports    []int
+	Urls     []string
+}
+
+// Run collects facts about the machine and checks them against requirements of its role,
+// the report is saved in the config of the task. Step fails if any check has failed
+// and the requirements are enforced.
+func (s *Step) Run(ctx context.Context, out io.Writer, config *steps.Config) error {
+	cfg := config.PreflightConfig
+	requirements := cfg.Node
+	if config.IsMaster {
+		requirements = cfg.Master
+	}
+
+	// Facts are gathered every time
+	facts := &bytes.Buffer{}
+	err := steps.RunTemplate(steps.WithoutMarkers(ctx), s.scriptTemplate, config.Runner, facts, templateData{
+		Binaries: cfg.Binaries,
+		Ports:    requirements.Ports,
+		Urls:     cfg.Urls,
+	})
+	if err != nil {
+		return errors.Wrap(err, "preflight step")
+	}
+
+	// Script is recorded instead of being run
+	if config.DryRun {
+		_, err := io.Copy(out, facts)
+		return err
+	}
+
+	report := Check(parseFacts(facts), cfg, requirements)
+	config.Lock()
+	config.PreflightConfig.Report = report
+	config.Unlock()
+
+	failed := make([]string, 0)
+	for _, check := range report.Checks {
+		status := "ok"
+		if !check.Passed {
+			status = "FAILED"
+			failed = append(failed, fmt.Sprintf("%s: %s", check.Name, check.Hint))
+		}
+		fmt.Fprintf(out, "[%s] %s: %s\n", status, check.Name, check.Actual)
+	}
+
+	if len(failed) > 0 && cfg.Enforce {
+		return errors.Errorf("preflight checks failed: %s", strings.Join(failed, "; "))
+	}
+
+	if len(failed) > 0 {
+		fmt.Fprintf(out, "WARNING: preflight checks failed, provisioning goes on: %s\n", strings.Join(failed, "; "))
+	}
+
+	return nil
+}
+
+// parseFacts reads key=value lines printed by the script
+func parseFacts(r io.Reader) map[string]string {
+	facts := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		parts := strings.SplitN(strings.TrimSpace(scanner.Text()), "=", 2)
+		if len(parts) == 2 {
+			facts[parts[0]] = parts[1]
+		}
+	}
+
+	return facts
+}
+
+// Check builds the report of the machine facts, requirements that are not set are not checked
+func Check(facts map[string]string, cfg steps.PreflightConfig, requirements steps.PreflightRequirements) *steps.PreflightReport {
+	report := &steps.PreflightReport{Passed: true}
+	add := func(check steps.PreflightCheck) {
+		if check.Passed {
+			check.Hint = ""
+		} else {
+			report.Passed = false
+		}
+		report.Checks = append(report.Checks, check)
+	}
+
+	if cfg.OperatingSystem != "" {
+		add(steps.PreflightCheck{
+			Name:     "operating system",
+			Passed:   strings.EqualFold(facts["os"], cfg.OperatingSystem),
+			Expected: cfg.OperatingSystem,
+			Actual:   facts["os"],
+			Hint:     fmt.Sprintf("use image with %s operating system", cfg.OperatingSystem),
+		})
+	}
+
+	if cfg.Version != "" {
+		actual := strings.TrimSpace(facts["distribution"] + " " + facts["versionId"] + " " + facts["versionCodename"])
+		add(steps.PreflightCheck{
+			Name:     "distribution version",
+			Passed:   cfg.Version == facts["versionCodename"] || cfg.Version == facts["versionId"],
+			Expected: cfg.Version,
+			Actual:   actual,
+			Hint:     fmt.Sprintf("use image of %s release of the distribution", cfg.Version),
+		})
+	}
+
+	if cfg.Arch != "" {
+		arch := facts["arch"]
+		if name, ok := archNames[arch]; ok {
+			arch = name
+		}
+
+		add(steps.PreflightCheck{
+			Name:     "architecture",
+			Passed:   arch == cfg.Arch,
+			Expected: cfg.Arch,
+			Actual:   arch,
+			Hint:     fmt.Sprintf("use %s machine or change architecture of the profile", cfg.Arch),
+		})
+	}
+
+	minimums := []struct {
+		name  string
+		fact  string
+		unit  string
+		value int
+		slack int
+	}{
+		{"cpu", "cpus", "", requirements.MinCPU, 0},
+		{"memory", "memoryMb", "MB", requirements.MinMemoryMB, requirements.MinMemoryMB * memorySlackPercent / 100},
+		{"free disk", "diskGb", "GB", requirements.MinDiskGB, 0},
+	}
+
+	for _, min := range minimums {
+		if min.value <= 0 {
+			continue
+		}
+
+		actual, err := strconv.Atoi(facts[min.fact])
+		add(steps.PreflightCheck{
+			Name:     min.name,
+			Passed:   err == nil && actual >= min.value-min.slack,
+			Expected: fmt.Sprintf(">= %d%s", min.value, min.unit),
+			Actual:   facts[min.fact] + min.unit,
+			Hint:     fmt.Sprintf("use machine with at least %d%s of %s", min.value, min.unit, min.name),
+		})
+	}
+
+	for _, binary := range cfg.Binaries {
+		path := facts["binary."+binary]
+		add(steps.PreflightCheck{
+			Name:     "binary " + binary,
+			Passed:   path != "",
+			Expected: "installed",
+			Actual:   path,
+			Hint:     fmt.Sprintf("install %s to the image", binary),
+		})
+	}
+
+	for _, port := range requirements.Ports {
+		state := facts[fmt.Sprintf("port.%d", port)]
+		add(steps.PreflightCheck{
+			Name:     fmt.Sprintf("port %d", port),
+			Passed:   state == "free",
+			Expected: "free",
+			Actual:   state,
+			Hint:     fmt.Sprintf("stop the service that listens on port %d", port),
+		})
+	}
+
+	for _, url := range cfg.Urls {
+		code, err := strconv.Atoi(facts["url."+url])
+		add(steps.PreflightCheck{
+			Name:     "connectivity to " + url,
+			Passed:   err == nil && code > 0 && code < 500,
+			Expected: "reachable",
+			Actual:   "http status " + facts["url."+url],
+			Hint:     fmt.Sprintf("allow outbound connections to %s", url),
+		})
+	}
+
+	return report
+}
+
+func (s *Step) Rollback(context.Context, io.Writer, *steps.Config) error {
+	return nil
+}
+
+func (s *Step) Name() string {
+	return StepName
+}
+
+func (s *Step) Description() string {
+	return "Check that the machine meets requirements of its role"
+}
+
+func (s *Step) Depends() []string {
+	return []string{ssh.StepName}
+}
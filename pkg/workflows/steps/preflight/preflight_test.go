@@ -0,0 +1,220 @@
+package preflight
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/supergiant/supergiant/pkg/runner"
+	"github.com/supergiant/supergiant/pkg/templatemanager"
+	"github.com/supergiant/supergiant/pkg/testutils"
+	"github.com/supergiant/supergiant/pkg/workflows/steps"
+	"github.com/supergiant/supergiant/pkg/workflows/steps/ssh"
+)
+
+const goodFacts = `os=linux
+distribution=ubuntu
+versionId=16.04
+versionCodename=xenial
+arch=x86_64
+cpus=2
+memoryMb=4096
+diskGb=50
+binary.systemctl=/bin/systemctl
+binary.curl=/usr/bin/curl
+port.2379=free
+port.8080=free
+url.https://github.com=200
+`
+
+// factsRunner prints facts of the machine instead of running the script
+type factsRunner struct {
+	facts string
+}
+
+func (f *factsRunner) Run(command *runner.Command) error {
+	_, err := io.Copy(command.Out, strings.NewReader(f.facts))
+	return err
+}
+
+func preflightConfig() steps.PreflightConfig {
+	return steps.PreflightConfig{
+		OperatingSystem: "linux",
+		Version:         "xenial",
+		Arch:            "amd64",
+		Enforce:         true,
+		Master: steps.PreflightRequirements{
+			MinCPU:      2,
+			MinMemoryMB: 2048,
+			MinDiskGB:   20,
+			Ports:       []int{2379, 8080},
+		},
+		Node: steps.PreflightRequirements{
+			MinCPU: 1,
+		},
+		Binaries: []string{"systemctl", "curl"},
+		Urls:     []string{"https://github.com"},
+	}
+}
+
+func TestTemplate(t *testing.T) {
+	if err := templatemanager.Init("../../../../templates"); err != nil {
+		t.Fatal(err)
+	}
+
+	tpl := templatemanager.GetTemplate(StepName)
+	if tpl == nil {
+		t.Fatal("template not found")
+	}
+
+	output := &bytes.Buffer{}
+	config := &steps.Config{
+		IsMaster:        true,
+		PreflightConfig: preflightConfig(),
+		Runner:          &testutils.MockRunner{},
+		DryRun:          true,
+	}
+
+	if err := New(tpl).Run(context.Background(), output, config); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	for _, expected := range []string{"binary.systemctl=", "port.2379=", "port.8080=", "url.https://github.com="} {
+		if !strings.Contains(output.String(), expected) {
+			t.Errorf("%s not found in script %s", expected, output.String())
+		}
+	}
+
+	if config.PreflightConfig.Report != nil {
+		t.Errorf("report must not be made in dry run")
+	}
+}
+
+func TestRun(t *testing.T) {
+	if err := templatemanager.Init("../../../../templates"); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name   string
+		facts  string
+		failed []string
+	}{
+		{
+			name:  "passed",
+			facts: goodFacts,
+		},
+		{
+			name: "failed",
+			facts: strings.NewReplacer("cpus=2", "cpus=1", "port.2379=free", "port.2379=busy",
+				"binary.curl=/usr/bin/curl", "binary.curl=").Replace(goodFacts),
+			failed: []string{"cpu", "binary curl", "port 2379"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		output := &bytes.Buffer{}
+		config := &steps.Config{
+			IsMaster:        true,
+			PreflightConfig: preflightConfig(),
+			Runner:          &factsRunner{testCase.facts},
+		}
+
+		err := New(templatemanager.GetTemplate(StepName)).Run(context.Background(), output, config)
+		if (err != nil) != (len(testCase.failed) > 0) {
+			t.Errorf("%s: unexpected error %v", testCase.name, err)
+		}
+
+		report := config.PreflightConfig.Report
+		if report == nil {
+			t.Errorf("%s: report has not been saved", testCase.name)
+			continue
+		}
+
+		if report.Passed != (len(testCase.failed) == 0) {
+			t.Errorf("%s: wrong report status %v", testCase.name, report.Passed)
+		}
+
+		failed := make([]string, 0)
+		for _, check := range report.Checks {
+			if !check.Passed {
+				failed = append(failed, check.Name)
+
+				if !strings.Contains(err.Error(), check.Hint) {
+					t.Errorf("%s: hint %s not found in error %v", testCase.name, check.Hint, err)
+				}
+			}
+		}
+
+		if strings.Join(failed, ",") != strings.Join(testCase.failed, ",") {
+			t.Errorf("%s: wrong failed checks %v expected %v", testCase.name, failed, testCase.failed)
+		}
+	}
+}
+
+func TestRunNotEnforced(t *testing.T) {
+	if err := templatemanager.Init("../../../../templates"); err != nil {
+		t.Fatal(err)
+	}
+
+	output := &bytes.Buffer{}
+	config := &steps.Config{
+		IsMaster:        true,
+		PreflightConfig: preflightConfig(),
+		Runner:          &factsRunner{strings.Replace(goodFacts, "cpus=2", "cpus=1", 1)},
+	}
+	config.PreflightConfig.Enforce = false
+
+	if err := New(templatemanager.GetTemplate(StepName)).Run(context.Background(), output, config); err != nil {
+		t.Fatalf("failed checks must be reported only, error %v", err)
+	}
+
+	if config.PreflightConfig.Report == nil || config.PreflightConfig.Report.Passed {
+		t.Errorf("failed report must be saved %v", config.PreflightConfig.Report)
+	}
+
+	if !strings.Contains(output.String(), "WARNING") {
+		t.Errorf("warning not found in output %s", output.String())
+	}
+}
+
+func TestCheckMemorySlack(t *testing.T) {
+	requirements := steps.PreflightRequirements{MinMemoryMB: 2048}
+
+	// MemTotal of the machine with 2GB of memory
+	if report := Check(map[string]string{"memoryMb": "1993"}, steps.PreflightConfig{}, requirements); !report.Passed {
+		t.Errorf("memory reserved by the kernel must be tolerated %v", report.Checks)
+	}
+
+	if report := Check(map[string]string{"memoryMb": "1024"}, steps.PreflightConfig{}, requirements); report.Passed {
+		t.Errorf("half of the memory must not be enough")
+	}
+}
+
+func TestCheckRole(t *testing.T) {
+	cfg := preflightConfig()
+	facts := parseFacts(strings.NewReader(strings.Replace(goodFacts, "cpus=2", "cpus=1", 1)))
+
+	if report := Check(facts, cfg, cfg.Node); !report.Passed {
+		t.Errorf("node requirements must be met %v", report.Checks)
+	}
+
+	if report := Check(facts, cfg, cfg.Master); report.Passed {
+		t.Errorf("master requirements must not be met")
+	}
+
+	// Requirements that are not set are not checked
+	if report := Check(map[string]string{}, steps.PreflightConfig{}, steps.PreflightRequirements{}); !report.Passed || len(report.Checks) != 0 {
+		t.Errorf("unexpected checks %v", report.Checks)
+	}
+}
+
+func TestDepends(t *testing.T) {
+	s := &Step{}
+
+	if len(s.Depends()) != 1 || s.Depends()[0] != ssh.StepName {
+		t.Errorf("Wrong dependency list %v expected %v", s.Depends(), []string{ssh.StepName})
+	}
+}
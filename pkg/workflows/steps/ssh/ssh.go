@@ -8,6 +8,8 @@ import (
 
 	"github.com/supergiant/supergiant/pkg/runner/ssh"
 	"github.com/supergiant/supergiant/pkg/workflows/steps"
+	"github.com/supergiant/supergiant/pkg/workflows/steps/amazon"
+	"github.com/supergiant/supergiant/pkg/workflows/steps/digitalocean"
 )
 
 const StepName = "ssh"
@@ -50,5 +52,5 @@ func (s *Step) Description() string {
 }
 
 func (s *Step) Depends() []string {
-	return []string{"node"}
+	return []string{digitalocean.CreateMachineStepName, amazon.StepNameCreateEC2Instance}
 }
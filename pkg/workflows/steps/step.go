@@ -10,9 +10,11 @@ type Status string
 
 const (
 	StatusTodo      Status = "todo"
+	StatusQueued    Status = "queued"
 	StatusExecuting        = "executing"
 	StatusSuccess   Status = "success"
 	StatusError     Status = "error"
+	StatusCancelled Status = "cancelled"
 )
 
 type Step interface {
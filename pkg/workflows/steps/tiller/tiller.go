@@ -9,6 +9,7 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/supergiant/supergiant/pkg/workflows/steps"
+	"github.com/supergiant/supergiant/pkg/workflows/steps/clustercheck"
 
 	tm "github.com/supergiant/supergiant/pkg/templatemanager"
 	"github.com/supergiant/supergiant/pkg/workflows/steps/poststart"
@@ -35,7 +36,7 @@ func New(script *template.Template) *Step {
 }
 
 func (j *Step) Run(ctx context.Context, out io.Writer, config *steps.Config) error {
-	err := steps.RunTemplate(context.Background(), j.script, config.Runner, out, config.TillerConfig)
+	err := steps.RunTemplate(ctx, j.script, config.Runner, out, config.TillerConfig)
 
 	if err != nil {
 		return errors.Wrap(err, "install tiller step")
@@ -57,5 +58,5 @@ func (s *Step) Description() string {
 }
 
 func (s *Step) Depends() []string {
-	return []string{poststart.StepName}
+	return []string{poststart.StepName, clustercheck.StepName}
 }
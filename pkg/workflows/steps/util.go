@@ -6,43 +6,63 @@ import (
 	"io"
 	"text/template"
 
+	"github.com/sirupsen/logrus"
+
 	"github.com/supergiant/supergiant/pkg/runner"
 )
 
+// RunTemplate renders the template with cfg and runs the script on the machine, script that
+// has completed on the machine already is skipped when markers are turned on, see WithMarkers.
 func RunTemplate(ctx context.Context, tpl *template.Template, r runner.Runner, output io.Writer, cfg interface{}) error {
-	resultChan := make(chan error)
+	resultChan := make(chan error, 1)
 
 	go func() {
-		buffer := new(bytes.Buffer)
-		err := tpl.Execute(buffer, cfg)
+		resultChan <- runTemplate(ctx, tpl, r, output, cfg)
+	}()
 
-		if err != nil {
-			resultChan <- err
-		}
-		cmd, err := runner.NewCommand(ctx, buffer.String(), output, output)
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case err := <-resultChan:
+		return err
+	}
+}
+
+func runTemplate(ctx context.Context, tpl *template.Template, r runner.Runner, output io.Writer, cfg interface{}) error {
+	buffer := new(bytes.Buffer)
+	if err := tpl.Execute(buffer, cfg); err != nil {
+		return err
+	}
+	script := buffer.String()
 
+	force, enabled := markers(ctx)
+	if enabled && !force {
+		done, err := completed(ctx, r, tpl.Name(), script)
 		if err != nil {
-			resultChan <- err
+			return err
 		}
 
-		err = r.Run(cmd)
-
-		if err != nil {
-			resultChan <- err
-			return
+		if done {
+			_, err := io.WriteString(output, skippedMessage(tpl.Name()))
+			return err
 		}
+	}
 
-		close(resultChan)
-	}()
+	cmd, err := runner.NewCommand(ctx, script, output, output)
+	if err != nil {
+		return err
+	}
 
-	select {
-	case <-ctx.Done():
-		if ctx.Err() != nil {
-			return ctx.Err()
-		}
-	case err := <-resultChan:
+	if err := r.Run(cmd); err != nil {
 		return err
 	}
 
+	// Script that has completed without the marker is run again next time
+	if enabled {
+		if err := markCompleted(ctx, r, tpl.Name(), script); err != nil {
+			logrus.Warnf("script %s has completed: %v", tpl.Name(), err)
+		}
+	}
+
 	return nil
 }
@@ -1 +1,83 @@
 package steps
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"text/template"
+
+	"github.com/supergiant/supergiant/pkg/runner"
+)
+
+// machineRunner keeps markers written by the scripts and records the scripts that have run
+type machineRunner struct {
+	marker string
+	runs   []string
+}
+
+func (m *machineRunner) Run(cmd *runner.Command) error {
+	switch {
+	case strings.HasPrefix(cmd.Script, "cat "+MarkerDir):
+		_, err := io.WriteString(cmd.Out, m.marker)
+		return err
+	case strings.HasPrefix(cmd.Script, "mkdir -p "+MarkerDir):
+		m.marker = strings.SplitN(cmd.Script, "'", 3)[1]
+		return nil
+	}
+
+	m.runs = append(m.runs, cmd.Script)
+	return nil
+}
+
+func TestRunTemplateMarkers(t *testing.T) {
+	tpl := template.Must(template.New("install").Parse("install {{ . }}"))
+	r := &machineRunner{}
+	ctx := WithMarkers(context.Background(), false)
+
+	if err := RunTemplate(ctx, tpl, r, &bytes.Buffer{}, "v1"); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if r.marker != marker("install v1") {
+		t.Errorf("wrong marker %s expected %s", r.marker, marker("install v1"))
+	}
+
+	out := &bytes.Buffer{}
+	if err := RunTemplate(ctx, tpl, r, out, "v1"); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if len(r.runs) != 1 || out.String() != skippedMessage("install") {
+		t.Errorf("completed script must be skipped, runs %v output %s", r.runs, out.String())
+	}
+
+	// Changed script runs again
+	if err := RunTemplate(ctx, tpl, r, &bytes.Buffer{}, "v2"); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if len(r.runs) != 2 || r.marker != marker("install v2") {
+		t.Errorf("changed script must run, runs %v marker %s", r.runs, r.marker)
+	}
+
+	if err := RunTemplate(WithMarkers(context.Background(), true), tpl, r, &bytes.Buffer{}, "v2"); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if len(r.runs) != 3 {
+		t.Errorf("forced script must run, runs %v", r.runs)
+	}
+
+	for _, c := range []context.Context{context.Background(), WithoutMarkers(ctx)} {
+		r := &machineRunner{marker: marker("install v2")}
+		if err := RunTemplate(c, tpl, r, &bytes.Buffer{}, "v2"); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+
+		if len(r.runs) != 1 || r.marker != marker("install v2") {
+			t.Errorf("markers must not be used, runs %v", r.runs)
+		}
+	}
+}
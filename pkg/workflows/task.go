@@ -14,6 +14,8 @@ import (
 	"github.com/supergiant/supergiant/pkg/util"
 	"github.com/supergiant/supergiant/pkg/workflows/steps"
 	"runtime/debug"
+	"sync"
+	"time"
 )
 
 // Task is an entity that has it own state that can be tracked
@@ -25,9 +27,25 @@ type Task struct {
 	Config       *steps.Config `json:"config"`
 	Status       steps.Status  `json:"status"`
 	StepStatuses []StepStatus  `json:"stepsStatuses"`
+	CreatedAt    time.Time     `json:"createdAt"`
+	UpdatedAt    time.Time     `json:"updatedAt"`
+	// Timing of the last run of the task
+	StartedAt  *time.Time `json:"startedAt,omitempty"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+	Duration   *Duration  `json:"duration,omitempty"`
+	// Replica of the control plane that runs the task, other replicas
+	// may take the task over once the lease of the owner expires.
+	Owner          string     `json:"owner,omitempty"`
+	LeaseExpiresAt *time.Time `json:"leaseExpiresAt,omitempty"`
 
 	workflow   Workflow
 	repository storage.Interface
+	mu         sync.Mutex
+	// cancel stops the running task, the interrupted step is rolled back if rollbackOnCancel is set
+	cancel           context.CancelFunc
+	rollbackOnCancel bool
+	// force runs scripts of the steps that have left completion markers on the machine
+	force bool
 }
 
 func NewTask(taskType string, repository storage.Interface) (*Task, error) {
@@ -42,9 +60,10 @@ func NewTask(taskType string, repository storage.Interface) (*Task, error) {
 
 func newTask(workflowType string, workflow Workflow, repository storage.Interface) *Task {
 	return &Task{
-		ID:     uuid.New(),
-		Type:   workflowType,
-		Status: steps.StatusTodo,
+		ID:        uuid.New(),
+		Type:      workflowType,
+		Status:    steps.StatusTodo,
+		CreatedAt: time.Now(),
 
 		workflow:   workflow,
 		repository: repository,
@@ -54,154 +73,490 @@ func newTask(workflowType string, workflow Workflow, repository storage.Interfac
 // Run executes all steps of workflow and tracks the progress in persistent storage
 func (w *Task) Run(ctx context.Context, config steps.Config, out io.WriteCloser) chan error {
 	errChan := make(chan error, 1)
+	if w == nil {
+		return errChan
+	}
 
-	go func() {
-		defer func() {
-			if r := recover(); r != nil {
-				w.Status = steps.StatusError
-				if err := w.sync(ctx); err != nil {
-					logrus.Errorf("sync error %v for task %s", err, w.ID)
-				}
-				debug.PrintStack()
-				errChan <- errors.Errorf("provisioning failed, unexpected panic: %v ", r)
-			}
-		}()
-		if w == nil {
-			return
-		}
-
-		// Create list of statuses to track
-		for _, step := range w.workflow {
-			w.StepStatuses = append(w.StepStatuses, StepStatus{
-				Status:   steps.StatusTodo,
-				StepName: step.Name(),
-				ErrMsg:   "",
-			})
-		}
+	ctx, err := w.track(ctx, w.ID)
+	if err != nil {
+		errChan <- err
+		return errChan
+	}
 
-		// Set config to the task
-		w.Config = &config
-		// Save task state before first step
-		if err := w.sync(ctx); err != nil {
-			logrus.Errorf("Error saving task state %v", err)
-		}
-		// Start from the first step
-		err := w.startFrom(ctx, w.ID, out, 0)
+	go func() {
+		err := w.run(ctx, &config, out)
+		// Task can be restarted as soon as the result is received
+		w.untrack(w.ID)
 
 		if err != nil {
 			errChan <- err
 			return
 		}
-
-		logrus.Infof("Task %s has finished successfully", w.ID)
-		// Notify provisioner that task output closed with error
-		if err := out.Close(); err != nil {
-			errChan <- err
-		}
 		close(errChan)
 	}()
 
 	return errChan
 }
 
-// Restart executes task from the last failed step
-func (w *Task) Restart(ctx context.Context, id string, out io.Writer) chan error {
+func (w *Task) run(ctx context.Context, config *steps.Config, out io.WriteCloser) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			w.Status = steps.StatusError
+			if err := w.sync(ctx); err != nil {
+				logrus.Errorf("sync error %v for task %s", err, w.ID)
+			}
+			debug.PrintStack()
+			err = errors.Errorf("provisioning failed, unexpected panic: %v ", r)
+		}
+	}()
+
+	w.mu.Lock()
+	// Create list of statuses to track
+	for _, step := range w.workflow {
+		w.StepStatuses = append(w.StepStatuses, StepStatus{
+			Status:   steps.StatusTodo,
+			StepName: step.Name(),
+			ErrMsg:   "",
+		})
+	}
+
+	// Set config to the task
+	w.Config = config
+	if config.DryRun {
+		w.dryRun()
+	}
+	w.renewLease()
+	// Save task state before first step
+	if err := w.sync(ctx); err != nil {
+		logrus.Errorf("Error saving task state %v", err)
+	}
+	w.mu.Unlock()
+
+	go w.heartbeat(ctx)
+
+	release, err := w.schedule(ctx)
+	if err != nil {
+		w.finish(ctx, stopStatus(ctx))
+		return errors.Wrapf(err, "task %s", w.ID)
+	}
+	defer release()
+
+	w.mu.Lock()
+	w.start()
+	w.mu.Unlock()
+
+	if err := w.execute(ctx, out); err != nil {
+		w.finish(ctx, "")
+		return err
+	}
+
+	w.finish(ctx, steps.StatusSuccess)
+
+	logrus.Infof("Task %s has finished successfully", w.ID)
+	// Notify provisioner that task output closed with error
+	return out.Close()
+}
+
+// Restart executes steps of the task that have not succeeded, scripts that have completed
+// on the machine already are skipped unless force is set.
+func (w *Task) Restart(ctx context.Context, id string, out io.Writer, force bool) chan error {
 	errChan := make(chan error, 1)
 	wsLog := util.GetLogger(out)
 
 	wsLog.Infof("Restarting task %s", id)
+	ctx, err := w.track(ctx, id)
+	if err != nil {
+		errChan <- err
+		close(errChan)
+		return errChan
+	}
+
+	w.force = force
 	go func() {
 		defer close(errChan)
-		data, err := w.repository.Get(ctx, Prefix, id)
+
+		err := w.restart(ctx, id, out)
+		w.untrack(id)
 
 		if err != nil {
 			errChan <- err
-			return
 		}
+	}()
+	return errChan
+}
 
-		err = json.Unmarshal(data, w)
+func (w *Task) restart(ctx context.Context, id string, out io.Writer) error {
+	data, err := w.repository.Get(ctx, Prefix, id)
 
-		if err != nil {
-			errChan <- err
-			return
-		}
+	if err != nil {
+		return err
+	}
 
-		i := 0
-		// Skip successfully finished steps
-		for index, stepStatus := range w.StepStatuses {
-			if stepStatus.Status == steps.StatusError {
-				i = index
+	err = json.Unmarshal(data, w)
+
+	if err != nil {
+		return err
+	}
+
+	if w.Owner != Owner() && !w.leaseExpired(time.Now()) {
+		return errors.Wrapf(errNotOwner, "task %s owner %s", w.ID, w.Owner)
+	}
+
+	if w.Config != nil && w.Config.DryRun {
+		w.dryRun()
+	}
+
+	w.mu.Lock()
+	w.renewLease()
+	if err := w.sync(ctx); err != nil {
+		logrus.Errorf("sync error %v for task %s", err, w.ID)
+	}
+	w.mu.Unlock()
+
+	go w.heartbeat(ctx)
+
+	release, err := w.schedule(ctx)
+	if err != nil {
+		w.finish(ctx, stopStatus(ctx))
+		return errors.Wrapf(err, "task %s", w.ID)
+	}
+	defer release()
+
+	w.mu.Lock()
+	w.start()
+	w.mu.Unlock()
+
+	// Successfully finished steps are skipped
+	if err := w.execute(ctx, out); err != nil {
+		w.finish(ctx, "")
+		return err
+	}
+
+	w.finish(ctx, steps.StatusSuccess)
+
+	return nil
+}
+
+// start resets timing of the task before it is run
+func (w *Task) start() {
+	now := time.Now()
+	w.StartedAt = &now
+	w.FinishedAt = nil
+	w.Duration = nil
+}
+
+// finish records the time the task has taken and saves the task, the status
+// is kept as it is when empty since failed steps have set it already.
+func (w *Task) finish(ctx context.Context, status steps.Status) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if status != "" {
+		w.Status = status
+	}
+
+	now := time.Now()
+	w.FinishedAt = &now
+	w.Duration = elapsed(w.StartedAt, now)
+	if w.Duration != nil {
+		taskDurations.observe(w.Type, w.Status, w.Duration.Duration)
+	}
+
+	if err := w.sync(ctx); err != nil {
+		logrus.Errorf("sync error %v for task %s", err, w.ID)
+	}
+}
+
+// execute runs steps that have not succeeded yet, a step is started as soon as
+// all steps it depends on have succeeded and no more than Parallelism steps run at once.
+// After the first failure new steps are not started, running ones are waited for.
+func (w *Task) execute(ctx context.Context, out io.Writer) error {
+	g, err := newGraph(w.workflow)
+	if err != nil {
+		return errors.Wrapf(err, "task %s", w.ID)
+	}
+
+	type result struct {
+		index int
+		err   error
+	}
+
+	// Steps running in parallel share the output
+	out = &syncWriter{w: out}
+	limit := Parallelism()
+	results := make(chan result)
+	started := make([]bool, len(w.workflow))
+	running := 0
+
+	var firstErr error
+	for {
+		for i := range w.workflow {
+			if firstErr != nil || running >= limit || ctx.Err() != nil {
 				break
 			}
+
+			if started[i] || w.succeeded(i) || !g.ready(i, w.succeeded) {
+				continue
+			}
+
+			started[i] = true
+			running++
+			go func(i int) {
+				results <- result{index: i, err: w.runStep(ctx, out, i)}
+			}(i)
 		}
-		// Start from the last failed one
-		err = w.startFrom(ctx, id, out, i)
 
-		if err != nil {
-			errChan <- err
+		if running == 0 {
+			break
 		}
-	}()
-	return errChan
+
+		r := <-results
+		running--
+		if r.err != nil && firstErr == nil {
+			firstErr = r.err
+		}
+	}
+
+	if firstErr != nil {
+		w.undo(ctx, out, g)
+		return firstErr
+	}
+
+	// Steps are left undone only when the task has been stopped before they were started
+	for i := range w.workflow {
+		if !w.succeeded(i) {
+			w.mu.Lock()
+			w.Status = stopStatus(ctx)
+			if err := w.sync(ctx); err != nil {
+				logrus.Errorf("sync error %v for task %s", err, w.ID)
+			}
+			w.mu.Unlock()
+
+			w.undo(ctx, out, g)
+			return errors.Wrapf(ctx.Err(), "task %s", w.ID)
+		}
+	}
+
+	return nil
 }
 
-// start task execution from particular step
-func (w *Task) startFrom(ctx context.Context, id string, out io.Writer, i int) error {
-	// Start workflow from the last failed step
+// runStep runs step of the workflow with index i and tracks its status
+func (w *Task) runStep(ctx context.Context, out io.Writer, i int) (err error) {
+	step := w.workflow[i]
 	wsLog := util.GetLogger(out)
-	for index := i; index < len(w.StepStatuses); index++ {
-		step := w.workflow[index]
-
-		wsLog.Infof("[%s] - started", step.Name())
-		logrus.Info(step.Name())
+	// Commands issued by the step are recorded in its status in dry run
+	ctx = context.WithValue(ctx, stepIndexKey{}, i)
+	if w.Config == nil || !w.Config.DryRun {
+		ctx = steps.WithMarkers(ctx, w.force)
+	}
 
-		// Sync to storage with task in executing state
-		w.StepStatuses[index].Status = steps.StatusExecuting
-		if err := w.sync(ctx); err != nil {
-			logrus.Errorf("sync error %v", err)
+	defer func() {
+		if r := recover(); r != nil {
+			debug.PrintStack()
+			err = errors.Errorf("step %s has panicked: %v", step.Name(), r)
+			w.setStepStatus(ctx, i, steps.StatusError, err)
 		}
+	}()
 
-		if err := step.Run(ctx, out, w.Config); err != nil {
-			// Mark step status as error
-			w.StepStatuses[index].Status = steps.StatusError
-			w.StepStatuses[index].ErrMsg = err.Error()
+	wsLog.Infof("[%s] - started", step.Name())
+	logrus.Info(step.Name())
 
-			wsLog.Infof("[%s] - failed: %s", step.Name(), err.Error())
-			if err2 := w.sync(ctx); err2 != nil {
-				logrus.Errorf("sync error %v for step %s", err2, step.Name())
-			}
+	// Sync to storage with task in executing state
+	w.setStepStatus(ctx, i, steps.StatusExecuting, nil)
 
-			if err3 := step.Rollback(ctx, out, w.Config); err3 != nil {
-				logrus.Errorf("rollback: step %s : %v", step.Name(), err3)
+	if err := w.runAttempts(ctx, out, i); err != nil {
+		if ctx.Err() == context.Canceled {
+			wsLog.Infof("[%s] - cancelled", step.Name())
+			w.setStepStatus(ctx, i, steps.StatusCancelled, err)
+
+			// Task that rolls back all its steps does it once running steps are stopped
+			if w.shouldRollbackOnCancel() && !w.rollbackOnFailure() {
+				// Context of the task is done already
+				w.rollbackStep(context.Background(), out, i)
 			}
 
 			return err
-		} else {
-			wsLog.Infof("[%s] - success", step.Name())
-			// Mark step as success
-			w.StepStatuses[index].Status = steps.StatusSuccess
-			if err := w.sync(ctx); err != nil {
-				logrus.Errorf("sync error %v for step %s", err, step.Name())
-			}
 		}
+
+		wsLog.Infof("[%s] - failed: %s", step.Name(), err.Error())
+		w.setStepStatus(ctx, i, steps.StatusError, err)
+
+		if !w.rollbackOnFailure() {
+			w.rollbackStep(ctx, out, i)
+		}
+
+		return err
 	}
 
+	wsLog.Infof("[%s] - success", step.Name())
+	w.setStepStatus(ctx, i, steps.StatusSuccess, nil)
+
 	return nil
 }
 
+// runAttempts runs the step until it succeeds or retry policy of the step gives up
+func (w *Task) runAttempts(ctx context.Context, out io.Writer, i int) error {
+	step := w.workflow[i]
+	policy := retryPolicy(step)
+	attempts := policy.attempts()
+
+	for attempt := 1; ; attempt++ {
+		if err := w.waitCloudAPI(ctx, step); err != nil {
+			return err
+		}
+
+		err := step.Run(ctx, out, w.Config)
+		w.recordAttempt(ctx, i, err)
+
+		if err == nil || attempt == attempts || ctx.Err() != nil || !policy.retryable(err) {
+			return err
+		}
+
+		delay := policy.delay(attempt)
+		util.GetLogger(out).Infof("[%s] - attempt %d of %d failed, retry in %s: %v",
+			step.Name(), attempt, attempts, delay, err)
+
+		if !sleep(ctx, delay) {
+			return err
+		}
+	}
+}
+
+func (w *Task) recordAttempt(ctx context.Context, i int, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.StepStatuses[i].Attempts++
+	if err == nil {
+		return
+	}
+
+	w.StepStatuses[i].AttemptErrors = append(w.StepStatuses[i].AttemptErrors, err.Error())
+	if err := w.sync(ctx); err != nil {
+		logrus.Errorf("sync error %v for step %s", err, w.StepStatuses[i].StepName)
+	}
+}
+
+// setStepStatus updates status of the step and saves the task, steps
+// running in parallel update the task one at a time.
+func (w *Task) setStepStatus(ctx context.Context, i int, status steps.Status, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.StepStatuses[i].Status = status
+	switch status {
+	case steps.StatusExecuting:
+		w.Status = steps.StatusExecuting
+		w.StepStatuses[i].ErrMsg = ""
+		// Attempts are counted for every run of the task separately
+		w.StepStatuses[i].Attempts = 0
+		w.StepStatuses[i].AttemptErrors = nil
+		w.StepStatuses[i].Scripts = nil
+		w.StepStatuses[i].RollbackStatus = ""
+		w.StepStatuses[i].RollbackErrMsg = ""
+
+		now := time.Now()
+		w.StepStatuses[i].StartedAt = &now
+		w.StepStatuses[i].FinishedAt = nil
+		w.StepStatuses[i].Duration = nil
+		w.setStepNode(i)
+	case steps.StatusError, steps.StatusCancelled:
+		w.Status = status
+		w.StepStatuses[i].ErrMsg = err.Error()
+		w.finishStep(i, status)
+	case steps.StatusSuccess:
+		w.finishStep(i, status)
+	}
+
+	if err := w.sync(ctx); err != nil {
+		logrus.Errorf("sync error %v for step %s", err, w.StepStatuses[i].StepName)
+	}
+}
+
+// finishStep records the time the step has taken, node of the
+// step is known only after cloud steps have created it.
+func (w *Task) finishStep(i int, status steps.Status) {
+	now := time.Now()
+	w.StepStatuses[i].FinishedAt = &now
+	w.StepStatuses[i].Duration = elapsed(w.StepStatuses[i].StartedAt, now)
+	w.setStepNode(i)
+
+	if w.StepStatuses[i].Duration != nil {
+		stepDurations.observe(w.StepStatuses[i].StepName, status, w.StepStatuses[i].Duration.Duration)
+	}
+}
+
+func (w *Task) setStepNode(i int) {
+	if w.Config == nil {
+		return
+	}
+
+	n := w.Config.CurrentNode()
+	w.StepStatuses[i].Node = n.Name
+	w.StepStatuses[i].Host = n.PublicIp
+}
+
+func (w *Task) shouldRollbackOnCancel() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.rollbackOnCancel
+}
+
+func (w *Task) succeeded(i int) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.StepStatuses[i].Status == steps.StatusSuccess
+}
+
+// PutOps returns storage operations that save current state of the task and its
+// cluster index, it allows to persist the task in one transaction with other entities.
+func (w *Task) PutOps() ([]storage.Op, error) {
+	data, err := w.marshal()
+
+	if err != nil {
+		return nil, err
+	}
+
+	ops := []storage.Op{storage.PutOp(Prefix, w.ID, data)}
+	if clusterName := w.clusterName(); clusterName != "" {
+		ops = append(ops, storage.PutOp(ClusterIndexPrefix, ClusterIndexKey(clusterName, w.ID), []byte(w.ID)))
+	}
+
+	return ops, nil
+}
+
 // synchronize state of workflow to storage
 func (w *Task) sync(ctx context.Context) error {
+	// State of the stopped task has to be saved as well
+	if ctx.Err() != nil {
+		ctx = context.Background()
+	}
+
+	ops, err := w.PutOps()
+
+	if err != nil {
+		return err
+	}
+
+	return w.repository.Txn(ctx, ops...)
+}
+
+func (w *Task) marshal() ([]byte, error) {
+	w.UpdatedAt = time.Now()
 	data, err := json.Marshal(w)
 	buf := &bytes.Buffer{}
 
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	err = json.Indent(buf, data, "", "\t")
 
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return w.repository.Put(ctx, Prefix, w.ID, buf.Bytes())
+	return buf.Bytes(), nil
 }
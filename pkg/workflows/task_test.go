@@ -12,6 +12,7 @@ import (
 
 	"github.com/stretchr/testify/require"
 	"github.com/supergiant/supergiant/pkg/sgerrors"
+	"github.com/supergiant/supergiant/pkg/storage"
 	"github.com/supergiant/supergiant/pkg/workflows/steps"
 )
 
@@ -42,16 +43,61 @@ func (f *MockRepository) GetAll(ctx context.Context, prefix string) ([][]byte, e
 	return nil, nil
 }
 
+func (f *MockRepository) List(ctx context.Context, prefix string) ([]storage.KeyValue, error) {
+	return nil, nil
+}
+
+func (f *MockRepository) Page(ctx context.Context, prefix string, limit int, continueToken string) ([]storage.KeyValue, string, error) {
+	return nil, "", nil
+}
+
 func (f *MockRepository) Delete(ctx context.Context, prefix string, key string) error {
 	return nil
 }
 
+func (f *MockRepository) Update(ctx context.Context, prefix string, key string, fn storage.UpdateFunc) error {
+	value, err := fn(f.storage[fmt.Sprintf("%s/%s", prefix, key)])
+	if err != nil {
+		return err
+	}
+	f.storage[fmt.Sprintf("%s/%s", prefix, key)] = value
+
+	return nil
+}
+
+func (f *MockRepository) Txn(ctx context.Context, ops ...storage.Op) error {
+	for _, op := range ops {
+		switch op.Type {
+		case storage.OpPut:
+			f.storage[fmt.Sprintf("%s/%s", op.Prefix, op.Key)] = op.Value
+		case storage.OpDelete:
+			delete(f.storage, fmt.Sprintf("%s/%s", op.Prefix, op.Key))
+		}
+	}
+
+	return nil
+}
+
+func (f *MockRepository) Close() error {
+	return nil
+}
+
+func (f *MockRepository) Watch(ctx context.Context, prefix string) (<-chan storage.Event, error) {
+	events := make(chan storage.Event)
+	go func() {
+		<-ctx.Done()
+		close(events)
+	}()
+	return events, nil
+}
+
 type MockStep struct {
 	name        string
 	description string
 	counter     int
 	messages    []string
 	errs        []error
+	depends     []string
 	rollback    bool
 }
 
@@ -87,7 +133,7 @@ func (f *MockStep) Description() string {
 }
 
 func (f *MockStep) Depends() []string {
-	return nil
+	return f.depends
 }
 
 func TestNewTask(t *testing.T) {
@@ -210,6 +256,16 @@ func TestTaskRunSuccess(t *testing.T) {
 		if status.Status != steps.StatusSuccess {
 			t.Errorf("Unexpected status expectec %s actual %s", steps.StatusSuccess, status.Status)
 		}
+
+		if status.StartedAt == nil || status.FinishedAt == nil || status.Duration == nil {
+			t.Errorf("Timing of step %s must be recorded", status.StepName)
+		}
+	}
+
+	if w.StartedAt == nil || w.FinishedAt == nil || w.Duration == nil {
+		t.Error("Timing of task must be recorded")
+	} else if w.FinishedAt.Before(*w.StartedAt) {
+		t.Errorf("Task finished %v before it started %v", w.FinishedAt, w.StartedAt)
 	}
 }
 
@@ -260,7 +316,7 @@ func TestWorkflowRestart(t *testing.T) {
 	}
 
 	buffer.Reset()
-	errChan = task.Restart(context.Background(), id, buffer)
+	errChan = task.Restart(context.Background(), id, buffer, false)
 	err = <-errChan
 
 	if err != nil {
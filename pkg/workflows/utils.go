@@ -2,9 +2,15 @@ package workflows
 
 import (
 	"encoding/json"
+	"io"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
 
 	"github.com/supergiant/supergiant/pkg/runner/ssh"
 	"github.com/supergiant/supergiant/pkg/storage"
+	"github.com/supergiant/supergiant/pkg/workflows/steps"
 )
 
 func DeserializeTask(data []byte, repository storage.Interface) (*Task, error) {
@@ -18,16 +24,25 @@ func DeserializeTask(data []byte, repository storage.Interface) (*Task, error) {
 	// Assign repository from task handler to task and restore workflow
 	task.repository = repository
 	task.workflow = GetWorkflow(task.Type)
+	if task.Type == BuildTaskType {
+		if task.workflow, err = BuildWorkflow(task.stepNames()); err != nil {
+			return nil, errors.Wrapf(err, "task %s", task.ID)
+		}
+	} else if len(task.workflow) > 0 && len(task.StepStatuses) > len(task.workflow) {
+		// Scripts of the profile follow steps of the workflow, task
+		// that has lost some of them is not resumable.
+		if err := task.AppendSteps(task.stepNames()[len(task.workflow):]); err != nil {
+			logrus.Warnf("task %s: %v", task.ID, err)
+		}
+	}
 
-	cfg := ssh.Config{
-		Host:    task.Config.Node.PublicIp,
-		Port:    task.Config.SshConfig.Port,
-		User:    task.Config.SshConfig.User,
-		Timeout: task.Config.SshConfig.Timeout,
-		Key:     []byte(task.Config.SshConfig.BootstrapPrivateKey),
+	// Task has not been started or its machine has not been created yet,
+	// runner is made by ssh step in that case. Dry run tasks record scripts instead.
+	if task.Config == nil || task.Config.Node.PublicIp == "" || task.Config.DryRun {
+		return task, nil
 	}
 
-	task.Config.Runner, err = ssh.NewRunner(cfg)
+	task.Config.Runner, err = ssh.NewRunner(nodeSshConfig(task.Config))
 
 	if err != nil {
 		return nil, err
@@ -35,3 +50,27 @@ func DeserializeTask(data []byte, repository storage.Interface) (*Task, error) {
 
 	return task, nil
 }
+
+// nodeSshConfig returns parameters of ssh connection to the node of the config
+func nodeSshConfig(config *steps.Config) ssh.Config {
+	return ssh.Config{
+		Host:    config.Node.PublicIp,
+		Port:    config.SshConfig.Port,
+		User:    config.SshConfig.User,
+		Timeout: config.SshConfig.Timeout,
+		Key:     []byte(config.SshConfig.BootstrapPrivateKey),
+	}
+}
+
+// syncWriter serializes writes of steps that run in parallel
+type syncWriter struct {
+	m sync.Mutex
+	w io.Writer
+}
+
+func (s *syncWriter) Write(p []byte) (int, error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	return s.w.Write(p)
+}
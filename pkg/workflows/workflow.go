@@ -2,6 +2,7 @@ package workflows
 
 import (
 	"sync"
+	"time"
 
 	"github.com/supergiant/supergiant/pkg/workflows/steps"
 	"github.com/supergiant/supergiant/pkg/workflows/steps/certificates"
@@ -16,6 +17,7 @@ import (
 	"github.com/supergiant/supergiant/pkg/workflows/steps/manifest"
 	"github.com/supergiant/supergiant/pkg/workflows/steps/network"
 	"github.com/supergiant/supergiant/pkg/workflows/steps/poststart"
+	"github.com/supergiant/supergiant/pkg/workflows/steps/preflight"
 	"github.com/supergiant/supergiant/pkg/workflows/steps/ssh"
 	"github.com/supergiant/supergiant/pkg/workflows/steps/tiller"
 )
@@ -26,6 +28,21 @@ type StepStatus struct {
 	Status   steps.Status `json:"status"`
 	StepName string       `json:"stepName"`
 	ErrMsg   string       `json:"errorMessage"`
+	// Attempts is the number of runs of the step, AttemptErrors
+	// holds errors of the failed ones in the order of attempts.
+	Attempts      int      `json:"attempts,omitempty"`
+	AttemptErrors []string `json:"attemptErrors,omitempty"`
+	// Scripts are rendered by the step in dry run instead of being run
+	Scripts []string `json:"scripts,omitempty"`
+	// RollbackStatus is empty when the step has not been rolled back
+	RollbackStatus steps.Status `json:"rollbackStatus,omitempty"`
+	RollbackErrMsg string       `json:"rollbackErrorMessage,omitempty"`
+	// Timing of the last run of the step and the node it has run on
+	StartedAt  *time.Time `json:"startedAt,omitempty"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+	Duration   *Duration  `json:"duration,omitempty"`
+	Node       string     `json:"node,omitempty"`
+	Host       string     `json:"host,omitempty"`
 }
 
 // Workflow is a template for doing some actions
@@ -49,62 +66,125 @@ type WorkflowSet struct {
 	DeleteCluster   string
 }
 
+// downloadRetry is a retry policy of built-in steps that fetch packages
+// and binaries from the internet, they fail on network glitches.
+var downloadRetry = map[string]*RetryPolicy{
+	downloadk8sbinary.StepName: defaultDownloadRetry,
+	docker.StepName:            defaultDownloadRetry,
+	cni.StepName:               defaultDownloadRetry,
+	flannel.StepName:           defaultDownloadRetry,
+}
+
+var defaultDownloadRetry = &RetryPolicy{
+	MaxAttempts: 3,
+	Backoff:     Duration{10 * time.Second},
+	Multiplier:  2,
+	MaxBackoff:  Duration{time.Minute},
+}
+
+// DefaultParallelism is the number of independent steps of a task that run at the same time
+const DefaultParallelism = 4
+
 var (
 	m           sync.RWMutex
 	workflowMap map[string]Workflow
+	parallelism = DefaultParallelism
 )
 
-func Init() {
+// Init registers built-in workflows, it fails if steps of a workflow are
+// not registered or their dependencies can not be resolved.
+func Init() error {
+	m.Lock()
 	workflowMap = make(map[string]Workflow)
-
-	digitalOceanMasterWorkflow := []steps.Step{
-		steps.GetStep(digitalocean.CreateMachineStepName),
-		steps.GetStep(ssh.StepName),
-		steps.GetStep(downloadk8sbinary.StepName),
-		steps.GetStep(docker.StepName),
-		steps.GetStep(cni.StepName),
-		steps.GetStep(certificates.StepName),
-		steps.GetStep(etcd.StepName),
-		steps.GetStep(flannel.StepName),
-		steps.GetStep(manifest.StepName),
-		steps.GetStep(kubelet.StepName),
-		steps.GetStep(network.StepName),
-		steps.GetStep(poststart.StepName),
-	}
-	digitalOceanNodeWorkflow := []steps.Step{
-		steps.GetStep(digitalocean.CreateMachineStepName),
-		steps.GetStep(ssh.StepName),
-		steps.GetStep(downloadk8sbinary.StepName),
-		steps.GetStep(docker.StepName),
-		steps.GetStep(certificates.StepName),
-		steps.GetStep(manifest.StepName),
-		steps.GetStep(flannel.StepName),
-		steps.GetStep(kubelet.StepName),
-		steps.GetStep(cni.StepName),
-		steps.GetStep(poststart.StepName),
+	m.Unlock()
+
+	builtin := map[string][]string{
+		DigitalOceanMaster: {
+			digitalocean.CreateMachineStepName,
+			ssh.StepName,
+			preflight.StepName,
+			downloadk8sbinary.StepName,
+			docker.StepName,
+			cni.StepName,
+			certificates.StepName,
+			etcd.StepName,
+			flannel.StepName,
+			manifest.StepName,
+			kubelet.StepName,
+			network.StepName,
+			poststart.StepName,
+		},
+		DigitalOceanNode: {
+			digitalocean.CreateMachineStepName,
+			ssh.StepName,
+			preflight.StepName,
+			downloadk8sbinary.StepName,
+			docker.StepName,
+			certificates.StepName,
+			manifest.StepName,
+			flannel.StepName,
+			kubelet.StepName,
+			cni.StepName,
+			poststart.StepName,
+		},
+		Cluster: {
+			ssh.StepName,
+			clustercheck.StepName,
+			tiller.StepName,
+		},
+		DigitalOceanDeleteNode: {
+			digitalocean.DeleteMachineStepName,
+		},
+		DigitalOceanDeleteCluster: {
+			digitalocean.DeleteClusterStepName,
+		},
 	}
-	clusterWorkflow := []steps.Step{
-		steps.GetStep(ssh.StepName),
-		steps.GetStep(clustercheck.StepName),
-		steps.GetStep(tiller.StepName),
+
+	for name, stepNames := range builtin {
+		def := Definition{
+			Name:  name,
+			Steps: make([]StepDefinition, 0, len(stepNames)),
+		}
+
+		for _, stepName := range stepNames {
+			def.Steps = append(def.Steps, StepDefinition{
+				Name:    stepName,
+				Depends: afterPreflight(stepName, stepNames),
+				Retry:   downloadRetry[stepName],
+			})
+		}
+
+		workflow, err := def.Build()
+		if err != nil {
+			return err
+		}
+
+		RegisterWorkFlow(name, workflow)
 	}
 
-	digitalOceanDeleteWorkflow := []steps.Step{
-		steps.GetStep(digitalocean.DeleteMachineStepName),
+	return nil
+}
+
+// afterPreflight makes steps of the workflow that need the machine wait for its
+// pre-flight checks, nil keeps dependencies declared by the step.
+func afterPreflight(stepName string, workflow []string) []string {
+	step := steps.GetStep(stepName)
+	if step == nil || stepName == preflight.StepName {
+		return nil
 	}
 
-	digitalOceanDeleteClusterWorkflow := []steps.Step{
-		steps.GetStep(digitalocean.DeleteClusterStepName),
+	hasPreflight := false
+	for _, name := range workflow {
+		hasPreflight = hasPreflight || name == preflight.StepName
 	}
 
-	m.Lock()
-	defer m.Unlock()
+	for _, dep := range step.Depends() {
+		if hasPreflight && dep == ssh.StepName {
+			return append(append([]string{}, step.Depends()...), preflight.StepName)
+		}
+	}
 
-	workflowMap[DigitalOceanDeleteNode] = digitalOceanDeleteWorkflow
-	workflowMap[Cluster] = clusterWorkflow
-	workflowMap[DigitalOceanMaster] = digitalOceanMasterWorkflow
-	workflowMap[DigitalOceanNode] = digitalOceanNodeWorkflow
-	workflowMap[DigitalOceanDeleteCluster] = digitalOceanDeleteClusterWorkflow
+	return nil
 }
 
 func RegisterWorkFlow(workflowName string, workflow Workflow) {
@@ -118,3 +198,21 @@ func GetWorkflow(workflowName string) Workflow {
 	defer m.RUnlock()
 	return workflowMap[workflowName]
 }
+
+// SetParallelism limits the number of steps of a task that run at the same time,
+// values below one make steps run one by one.
+func SetParallelism(n int) {
+	if n < 1 {
+		n = 1
+	}
+
+	m.Lock()
+	defer m.Unlock()
+	parallelism = n
+}
+
+func Parallelism() int {
+	m.RLock()
+	defer m.RUnlock()
+	return parallelism
+}
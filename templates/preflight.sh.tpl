@@ -0,0 +1,25 @@
+#!/bin/sh
+# Facts about the machine are printed as key=value lines, supergiant checks them against requirements
+
+echo "os=$(uname -s | tr '[:upper:]' '[:lower:]')"
+if [ -f /etc/os-release ]; then
+    . /etc/os-release
+fi
+echo "distribution=${ID}"
+echo "versionId=${VERSION_ID}"
+echo "versionCodename=${VERSION_CODENAME:-${UBUNTU_CODENAME}}"
+echo "arch=$(uname -m)"
+echo "cpus=$(nproc 2>/dev/null || grep -c ^processor /proc/cpuinfo)"
+echo "memoryMb=$(awk '/^MemTotal:/ {print int($2 / 1024)}' /proc/meminfo)"
+echo "diskGb=$(df -Pk / | awk 'NR == 2 {print int($4 / 1048576)}')"
+
+LISTENING=$( (ss -tln 2>/dev/null || netstat -tln 2>/dev/null) | awk 'NR > 1 {print $4}')
+{{ range .Binaries }}
+echo "binary.{{ . }}=$(command -v {{ . }} || true)"
+{{- end }}
+{{ range .Ports }}
+if echo "${LISTENING}" | grep -q ':{{ . }}$'; then echo "port.{{ . }}=busy"; else echo "port.{{ . }}=free"; fi
+{{- end }}
+{{ range .Urls }}
+echo "url.{{ . }}=$(curl -sS -o /dev/null -m 10 -w '%{http_code}' {{ . }} 2>/dev/null || true)"
+{{- end }}
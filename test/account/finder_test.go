@@ -10,7 +10,6 @@ import (
 	"net/http"
 	"net/http/httptest"
 
-	"github.com/coreos/etcd/clientv3"
 	"github.com/gorilla/mux"
 	"github.com/pborman/uuid"
 	"github.com/sirupsen/logrus"
@@ -24,11 +23,11 @@ import (
 
 const defaultETCDHost = "http://127.0.0.1:2379"
 
-var defaultConfig clientv3.Config
+var defaultConfig storage.ETCDConfig
 
 func init() {
 	assert.MustRunETCD(defaultETCDHost)
-	defaultConfig = clientv3.Config{
+	defaultConfig = storage.ETCDConfig{
 		Endpoints: []string{defaultETCDHost},
 	}
 }
@@ -1,3 +1,4 @@
+//go:build integration
 // +build integration
 
 package pki
@@ -9,7 +10,6 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"github.com/coreos/etcd/clientv3"
 	"github.com/stretchr/testify/require"
 	"github.com/supergiant/supergiant/pkg/pki"
 	"github.com/supergiant/supergiant/pkg/storage"
@@ -21,7 +21,7 @@ const (
 	defaultETCDHost = "http://127.0.0.1:2379"
 )
 
-var defaultConfig clientv3.Config
+var defaultConfig storage.ETCDConfig
 
 const validCert = `-----BEGIN CERTIFICATE-----
 MIIDWjCCAkICCQDL2Tw+Yt3GVTANBgkqhkiG9w0BAQUFADBvMRAwDgYDVQQDDAdx
@@ -74,7 +74,7 @@ uZHHNeMDu9+DXXLGkf6K4AxCm8bW7iO9iovJbdGUiqEo2wHxmcKoSAdAIwvUlf+0
 
 func init() {
 	assert.MustRunETCD(defaultETCDHost)
-	defaultConfig = clientv3.Config{
+	defaultConfig = storage.ETCDConfig{
 		Endpoints: []string{defaultETCDHost},
 	}
 }
@@ -1,3 +1,4 @@
+//go:build integration
 // +build integration
 
 package profile
@@ -7,8 +8,6 @@ import (
 	"testing"
 	"time"
 
-	"github.com/coreos/etcd/clientv3"
-
 	"github.com/supergiant/supergiant/pkg/profile"
 	"github.com/supergiant/supergiant/pkg/sgerrors"
 	"github.com/supergiant/supergiant/pkg/storage"
@@ -19,11 +18,11 @@ const (
 	defaultETCDHost = "http://127.0.0.1:2379"
 )
 
-var defaultConfig clientv3.Config
+var defaultConfig storage.ETCDConfig
 
 func init() {
 	assert.MustRunETCD(defaultETCDHost)
-	defaultConfig = clientv3.Config{
+	defaultConfig = storage.ETCDConfig{
 		Endpoints: []string{defaultETCDHost},
 	}
 }
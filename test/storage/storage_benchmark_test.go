@@ -0,0 +1,53 @@
+//go:build integration
+// +build integration
+
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/supergiant/supergiant/pkg/storage"
+)
+
+const benchPrefix = "/bench/"
+
+// BenchmarkETCDGetSharedClient reuses one etcd client for all requests
+func BenchmarkETCDGetSharedClient(b *testing.B) {
+	kv := storage.NewETCDRepository(defaultConfig)
+	defer kv.Close()
+
+	benchmarkGet(b, func() storage.Interface {
+		return kv
+	}, func(storage.Interface) {})
+}
+
+// BenchmarkETCDGetDialPerCall dials etcd for every request the way
+// the repository used to work before sharing the client
+func BenchmarkETCDGetDialPerCall(b *testing.B) {
+	benchmarkGet(b, func() storage.Interface {
+		return storage.NewETCDRepository(defaultConfig)
+	}, func(kv storage.Interface) {
+		kv.Close()
+	})
+}
+
+func benchmarkGet(b *testing.B, get func() storage.Interface, release func(storage.Interface)) {
+	ctx := context.Background()
+	kv := storage.NewETCDRepository(defaultConfig)
+	defer kv.Close()
+
+	if err := kv.Put(ctx, benchPrefix, "key", []byte("value")); err != nil {
+		b.Fatal(err)
+	}
+	defer kv.Delete(ctx, benchPrefix, "")
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r := get()
+		if _, err := r.Get(ctx, benchPrefix, "key"); err != nil {
+			b.Fatal(err)
+		}
+		release(r)
+	}
+}
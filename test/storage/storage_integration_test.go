@@ -1,3 +1,4 @@
+//go:build integration
 // +build integration
 
 package storage
@@ -7,7 +8,6 @@ import (
 	"testing"
 	"time"
 
-	"github.com/coreos/etcd/clientv3"
 	"github.com/stretchr/testify/require"
 
 	"github.com/supergiant/supergiant/pkg/storage"
@@ -19,11 +19,11 @@ const (
 	testPrefix      = "/test/"
 )
 
-var defaultConfig clientv3.Config
+var defaultConfig storage.ETCDConfig
 
 func init() {
 	assert.MustRunETCD(defaultETCDHost)
-	defaultConfig = clientv3.Config{
+	defaultConfig = storage.ETCDConfig{
 		Endpoints: []string{defaultETCDHost},
 	}
 }